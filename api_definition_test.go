@@ -1,6 +1,10 @@
 package schreder
 
-import "time"
+import (
+	"time"
+
+	"github.com/testmeifyoucan/schreder/match"
+)
 
 type HelloTest struct{}
 
@@ -261,3 +265,239 @@ func (t *UpdateUserTest) TestCases() []TestCase {
 		},
 	}
 }
+
+type ListUsersTest struct{}
+
+func (t *ListUsersTest) Method() string      { return "GET" }
+func (t *ListUsersTest) Description() string { return "Test for ListUsers API handler" }
+func (t *ListUsersTest) Path() string        { return "/users" }
+func (t *ListUsersTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description:      "All users",
+			ExpectedHttpCode: 200,
+			ExpectedData: []User{
+				{Login: "octocat", Name: "monalisa octocat"},
+				{Login: "hubot", Name: "Hubot"},
+			},
+		},
+		{
+			Description:      "Users filtered by login",
+			ExpectedHttpCode: 200,
+			QueryParams: ParamMap{
+				"login": Param{Value: "octocat"},
+			},
+			ExpectedData: []User{
+				{Login: "octocat", Name: "monalisa octocat"},
+			},
+		},
+		{
+			Description:      "Number of users",
+			ExpectedHttpCode: 200,
+			QueryParams: ParamMap{
+				"count": Param{Value: true},
+			},
+			ExpectedData: 2,
+		},
+		{
+			Description:      "Invalid filter",
+			ExpectedHttpCode: 400,
+			QueryParams: ParamMap{
+				"login": Param{Value: ""},
+			},
+			ExpectedData: "login must not be empty",
+		},
+	}
+}
+
+type FindUserTest struct{}
+
+func (t *FindUserTest) Method() string      { return "GET" }
+func (t *FindUserTest) Description() string { return "Test for finding users by login" }
+func (t *FindUserTest) Path() string        { return "/user/{username}" }
+func (t *FindUserTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "User with dash in login",
+			PathParams: ParamMap{
+				"username": Param{Value: "octo-cat"},
+			},
+
+			ExpectedHttpCode: 200,
+			ExpectedData: User{
+				Login: "octo-cat",
+			},
+		},
+	}
+}
+
+type SearchResult struct {
+	TotalCount int       `json:"total_count"`
+	Items      []User    `json:"items"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SearchUsersTest struct{}
+
+func (t *SearchUsersTest) Method() string      { return "GET" }
+func (t *SearchUsersTest) Description() string { return "Test for searching users" }
+func (t *SearchUsersTest) Path() string        { return "/search/users" }
+func (t *SearchUsersTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "Users found",
+			QueryParams: ParamMap{
+				"q": Param{Value: "octocat"},
+			},
+
+			ExpectedHttpCode: 200,
+			ExpectedData: match.WithExample(map[string]interface{}{
+				"total_count": match.Range(1, 100),
+				"items":       match.Contains(match.Subset(User{Login: "octocat"})),
+				"updated_at":  match.RFC3339(),
+			}, SearchResult{
+				TotalCount: 1,
+				Items:      []User{{Login: "octocat", ID: 1}},
+				UpdatedAt:  time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC),
+			}),
+		},
+	}
+}
+
+// OutdatedGetUserTest is a version of GetUserTest that does not follow the API anymore
+type OutdatedGetUserTest struct{}
+
+func (t *OutdatedGetUserTest) Method() string      { return "GET" }
+func (t *OutdatedGetUserTest) Description() string { return "Test for getting user details" }
+func (t *OutdatedGetUserTest) Path() string        { return "/user/{username}" }
+func (t *OutdatedGetUserTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "User by login",
+			Headers: ParamMap{
+				"Accept":        Param{Value: "application/json"},
+				"X-Api-Version": Param{Value: 1},
+			},
+			PathParams: ParamMap{
+				"login": Param{Value: "octocat"},
+			},
+			QueryParams: ParamMap{
+				"fields": Param{Value: "name"},
+			},
+			RequestBody:      map[string]interface{}{"login": "octocat"},
+			ExpectedHttpCode: 200,
+		},
+		{
+			Description: "User is a teapot",
+			PathParams: ParamMap{
+				"username": Param{Value: "teapot"},
+			},
+			ExpectedHttpCode: 418,
+		},
+	}
+}
+
+type UploadAvatarTest struct{}
+
+func (t *UploadAvatarTest) Method() string      { return "POST" }
+func (t *UploadAvatarTest) Description() string { return "Test for uploading user avatar" }
+func (t *UploadAvatarTest) Path() string        { return "/user/{username}/avatar" }
+func (t *UploadAvatarTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "Avatar uploaded",
+			Headers: ParamMap{
+				"Content-Type": Param{Value: "multipart/form-data"},
+			},
+			PathParams: ParamMap{
+				"username": Param{Value: "octocat"},
+			},
+			RequestBody: map[string]interface{}{
+				"avatar": File{Name: "octocat.png", ContentType: "image/png", Content: []byte("PNG")},
+				"tags":   []string{"cat", "octopus"},
+				"public": true,
+			},
+			ExpectedHttpCode: 204,
+		},
+	}
+}
+
+type ListItemsTest struct{}
+
+func (t *ListItemsTest) Method() string      { return "GET" }
+func (t *ListItemsTest) Description() string { return "Test for listing items of authenticated user" }
+func (t *ListItemsTest) Path() string        { return "/items" }
+func (t *ListItemsTest) SecuritySchemes() []SecurityScheme {
+	return []SecurityScheme{
+		{Name: "oauth2", Type: "oauth2", Flow: "application", TokenURL: "http://testapi.my/token", Scopes: []string{"items"}},
+		{Name: "apiKey", Type: "apiKey", In: "query", ParamName: "api_key"},
+	}
+}
+func (t *ListItemsTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "Items listed",
+			Headers: ParamMap{
+				"Authorization": Param{Value: "Bearer secret-token"},
+				"Accept":        Param{Value: "application/json"},
+			},
+			QueryParams: ParamMap{
+				"api_key": Param{Value: "secret-key"},
+				"page":    Param{Value: 1},
+			},
+			ExpectedHttpCode: 200,
+			ExpectedData:     []string{"item"},
+		},
+	}
+}
+
+type DeleteItemsTest struct{}
+
+func (t *DeleteItemsTest) Method() string      { return "DELETE" }
+func (t *DeleteItemsTest) Description() string { return "Test for deleting all items" }
+func (t *DeleteItemsTest) Path() string        { return "/items" }
+func (t *DeleteItemsTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "Items deleted",
+			Headers: ParamMap{
+				"Authorization": Param{Value: "Bearer secret-admin-token"},
+			},
+			ExpectedHttpCode: 204,
+		},
+	}
+}
+
+type LoginTest struct{}
+
+func (t *LoginTest) Method() string      { return "POST" }
+func (t *LoginTest) Description() string { return "Test for logging in" }
+func (t *LoginTest) Path() string        { return "/sessions/{tenant}" }
+func (t *LoginTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description: "Logged in",
+			Headers: ParamMap{
+				"X-Api-Key": Param{Value: "secret-api-key", Required: true, Secret: true},
+				"X-Session": Param{Value: "secret-session"},
+			},
+			PathParams: ParamMap{
+				"tenant": Param{Value: "acme"},
+			},
+			QueryParams: ParamMap{
+				"signature": Param{Value: "secret-signature", Secret: true},
+			},
+			RequestBody: map[string]interface{}{
+				"login":    "admin",
+				"password": "secret-password",
+			},
+			ExpectedHttpCode: 201,
+			ExpectedData: map[string]interface{}{
+				"login": "admin",
+				"tokens": []interface{}{
+					map[string]interface{}{"value": "secret-token", "scope": "items"},
+				},
+			},
+		},
+	}
+}
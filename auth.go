@@ -0,0 +1,465 @@
+package schreder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IAuthenticator authenticates requests of the runner. Authenticate is called
+// with the final request right before it's sent, when its headers and body
+// are already set, so it can add credentials or sign the request
+type IAuthenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// IAuthenticatorFunc implements IAuthenticator in a functional way
+type IAuthenticatorFunc func(req *http.Request) error
+
+func (f IAuthenticatorFunc) Authenticate(req *http.Request) error { return f(req) }
+
+// SecurityScheme describes how requests of the API are authenticated,
+// so it can be documented by doc generators
+type SecurityScheme struct {
+	// Name identifies the scheme in generated documentation
+	Name string
+	// Type is one of "basic", "apiKey" or "oauth2"
+	Type        string
+	Description string
+
+	// In and ParamName define where API key is sent: "header" or "query", and its name
+	In        string
+	ParamName string
+
+	// Flow is OAuth2 flow in terms of Swagger: "application" for client credentials
+	// grant, "password", "implicit" or "accessCode"
+	Flow             string
+	AuthorizationURL string
+	TokenURL         string
+	Scopes           []string
+}
+
+// ISecurityDescriber is implemented by authenticators that can describe
+// their security scheme, see WithAuthenticator
+type ISecurityDescriber interface {
+	SecurityScheme() SecurityScheme
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+// NewBasicAuth creates an authenticator of HTTP basic authentication
+func NewBasicAuth(username, password string) IAuthenticator {
+	return &basicAuth{username: username, password: password}
+}
+
+func (a *basicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (a *basicAuth) SecurityScheme() SecurityScheme {
+	return SecurityScheme{Name: "basic", Type: "basic"}
+}
+
+// Token is an access token of bearer authentication
+type Token struct {
+	AccessToken string
+	// Expiry is the time when the token expires, zero time means it never does
+	Expiry time.Time
+}
+
+// TokenFetcherFunc fetches a new access token
+type TokenFetcherFunc func() (Token, error)
+
+// tokenExpiryDelta makes tokens refreshed a bit before they expire,
+// so they do not expire while request is on its way
+const tokenExpiryDelta = 10 * time.Second
+
+type bearerAuth struct {
+	mu sync.Mutex
+	// fetch fetches a token with the context of the request being authenticated
+	fetch  func(ctx context.Context) (Token, error)
+	token  Token
+	scheme SecurityScheme
+	now    func() time.Time
+}
+
+// NewBearerAuth creates an authenticator that sends a token fetched by given
+// function in Authorization header. The token is fetched before the first
+// request and is fetched again once it expires, so long runs survive
+// expiration of tokens. It's safe for concurrent use
+func NewBearerAuth(fetch TokenFetcherFunc) IAuthenticator {
+	return newBearerAuth(func(context.Context) (Token, error) { return fetch() })
+}
+
+func newBearerAuth(fetch func(ctx context.Context) (Token, error)) *bearerAuth {
+	return &bearerAuth{
+		fetch: fetch,
+		scheme: SecurityScheme{
+			Name:        "bearer",
+			Type:        "apiKey",
+			Description: "Bearer token in Authorization header",
+			In:          "header",
+			ParamName:   "Authorization",
+		},
+		now: time.Now,
+	}
+}
+
+func (a *bearerAuth) Authenticate(req *http.Request) error {
+	token, err := a.validToken(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+func (a *bearerAuth) SecurityScheme() SecurityScheme {
+	return a.scheme
+}
+
+// validToken returns cached token or fetches a new one if it's missing or expired
+func (a *bearerAuth) validToken(ctx context.Context) (Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expired := !a.token.Expiry.IsZero() && !a.now().Add(tokenExpiryDelta).Before(a.token.Expiry)
+	if a.token.AccessToken != "" && !expired {
+		return a.token, nil
+	}
+
+	token, err := a.fetch(ctx)
+	if err != nil {
+		return token, fmt.Errorf("could not fetch access token: %s", err.Error())
+	}
+	a.token = token
+
+	return token, nil
+}
+
+// OAuth2Config defines a client of OAuth2 client credentials grant
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// HttpClient sends requests to the token endpoint. The client of the runner
+	// is used by default, http.Client is used outside of runs
+	HttpClient IHttpClient
+}
+
+// NewOAuth2ClientCredentials creates an authenticator that obtains access tokens
+// from the token endpoint by OAuth2 client credentials grant and sends them as
+// bearer tokens. Tokens are refreshed once they expire
+func NewOAuth2ClientCredentials(config OAuth2Config) IAuthenticator {
+	auth := newBearerAuth(func(ctx context.Context) (Token, error) {
+		return fetchClientCredentialsToken(ctx, config)
+	})
+	auth.scheme = SecurityScheme{
+		Name:     "oauth2",
+		Type:     "oauth2",
+		Flow:     "application",
+		TokenURL: config.TokenURL,
+		Scopes:   config.Scopes,
+	}
+
+	return auth
+}
+
+// fetchClientCredentialsToken requests a token with the context of the request
+// being authenticated, so the token request is canceled and timed out with it
+func fetchClientCredentialsToken(ctx context.Context, config OAuth2Config) (Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(config.Scopes) > 0 {
+		form.Set("scope", strings.Join(config.Scopes, " "))
+	}
+
+	client := config.HttpClient
+	if client == nil {
+		client = runnerHttpClient(ctx)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Token{}, fmt.Errorf("token endpoint responded with %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return Token{}, fmt.Errorf("could not decode response of token endpoint: %s", err.Error())
+	}
+	if tokenResponse.AccessToken == "" {
+		return Token{}, fmt.Errorf("token endpoint responded with no access token")
+	}
+
+	token := Token{AccessToken: tokenResponse.AccessToken}
+	if tokenResponse.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// runnerClientKey is a key of the context of a run that holds the HTTP client
+// of the runner
+type runnerClientKey struct{}
+
+// withRunnerHttpClient puts the HTTP client of the runner into the context of
+// the run, so authenticators that send requests of their own use it as well
+func withRunnerHttpClient(ctx context.Context, client IHttpClient) context.Context {
+	return context.WithValue(ctx, runnerClientKey{}, client)
+}
+
+// runnerHttpClient returns the HTTP client of the run the context belongs to,
+// http.Client is returned outside of runs
+func runnerHttpClient(ctx context.Context) IHttpClient {
+	if client, ok := ctx.Value(runnerClientKey{}).(IHttpClient); ok && client != nil {
+		return client
+	}
+
+	return &http.Client{}
+}
+
+type hmacAuth struct {
+	keyID  string
+	secret []byte
+	now    func() time.Time
+}
+
+// NewHMACAuth creates an authenticator that signs requests with HMAC-SHA256
+// of the secret. Signed string consists of lines with method, escaped path,
+// raw query, Date header and hex encoded SHA256 of the body:
+//
+//	Authorization: HMAC-SHA256 KeyId=<key id>, Signature=<base64 of the signature>
+//
+// Date header is set to the current time if the request has none
+func NewHMACAuth(keyID, secret string) IAuthenticator {
+	return &hmacAuth{keyID: keyID, secret: []byte(secret), now: time.Now}
+}
+
+func (a *hmacAuth) Authenticate(req *http.Request) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", a.now().UTC().Format(http.TimeFormat))
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		req.Header.Get("Date"),
+		hexSHA256(body),
+	}, "\n")
+	signature := base64.StdEncoding.EncodeToString(hmacSHA256(a.secret, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC-SHA256 KeyId=%s, Signature=%s", a.keyID, signature))
+
+	return nil
+}
+
+func (a *hmacAuth) SecurityScheme() SecurityScheme {
+	return SecurityScheme{
+		Name:        "hmac",
+		Type:        "apiKey",
+		Description: "HMAC-SHA256 signature of the request in Authorization header",
+		In:          "header",
+		ParamName:   "Authorization",
+	}
+}
+
+// AWSConfig defines credentials and scope of AWS Signature Version 4
+type AWSConfig struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent in X-Amz-Security-Token header for temporary credentials
+	SessionToken string
+	Region       string
+	Service      string
+}
+
+type awsSigV4Auth struct {
+	config AWSConfig
+	now    func() time.Time
+}
+
+// NewAWSSigV4Auth creates an authenticator that signs requests with AWS Signature
+// Version 4. All headers of the request but Authorization and User-Agent are signed
+func NewAWSSigV4Auth(config AWSConfig) IAuthenticator {
+	return &awsSigV4Auth{config: config, now: time.Now}
+}
+
+func (a *awsSigV4Auth) Authenticate(req *http.Request) error {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	now := a.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.config.SessionToken)
+	}
+
+	headers := map[string]string{}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers["host"] = host
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "authorization" || name == "user-agent" {
+			continue
+		}
+
+		trimmed := []string{}
+		for _, value := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, a.config.Region, a.config.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.config.SecretAccessKey), date)
+	for _, part := range []string{a.config.Region, a.config.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		a.config.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+func (a *awsSigV4Auth) SecurityScheme() SecurityScheme {
+	return SecurityScheme{
+		Name:        "sigv4",
+		Type:        "apiKey",
+		Description: "AWS Signature Version 4 in Authorization header",
+		In:          "header",
+		ParamName:   "Authorization",
+	}
+}
+
+// awsCanonicalQuery encodes query parameters sorted by name and value,
+// spaces are encoded as '%20'
+func awsCanonicalQuery(query url.Values) string {
+	pairs := []string{}
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+// readRequestBody reads body of the request and leaves the request
+// with a body that can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body: %s", err.Error())
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
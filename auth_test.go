@@ -0,0 +1,170 @@
+package schreder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://testapi.my/items", nil)
+	assert.NoError(t, NewBasicAuth("user", "secret").Authenticate(req))
+
+	username, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+}
+
+func TestBearerAuthRefreshesExpiredToken(t *testing.T) {
+	now := time.Date(2016, time.December, 1, 12, 0, 0, 0, time.UTC)
+	fetched := 0
+	auth := NewBearerAuth(func() (Token, error) {
+		fetched++
+		if fetched == 3 {
+			return Token{}, errors.New("server is down")
+		}
+		return Token{AccessToken: string(rune('a' + fetched - 1)), Expiry: now.Add(time.Minute)}, nil
+	}).(*bearerAuth)
+	auth.now = func() time.Time { return now }
+
+	authorization := func() (string, error) {
+		req, _ := http.NewRequest("GET", "http://testapi.my/items", nil)
+		err := auth.Authenticate(req)
+		return req.Header.Get("Authorization"), err
+	}
+
+	header, err := authorization()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer a", header)
+
+	now = now.Add(30 * time.Second)
+	header, _ = authorization()
+	assert.Equal(t, "Bearer a", header, "token is cached until it expires")
+
+	now = now.Add(25 * time.Second)
+	header, _ = authorization()
+	assert.Equal(t, "Bearer b", header, "token is refreshed a bit before it expires")
+
+	now = now.Add(time.Minute)
+	_, err = authorization()
+	assert.EqualError(t, err, "could not fetch access token: server is down")
+}
+
+func TestOAuth2UsesClientAndContextOfRun(t *testing.T) {
+	tokenRequests := 0
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		tokenRequests++
+		<-req.Context().Done() // hung token endpoint
+		return nil, req.Context().Err()
+	})
+
+	auth := NewOAuth2ClientCredentials(OAuth2Config{TokenURL: "http://auth.my/token", ClientID: "client"})
+	ctx, cancel := context.WithTimeout(withRunnerHttpClient(context.Background(), client), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://testapi.my/items", nil)
+
+	assert.EqualError(t, auth.Authenticate(req), "could not fetch access token: context deadline exceeded")
+	assert.Equal(t, 1, tokenRequests, "token is requested by the client of the runner")
+}
+
+func TestHMACAuth(t *testing.T) {
+	auth := NewHMACAuth("key", "secret").(*hmacAuth)
+	auth.now = func() time.Time { return time.Date(2016, time.December, 1, 12, 0, 0, 0, time.UTC) }
+
+	req, _ := http.NewRequest("POST", "http://testapi.my/items?b=2&a=1", bytes.NewBufferString(`{"name": "item"}`))
+	assert.NoError(t, auth.Authenticate(req))
+
+	assert.Equal(t, "Thu, 01 Dec 2016 12:00:00 GMT", req.Header.Get("Date"))
+	assert.Equal(t, "HMAC-SHA256 KeyId=key, Signature=Qlwrw/O3sHB49tXRvG3YGUA33wCwqJpF9Sx4bBcm4/k=", req.Header.Get("Authorization"))
+
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, `{"name": "item"}`, string(body), "body is still sent")
+}
+
+// requests and signatures are taken from the test suite of AWS Signature Version 4
+func TestAWSSigV4Auth(t *testing.T) {
+	auth := NewAWSSigV4Auth(AWSConfig{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}).(*awsSigV4Auth)
+	auth.now = func() time.Time { return time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC) }
+
+	for url, signature := range map[string]string{
+		"https://example.amazonaws.com/":                             "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		"https://example.amazonaws.com/?Param2=value2&Param1=value1": "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		assert.NoError(t, auth.Authenticate(req))
+
+		assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, Signature="+signature, req.Header.Get("Authorization"), url)
+	}
+}
+
+func TestRunAuthenticatesRequests(t *testing.T) {
+	authorizations := []string{}
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+	})
+
+	issued := 0
+	runner := NewRunner("http://testapi.my", RunnerConfig{
+		HttpClient: client,
+		Authenticator: NewBearerAuth(func() (Token, error) {
+			issued++
+			return Token{AccessToken: "token"}, nil
+		}),
+	})
+	runner.Run(t, &namedCasesTest{})
+
+	assert.Equal(t, []string{"Bearer token", "Bearer token"}, authorizations)
+	assert.Equal(t, 1, issued)
+}
+
+func TestGenerateSwaggerSecurity(t *testing.T) {
+	seed := spec.Swagger{}
+	seed.SecurityDefinitions = spec.SecurityDefinitions{"apiKey": spec.APIKeyAuth("X-Api-Key", "header")}
+	auth := NewOAuth2ClientCredentials(OAuth2Config{TokenURL: "http://auth.my/token", Scopes: []string{"items:read"}})
+
+	generator := WithAuthenticator(WithConflictPolicy(NewSwaggerGeneratorYAML(seed), ConflictMerge, nil), auth)
+	generator = WithSecurity(generator, SecurityScheme{Name: "basic", Type: "basic"})
+	doc, err := generator.Generate([]Test{&namedCasesTest{}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[interface{}]interface{}{
+		"apiKey": map[interface{}]interface{}{"type": "apiKey", "name": "X-Api-Key", "in": "header"},
+		"basic":  map[interface{}]interface{}{"type": "basic"},
+		"oauth2": map[interface{}]interface{}{
+			"type":     "oauth2",
+			"flow":     "application",
+			"tokenUrl": "http://auth.my/token",
+			"scopes":   map[interface{}]interface{}{"items:read": ""},
+		},
+	}, yamlPath(t, doc, "securityDefinitions"))
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"oauth2": []interface{}{"items:read"}},
+		map[interface{}]interface{}{"basic": []interface{}{}},
+	}, yamlPath(t, doc, "paths", "/a/1", "get", "security"))
+	assert.Len(t, seed.SecurityDefinitions, 1, "seed is not changed")
+
+	custom := &customGenerator{}
+	assert.Equal(t, custom, WithAuthenticator(custom, auth), "generators that can not document security are returned as is")
+}
+
+type customGenerator struct{}
+
+func (g *customGenerator) Generate(tests []Test) ([]byte, error) { return nil, nil }
@@ -0,0 +1,397 @@
+// Package cassette provides an HTTP client that records API traffic into
+// a cassette file and replays it later with no server at all:
+//
+//	recorder, err := cassette.New("fixtures/api.yml", cassette.Config{Mode: cassette.ModeAuto})
+//	...
+//	runner := schreder.NewRunner("http://localhost:1323", schreder.RunnerConfig{HttpClient: recorder})
+//	runner.Run(t, tests...)
+//	err = recorder.Save()
+//
+// Recorded requests are matched strictly: by method, URL and body. Bodies
+// must be encoded the same way on every run, e.g. multipart bodies need
+// a boundary defined in Content-Type header of the test case.
+//
+// Secrets are not written to the cassette: values of redacted headers, query
+// parameters and JSON body values are replaced with Redacted. Requests are
+// matched after the same redaction, replayed responses contain Redacted
+// instead of secrets.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/testmeifyoucan/schreder"
+)
+
+// Mode defines whether the recorder sends requests to the API or replays them
+type Mode int
+
+const (
+	// ModeAuto replays the cassette if it exists, otherwise records a new one
+	ModeAuto Mode = iota
+	// ModeReplay replays the cassette, requests that were not recorded fail
+	ModeReplay
+	// ModeRecord sends all requests to the API and records them, replacing
+	// interactions of existing cassette
+	ModeRecord
+)
+
+// Redacted replaces redacted values in the cassette
+const Redacted = schreder.Redacted
+
+// HttpClient is a client that sends requests to the API while recording.
+// schreder.IHttpClient and *http.Client implement it
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config contains options of the recorder
+type Config struct {
+	Mode Mode
+	// Client sends requests to the API while recording, http.DefaultClient
+	// is used if it's not set
+	Client HttpClient
+	// RedactHeaders lists headers of requests and responses which values are
+	// not written to the cassette in addition to schreder.DefaultRedaction ones
+	RedactHeaders []string
+	// RedactQueryParams lists query parameters of requests which values are
+	// not written to the cassette, e.g. API keys
+	RedactQueryParams []string
+	// RedactJSONPaths point to values of JSON request and response bodies that
+	// are not written to the cassette, see schreder.Redaction
+	RedactJSONPaths []string
+}
+
+// Cassette is content of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	// BodyEncoding is 'base64' for binary bodies
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// Response is a recorded HTTP response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	// BodyEncoding is 'base64' for binary bodies
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// encodeBody encodes content of a body to be put into the cassette. Text
+// is kept as is for readability, binary content is encoded as base64
+func encodeBody(content []byte) (string, string) {
+	if utf8.Valid(content) {
+		return string(content), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(content), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return nil, fmt.Errorf("unknown encoding of body '%s'", encoding)
+}
+
+// Recorder is an HTTP client that records interactions with the API or replays
+// them from a cassette. It's safe for concurrent use, so tests can be run in
+// parallel mode. Each recorded interaction is replayed once, in the order
+// of recording, so the same request may get different responses.
+type Recorder struct {
+	path      string
+	recording bool
+	client    HttpClient
+	query     []string
+	redaction schreder.Redaction
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// New creates a recorder of the cassette located at given path.
+// Cassette is loaded unless the recorder records it
+func New(path string, config Config) (*Recorder, error) {
+	r := &Recorder{
+		path:   path,
+		client: config.Client,
+		query:  config.RedactQueryParams,
+		redaction: schreder.Redaction{
+			Headers:   config.RedactHeaders,
+			JSONPaths: config.RedactJSONPaths,
+		},
+	}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+
+	switch config.Mode {
+	case ModeRecord:
+		r.recording = true
+	case ModeAuto:
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			r.recording = true
+		}
+	}
+
+	if r.recording {
+		return r, nil
+	}
+
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	r.interactions = cassette.Interactions
+	r.replayed = make([]bool, len(r.interactions))
+
+	return r, nil
+}
+
+// Load loads a cassette from the file
+func Load(path string) (*Cassette, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load cassette '%s': %s", path, err.Error())
+	}
+
+	cassette := &Cassette{}
+	if err := yaml.Unmarshal(content, cassette); err != nil {
+		return nil, fmt.Errorf("could not load cassette '%s': %s", path, err.Error())
+	}
+
+	return cassette, nil
+}
+
+// Recording reports whether requests are sent to the API and recorded
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Do sends the request to the API and records the interaction, or returns
+// recorded response in replay mode
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.recording {
+		return r.record(req, body)
+	}
+
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var responseBody []byte
+	if resp.Body != nil {
+		responseBody, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     r.redactURL(req.URL),
+			Headers: r.redactHeaders(req.Header),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    r.redactHeaders(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(r.redaction.RedactJSON(body))
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(r.redaction.RedactJSON(responseBody))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	// recorded requests are redacted, so is the one that is matched against them
+	url := r.redactURL(req.URL)
+	body = r.redaction.RedactJSON(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.replayed[i] || !matches(interaction.Request, req.Method, url, body) {
+			continue
+		}
+
+		responseBody, err := decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		r.replayed[i] = true
+
+		header := http.Header{}
+		for name, values := range interaction.Response.Headers {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(responseBody)),
+			ContentLength: int64(len(responseBody)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette '%s' has no interaction recorded for %s %s", r.path, req.Method, url)
+}
+
+// Save writes recorded interactions to the cassette file. Does nothing
+// in replay mode
+func (r *Recorder) Save() error {
+	if !r.recording {
+		return nil
+	}
+
+	r.mu.Lock()
+	content, err := yaml.Marshal(Cassette{Interactions: r.interactions})
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(r.path, content, 0644)
+}
+
+// Unused returns interactions of the cassette that were not replayed.
+// They usually mean that tests changed since the cassette was recorded
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unused := []Interaction{}
+	for i, interaction := range r.interactions {
+		if !r.recording && !r.replayed[i] {
+			unused = append(unused, interaction)
+		}
+	}
+
+	return unused
+}
+
+func (r *Recorder) redactHeaders(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	redacted := http.Header{}
+	for name, values := range header {
+		redacted[name] = values
+		if r.redaction.IsSecretHeader(name) {
+			redacted[name] = []string{Redacted}
+		}
+	}
+
+	return redacted
+}
+
+// redactURL returns the URL with values of redacted query parameters replaced
+func (r *Recorder) redactURL(u *url.URL) string {
+	if len(r.query) == 0 || u.RawQuery == "" {
+		return u.String()
+	}
+
+	query := u.Query()
+	redacted := false
+	for _, name := range r.query {
+		if _, ok := query[name]; ok {
+			query.Set(name, Redacted)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.String()
+	}
+
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// matches checks that recorded request has the same method, URL and body
+func matches(recorded Request, method, url string, body []byte) bool {
+	if recorded.Method != method || recorded.URL != url {
+		return false
+	}
+
+	recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	return err == nil && bytes.Equal(recordedBody, body)
+}
+
+// readRequestBody reads body of the request and restores it, so the request
+// still can be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
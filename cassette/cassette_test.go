@@ -0,0 +1,246 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer() (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"call":%d,"method":"%s","body":%q}`, calls, r.Method, string(body))
+	}))
+
+	return server, &calls
+}
+
+func newRequest(t *testing.T, method, url, body string) *http.Request {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Request-Id", "42")
+
+	return req
+}
+
+func do(t *testing.T, client HttpClient, req *http.Request) (*http.Response, string) {
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, string(body)
+}
+
+func tempCassette(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "fixtures", "api.yml"), func() { os.RemoveAll(dir) }
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path, cleanup := tempCassette(t)
+	defer cleanup()
+
+	server, calls := newServer()
+	recorder, err := New(path, Config{})
+	if !assert.NoError(t, err) || !assert.True(t, recorder.Recording()) {
+		return
+	}
+
+	_, body := do(t, recorder, newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"a"}`))
+	assert.Equal(t, `{"call":1,"method":"POST","body":"{\"name\":\"a\"}"}`, body)
+	do(t, recorder, newRequest(t, "GET", server.URL+"/users", ""))
+	do(t, recorder, newRequest(t, "GET", server.URL+"/users", ""))
+	do(t, recorder, newRequest(t, "PUT", server.URL+"/avatar", "\xff\xfe"))
+	if !assert.NoError(t, recorder.Save()) {
+		return
+	}
+	server.Close()
+
+	cassette, err := Load(path)
+	if !assert.NoError(t, err) || !assert.Len(t, cassette.Interactions, 4) {
+		return
+	}
+	recorded := cassette.Interactions[0]
+	assert.Equal(t, Redacted, recorded.Request.Headers.Get("Authorization"))
+	assert.Equal(t, "42", recorded.Request.Headers.Get("X-Request-Id"))
+	assert.Equal(t, Redacted, recorded.Response.Headers.Get("Set-Cookie"))
+	assert.Equal(t, "base64", cassette.Interactions[3].Request.BodyEncoding)
+
+	// server is closed, everything comes from the cassette
+	replayer, err := New(path, Config{})
+	if !assert.NoError(t, err) || !assert.False(t, replayer.Recording()) {
+		return
+	}
+
+	resp, body := do(t, replayer, newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"a"}`))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"call":1,"method":"POST","body":"{\"name\":\"a\"}"}`, body)
+
+	// the same requests get responses in the order of recording
+	_, body = do(t, replayer, newRequest(t, "GET", server.URL+"/users", ""))
+	assert.Contains(t, body, `"call":2`)
+	_, body = do(t, replayer, newRequest(t, "GET", server.URL+"/users", ""))
+	assert.Contains(t, body, `"call":3`)
+
+	assert.Len(t, replayer.Unused(), 1)
+	_, body = do(t, replayer, newRequest(t, "PUT", server.URL+"/avatar", "\xff\xfe"))
+	assert.Contains(t, body, `"call":4`)
+	assert.Empty(t, replayer.Unused())
+	assert.Equal(t, 4, *calls)
+}
+
+func TestReplayIsStrict(t *testing.T) {
+	path, cleanup := tempCassette(t)
+	defer cleanup()
+
+	server, _ := newServer()
+	defer server.Close()
+
+	recorder, _ := New(path, Config{Mode: ModeRecord})
+	do(t, recorder, newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"a"}`))
+	if !assert.NoError(t, recorder.Save()) {
+		return
+	}
+
+	replayer, err := New(path, Config{Mode: ModeReplay})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, req := range []*http.Request{
+		newRequest(t, "PUT", server.URL+"/users?x=1", `{"name":"a"}`),
+		newRequest(t, "POST", server.URL+"/users?x=2", `{"name":"a"}`),
+		newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"b"}`),
+	} {
+		_, err := replayer.Do(req)
+		assert.EqualError(t, err, fmt.Sprintf("cassette '%s' has no interaction recorded for %s %s",
+			path, req.Method, req.URL.String()))
+	}
+
+	// recorded interaction is replayed once
+	do(t, replayer, newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"a"}`))
+	_, err = replayer.Do(newRequest(t, "POST", server.URL+"/users?x=1", `{"name":"a"}`))
+	assert.Error(t, err)
+
+	_, err = New(path+".missing", Config{Mode: ModeReplay})
+	assert.Error(t, err)
+}
+
+func TestRerecord(t *testing.T) {
+	path, cleanup := tempCassette(t)
+	defer cleanup()
+
+	server, calls := newServer()
+	defer server.Close()
+
+	recorder, _ := New(path, Config{RedactHeaders: []string{"x-request-id"}})
+	do(t, recorder, newRequest(t, "GET", server.URL+"/a", ""))
+	do(t, recorder, newRequest(t, "GET", server.URL+"/b", ""))
+	assert.NoError(t, recorder.Save())
+
+	// existing cassette is replaced
+	recorder, _ = New(path, Config{Mode: ModeRecord})
+	if !assert.True(t, recorder.Recording()) {
+		return
+	}
+	_, body := do(t, recorder, newRequest(t, "POST", server.URL+"/c", "body"))
+	assert.Contains(t, body, `"body":"body"`)
+	assert.NoError(t, recorder.Save())
+	assert.Equal(t, 3, *calls)
+
+	cassette, err := Load(path)
+	if assert.NoError(t, err) && assert.Len(t, cassette.Interactions, 1) {
+		assert.Equal(t, "POST", cassette.Interactions[0].Request.Method)
+		assert.Equal(t, server.URL+"/c", cassette.Interactions[0].Request.URL)
+		assert.Equal(t, Redacted, cassette.Interactions[0].Request.Headers.Get("Authorization"))
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	assert.False(t, bytes.Contains(content, []byte("secret")), "cassette contains secrets:\n%s", content)
+}
+
+func TestRedactHeadersKeepsDefaults(t *testing.T) {
+	path, cleanup := tempCassette(t)
+	defer cleanup()
+
+	server, _ := newServer()
+	defer server.Close()
+
+	recorder, _ := New(path, Config{RedactHeaders: []string{"x-request-id"}})
+	do(t, recorder, newRequest(t, "GET", server.URL+"/a", ""))
+	assert.NoError(t, recorder.Save())
+
+	cassette, err := Load(path)
+	if assert.NoError(t, err) && assert.Len(t, cassette.Interactions, 1) {
+		interaction := cassette.Interactions[0]
+		assert.Equal(t, Redacted, interaction.Request.Headers.Get("X-Request-Id"))
+		assert.Equal(t, Redacted, interaction.Request.Headers.Get("Authorization"), "default headers are always redacted")
+		assert.Equal(t, Redacted, interaction.Response.Headers.Get("Set-Cookie"), "default headers are always redacted")
+	}
+}
+
+func TestRedactQueryAndBody(t *testing.T) {
+	path, cleanup := tempCassette(t)
+	defer cleanup()
+
+	server, calls := newServer()
+	defer server.Close()
+
+	config := Config{RedactQueryParams: []string{"key"}, RedactJSONPaths: []string{"$.password", "$.body"}}
+	recorder, _ := New(path, config)
+	_, body := do(t, recorder, newRequest(t, "POST", server.URL+"/login?key=k1&x=1", `{"login":"a","password":"p1"}`))
+	assert.Contains(t, body, `"password\":\"p1\"`, "client gets the response as is")
+	assert.NoError(t, recorder.Save())
+
+	cassette, err := Load(path)
+	if assert.NoError(t, err) && assert.Len(t, cassette.Interactions, 1) {
+		recorded := cassette.Interactions[0]
+		assert.Equal(t, server.URL+"/login?key=%5BREDACTED%5D&x=1", recorded.Request.URL)
+		assert.Equal(t, `{"login":"a","password":"[REDACTED]"}`, recorded.Request.Body)
+		assert.Equal(t, `{"body":"[REDACTED]","call":1,"method":"POST"}`, recorded.Response.Body)
+	}
+
+	// requests are matched by their redacted secrets, so the ones sent
+	// with other credentials are replayed as well
+	replayer, err := New(path, config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, body = do(t, replayer, newRequest(t, "POST", server.URL+"/login?key=k2&x=1", `{"login":"a","password":"p2"}`))
+	assert.Equal(t, `{"body":"[REDACTED]","call":1,"method":"POST"}`, body)
+	assert.Equal(t, 1, *calls)
+
+	_, err = replayer.Do(newRequest(t, "POST", server.URL+"/login?key=k3&x=2", `{"login":"a","password":"p3"}`))
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "k3")
+	}
+}
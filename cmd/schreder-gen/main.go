@@ -0,0 +1,43 @@
+// Command schreder-gen generates schreder tests from Swagger 2.0 or OpenAPI 3 document:
+//
+//	schreder-gen -package api -out api_test.go swagger.yml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/testmeifyoucan/schreder/codegen"
+)
+
+func main() {
+	pkg := flag.String("package", "main", "package of generated code")
+	out := flag.String("out", "", "file to write generated code to, stdout by default")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] document\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	code, err := codegen.GenerateFile(flag.Arg(0), codegen.Options{Package: *pkg})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate tests: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write generated code: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
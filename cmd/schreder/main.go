@@ -0,0 +1,406 @@
+// Command schreder runs tests defined by YAML or JSON suite files against an API
+// and generates documentation of the API from the same files:
+//
+//	schreder run -url http://localhost:1323 users.yml
+//	schreder run -profiles profiles.yml -profile staging users.yml
+//	schreder generate -format raml -url http://localhost:1323 -out api.raml users.yml
+//
+// The API is defined either by -url or by an environment profile, see
+// schreder.SelectProfile. Values of -url and -header override the profile.
+//
+// See schreder.LoadTests for the format of suite files. Exit code is 0 when all
+// tests pass, 1 when some of them fail and 2 when tests could not be run at all.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-raml/raml"
+
+	"github.com/testmeifyoucan/schreder"
+)
+
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+const usage = `Usage:
+  %[1]s run [flags] suite...       run tests of suite files against the API
+  %[1]s generate [flags] suite...  generate documentation from suite files
+
+Run '%[1]s <command> -h' for flags of the command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(exitError)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		os.Exit(run(os.Args[2:]))
+	case "generate":
+		os.Exit(generate(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		fmt.Fprintf(os.Stdout, usage, os.Args[0])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, usage, os.Args[0])
+		os.Exit(exitError)
+	}
+}
+
+// headerFlags collects repeated '-header "Name: value"' flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("header must look like 'Name: value'")
+	}
+	h[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+
+	return nil
+}
+
+// listFlags collects values of a repeated flag
+type listFlags []string
+
+func (l *listFlags) String() string { return "" }
+
+func (l *listFlags) Set(value string) error {
+	*l = append(*l, value)
+
+	return nil
+}
+
+// profileFlags defines flags that select an environment profile
+func profileFlags(flags *flag.FlagSet) (path, name *string) {
+	path = flags.String("profiles", "", "file of environment profiles")
+	name = flags.String("profile", "", "profile of the API, $SCHREDER_PROFILE by default")
+	return path, name
+}
+
+// selectProfile picks the environment profile, base URL overrides the one of the profile
+func selectProfile(path, name, baseURL string) (schreder.Profile, error) {
+	profile, err := schreder.SelectProfile(path, name)
+	if err != nil {
+		return profile, err
+	}
+	if baseURL != "" {
+		profile.BaseUrl = baseURL
+	}
+
+	return profile, nil
+}
+
+// redactionFlags defines flags of secrets that must not be shown
+func redactionFlags(flags *flag.FlagSet) *schreder.Redaction {
+	redaction := &schreder.Redaction{}
+	flags.Var((*listFlags)(&redaction.Headers), "redact-header", "header which values are secret, may be repeated")
+	flags.Var((*listFlags)(&redaction.JSONPaths), "redact-path", "JSONPath of secret values of bodies like '$.password', may be repeated")
+
+	return redaction
+}
+
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags] suite...\n\nFlags:\n", os.Args[0], command)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// loadTests loads tests of all given suite files
+func loadTests(paths []string) ([]schreder.Test, error) {
+	tests := []schreder.Test{}
+	for _, path := range paths {
+		suiteTests, err := schreder.LoadTests(path)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, suiteTests...)
+	}
+
+	return tests, nil
+}
+
+func run(args []string) int {
+	headers := headerFlags{}
+	flags := newFlagSet("run")
+	baseURL := flags.String("url", "", "base URL of the API, required with no profile")
+	profiles, profile := profileFlags(flags)
+	flags.Var(headers, "header", "default header of requests like 'Name: value', may be repeated")
+	parallel := flags.Bool("parallel", false, "run tests in parallel")
+	maxParallel := flags.Int("max-parallel", 0, "limit of tests running simultaneously in parallel mode")
+	validateSchema := flags.Bool("validate-schema", false, "validate responses against the schema of expected data")
+	contractPath := flags.String("contract", "", "Swagger document the tests must conform to")
+	verbose := flags.Bool("v", false, "log all tests, not only failed ones")
+	redaction := redactionFlags(flags)
+	junitPath := flags.String("junit", "", "file to write JUnit XML report to")
+	jsonPath := flags.String("json-report", "", "file to write JSON report to")
+	htmlPath := flags.String("html-report", "", "file to write HTML report with requests and responses to")
+	timeout := flags.Duration("timeout", 0, "limit of the whole run, e.g. '5m'")
+	requestTimeout := flags.Duration("request-timeout", 0, "limit of every request, e.g. '10s'")
+	testTimeout := flags.Duration("test-timeout", 0, "limit of every test with its SetUp, e.g. '1m'")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+	env, err := selectProfile(*profiles, *profile, *baseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError
+	}
+	if env.BaseUrl == "" {
+		flags.Usage()
+		return exitError
+	}
+
+	tests, err := loadTests(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError
+	}
+
+	config := schreder.RunnerConfig{
+		DefaultHeaders: headers,
+		Parallel:       *parallel,
+		MaxParallel:    *maxParallel,
+		ValidateSchema: *validateSchema,
+		Redaction:      *redaction,
+		Report:         schreder.NewReport(),
+		RequestTimeout: *requestTimeout,
+		TestTimeout:    *testTimeout,
+	}
+	if *contractPath != "" {
+		if config.Contract, err = schreder.LoadContract(*contractPath); err != nil {
+			fmt.Fprintf(os.Stderr, "could not load the contract: %s\n", err.Error())
+			return exitError
+		}
+	}
+
+	var mu sync.Mutex
+	results := []schreder.TestCaseResult{}
+	config.OnResult = func(result schreder.TestCaseResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
+	}
+
+	runner, err := schreder.NewProfileRunner(env, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError
+	}
+	// interrupted run fails pending requests and still tears tests down,
+	// so does the run that exceeds the timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	ok := runWithTesting(*verbose, func(t *testing.T) {
+		runner.RunWithContext(ctx, t, tests...)
+		printSummary(results)
+		writeReport(t, *junitPath, config.Report.WriteJUnit)
+		writeReport(t, *jsonPath, config.Report.WriteJSON)
+		writeReport(t, *htmlPath, config.Report.WriteHTML)
+	})
+
+	return exitCode(ok, config.Report)
+}
+
+// testingMu serializes runs of runWithTesting, they share flags of the testing package
+var testingMu sync.Mutex
+
+// runWithTesting runs f as a test of the testing package, so failures are
+// reported the same way 'go test' does. Reports whether the test passed.
+//
+// This is the only place the command depends on the testing package running
+// tests outside of 'go test': testing.RunTests is what main functions generated
+// by 'go test' use and it is not covered by the compatibility promise. Flags of
+// the testing package are registered only to turn on verbose output by test.v,
+// the flag is restored once f is done, so runs in one process don't affect
+// each other
+func runWithTesting(verbose bool, f func(t *testing.T)) bool {
+	testingMu.Lock()
+	defer testingMu.Unlock()
+
+	testing.Init()
+	if v := flag.Lookup("test.v"); v != nil {
+		previous := v.Value.String()
+		v.Value.Set(strconv.FormatBool(verbose))
+		defer v.Value.Set(previous)
+	}
+
+	return testing.RunTests(matchAll, []testing.InternalTest{{Name: "schreder", F: f}})
+}
+
+// exitCode returns exit code of the run: reports that could not be written
+// fail the run as well as failed tests
+func exitCode(ok bool, report *schreder.Report) int {
+	if !ok || !report.Passed() {
+		return exitFailed
+	}
+
+	return exitPassed
+}
+
+func matchAll(pattern, name string) (bool, error) { return true, nil }
+
+// printSummary prints results of test cases
+func printSummary(results []schreder.TestCaseResult) {
+	fmt.Println()
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+			failed++
+		}
+
+		name := result.Test
+		if result.TestCase != "" {
+			name += " / " + result.TestCase
+		}
+		fmt.Printf("%s  %s %s  %s (%s)\n", status, result.Method, result.Path, name,
+			result.Duration.Round(time.Millisecond))
+	}
+	fmt.Printf("\n%d test cases: %d passed, %d failed\n", len(results), len(results)-failed, failed)
+}
+
+// writeReport writes the report to the file if its path is given
+func writeReport(t *testing.T, path string, write func(w io.Writer) error) {
+	if path == "" {
+		return
+	}
+
+	file, err := os.Create(path)
+	if err == nil {
+		err = write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		t.Errorf("could not write report '%s': %s", path, err.Error())
+	}
+}
+
+func generate(args []string) int {
+	flags := newFlagSet("generate")
+	format := flags.String("format", "swagger", "format of documentation: swagger, openapi, raml or raml10")
+	asJSON := flags.Bool("json", false, "generate JSON instead of YAML, Swagger and OpenAPI only")
+	title := flags.String("title", "API", "title of the API")
+	version := flags.String("version", "1.0", "version of the API")
+	description := flags.String("description", "", "description of the API")
+	baseURL := flags.String("url", "", "base URL of the API")
+	profiles, profile := profileFlags(flags)
+	out := flags.String("out", "", "file to write documentation to, stdout by default")
+	redaction := redactionFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	var generator schreder.IDocGenerator
+	info := &spec.Info{}
+	info.Title = *title
+	info.Version = *version
+	info.Description = *description
+	switch *format {
+	case "swagger":
+		seed := spec.Swagger{}
+		seed.Info = info
+		if *asJSON {
+			generator = schreder.NewSwaggerGeneratorJSONIndent(seed)
+		} else {
+			generator = schreder.NewSwaggerGeneratorYAML(seed)
+		}
+	case "openapi":
+		seed := schreder.OpenAPI{Info: info}
+		if *asJSON {
+			generator = schreder.NewOpenAPIGeneratorJSONIndent(seed)
+		} else {
+			generator = schreder.NewOpenAPIGeneratorYAML(seed)
+		}
+	case "raml", "raml10":
+		seed := raml.APIDefinition{}
+		seed.Title = *title
+		seed.Version = *version
+		seed.MediaType = "application/json"
+		if *format == "raml" {
+			generator = schreder.NewRamlGenerator(seed)
+		} else {
+			generator = schreder.NewRaml10Generator(seed)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format '%s'\n", *format)
+		return exitError
+	}
+
+	// the server of the API is documented if it's known
+	env, err := selectProfile(*profiles, *profile, *baseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError
+	}
+	if env.BaseUrl != "" {
+		if generator, err = schreder.WithProfile(generator, env); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return exitError
+		}
+	}
+
+	tests, err := loadTests(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitError
+	}
+
+	doc, err := schreder.WithRedaction(generator, *redaction).Generate(tests)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate documentation: %s\n", err.Error())
+		return exitFailed
+	}
+
+	if *out == "" {
+		os.Stdout.Write(doc)
+		return exitPassed
+	}
+	if err := ioutil.WriteFile(*out, doc, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write documentation: %s\n", err.Error())
+		return exitFailed
+	}
+
+	return exitPassed
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const helloSuite = `
+tests:
+- method: GET
+  path: /hello
+  cases:
+  - expectedHttpCode: 200
+    expectedData: Hello World!
+`
+
+func TestRunExitCode(t *testing.T) {
+	greeting := "Hello World!"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(greeting))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	suite := filepath.Join(dir, "hello.yml")
+	if !assert.NoError(t, ioutil.WriteFile(suite, []byte(helloSuite), 0644)) {
+		return
+	}
+
+	assert.Equal(t, exitPassed, run([]string{"-url", server.URL, suite}))
+
+	verbose := flag.Lookup("test.v").Value.String()
+	assert.Equal(t, exitPassed, run([]string{"-v", "-url", server.URL, suite}))
+	assert.Equal(t, verbose, flag.Lookup("test.v").Value.String(), "flags of the testing package are restored")
+
+	greeting = "Bye World!"
+	assert.Equal(t, exitFailed, run([]string{"-url", server.URL, suite}), "tests fail")
+
+	assert.Equal(t, exitError, run([]string{"-url", server.URL, filepath.Join(dir, "missing.yml")}))
+	assert.Equal(t, exitError, run([]string{suite}), "URL is required with no profile")
+}
@@ -0,0 +1,722 @@
+// Package codegen generates schreder tests from an existing Swagger 2.0 or
+// OpenAPI 3 document, so onboarding of an API does not start from scratch.
+//
+// Every operation of the document becomes a Test type and every documented
+// status code of the operation becomes a test case. Parameters, request and
+// response bodies of test cases are seeded from examples of the document,
+// or from schemas if examples are missing. Parameters lead to the first success
+// response, so test cases of other responses with no example in the document
+// are commented out. Schemas of definitions become model structs used by
+// the test cases. Generated tests are a starting point:
+// review the test cases, as examples of a document rarely describe data
+// of a real instance of the API.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-openapi/spec"
+)
+
+// Options defines generated code
+type Options struct {
+	// Package is the name of the package of generated code, 'main' by default
+	Package string
+	// Source is the name of the document that is mentioned in generated code
+	Source string
+}
+
+// commonInitialisms are kept upper case in Go names
+var commonInitialisms = map[string]bool{
+	"API": true, "CSS": true, "DNS": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "SQL": true, "TLS": true, "UI": true, "URI": true, "URL": true,
+	"UUID": true, "XML": true,
+}
+
+// GenerateFile generates Go source of tests from the document at given path
+func GenerateFile(path string, options Options) ([]byte, error) {
+	doc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if options.Source == "" {
+		options.Source = path
+	}
+
+	return Generate(doc, options)
+}
+
+// Generate generates Go source of tests from Swagger 2.0 or OpenAPI 3 document
+// in JSON or YAML format
+func Generate(doc []byte, options Options) ([]byte, error) {
+	a, err := loadAPI(doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the document: %s", err.Error())
+	}
+
+	g := newGenerator(a)
+	code := g.generate(options)
+
+	formatted, err := format.Source(code)
+	if err != nil {
+		return nil, fmt.Errorf("could not format generated code: %s", err.Error())
+	}
+
+	return formatted, nil
+}
+
+type generator struct {
+	api *api
+	// names are Go names of models by their names in the document
+	names map[string]string
+	// inlineNames are Go names of declared nested objects by their name hints
+	inlineNames map[string]string
+	used        map[string]bool
+	// types are declarations of types of models and nested objects
+	types    []string
+	declared map[string]bool
+	usesTime bool
+	// usesTimePointer is set when literals of optional time fields need timePointer
+	usesTimePointer bool
+}
+
+func newGenerator(a *api) *generator {
+	g := &generator{
+		api:         a,
+		names:       map[string]string{},
+		inlineNames: map[string]string{},
+		used:        map[string]bool{},
+		declared:    map[string]bool{},
+	}
+
+	models := make([]string, 0, len(a.models))
+	for name := range a.models {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+	for _, name := range models {
+		g.names[name] = g.uniqueName(goName(name))
+	}
+
+	return g
+}
+
+func (g *generator) generate(options Options) []byte {
+	pkg := options.Package
+	if pkg == "" {
+		pkg = "main"
+	}
+
+	models := make([]string, 0, len(g.api.models))
+	for name := range g.api.models {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+	for _, name := range models {
+		model := g.api.models[name]
+		g.declareType(g.names[name], &model)
+	}
+
+	tests := &bytes.Buffer{}
+	for _, op := range g.api.operations {
+		g.writeTest(tests, op)
+	}
+
+	code := &bytes.Buffer{}
+	if options.Source != "" {
+		fmt.Fprintf(code, "// Tests generated by schreder-gen from %s.\n", options.Source)
+		fmt.Fprintf(code, "// Test cases are seeded from examples of the document, please review them.\n\n")
+	}
+	fmt.Fprintf(code, "package %s\n\n", pkg)
+	imports := []string{}
+	if g.usesTime {
+		imports = append(imports, "\"time\"\n")
+	}
+	if len(g.api.operations) > 0 {
+		imports = append(imports, "\"github.com/testmeifyoucan/schreder\"\n")
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(code, "import (\n%s)\n\n", strings.Join(imports, "\n"))
+	}
+
+	for _, declaration := range g.types {
+		code.WriteString(declaration)
+		code.WriteString("\n")
+	}
+	if g.usesTimePointer {
+		code.WriteString("// timePointer returns a pointer to the time for optional time fields\n")
+		code.WriteString("func timePointer(t time.Time) *time.Time { return &t }\n\n")
+	}
+	code.Write(tests.Bytes())
+
+	return code.Bytes()
+}
+
+func (g *generator) writeTest(w *bytes.Buffer, op operation) {
+	name := op.id
+	if name == "" {
+		name = operationName(op.method, op.path)
+	}
+	name = goName(name)
+	typeName := g.uniqueName(name + "Test")
+
+	description := op.description
+	if description == "" {
+		description = op.method + " " + op.path
+	}
+
+	fmt.Fprintf(w, "// %s tests %s %s\n", typeName, op.method, op.path)
+	fmt.Fprintf(w, "type %s struct{}\n\n", typeName)
+	fmt.Fprintf(w, "func (t *%s) Method() string { return %q }\n", typeName, op.method)
+	fmt.Fprintf(w, "func (t *%s) Description() string { return %q }\n", typeName, description)
+	fmt.Fprintf(w, "func (t *%s) Path() string { return %q }\n", typeName, op.path)
+	fmt.Fprintf(w, "func (t *%s) TestCases() []schreder.TestCase {\n", typeName)
+	fmt.Fprintf(w, "return []schreder.TestCase{\n")
+
+	primary := primaryResponse(op.responses)
+	for i, resp := range op.responses {
+		// parameters of the operation lead to its primary response, other
+		// responses are tested only if the document gives an example of them
+		if i == primary || resp.body != nil && resp.body.documented {
+			g.writeTestCase(w, op, resp, name)
+			continue
+		}
+
+		usesTime, types := g.usesTime, len(g.types)
+		testCase := &bytes.Buffer{}
+		g.writeTestCase(testCase, op, resp, name)
+		if len(g.types) == types {
+			// the time is used only by the commented out test case
+			g.usesTime = usesTime
+		}
+		fmt.Fprintf(w, "// TODO: set parameters of a request that gets %d response, the document does not describe them\n//\n", resp.code)
+		writeCommentedOut(w, testCase.String())
+	}
+
+	w.WriteString("}\n}\n\n")
+}
+
+func (g *generator) writeTestCase(w *bytes.Buffer, op operation, resp response, name string) {
+	w.WriteString("{\n")
+	caseDescription := resp.description
+	if caseDescription == "" {
+		caseDescription = fmt.Sprintf("%d response", resp.code)
+	}
+	fmt.Fprintf(w, "Description: %q,\n", caseDescription)
+
+	headers := map[string]string{}
+	query := map[string]string{}
+	path := map[string]string{}
+	for _, param := range op.params {
+		value := g.paramLiteral(param)
+		switch param.in {
+		case "header":
+			headers[param.name] = value
+		case "query":
+			query[param.name] = value
+		case "path":
+			path[param.name] = value
+		}
+	}
+	if op.body != nil && op.body.mediaType != defaultMediaType {
+		headers["Content-Type"] = fmt.Sprintf("schreder.Param{Value: %q}", op.body.mediaType)
+	}
+	writeParamMap(w, "Headers", headers)
+	writeParamMap(w, "QueryParams", query)
+	writeParamMap(w, "PathParams", path)
+
+	if op.body != nil && op.body.example != nil {
+		fmt.Fprintf(w, "RequestBody: %s,\n", g.literal(op.body.schema, op.body.example, name+"Request"))
+	}
+
+	fmt.Fprintf(w, "ExpectedHttpCode: %d,\n", resp.code)
+	if resp.body != nil && resp.body.example != nil {
+		fmt.Fprintf(w, "ExpectedData: %s,\n", g.literal(resp.body.schema, resp.body.example, name+"Response"))
+	}
+	w.WriteString("},\n")
+}
+
+// primaryResponse returns index of the first success response,
+// or of the first response if there is no success one
+func primaryResponse(responses []response) int {
+	for i, resp := range responses {
+		if resp.code >= 200 && resp.code < 300 {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// writeCommentedOut writes the code as a code block of a comment indented
+// by nesting of braces, as gofmt does not format commented out code
+func writeCommentedOut(w *bytes.Buffer, code string) {
+	depth := 0
+	for _, line := range strings.Split(strings.TrimSpace(code), "\n") {
+		if strings.HasPrefix(line, "}") {
+			depth--
+		}
+		fmt.Fprintf(w, "//\t%s%s\n", strings.Repeat("\t", depth), line)
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if strings.HasPrefix(line, "}") {
+			depth++
+		}
+	}
+}
+
+func (g *generator) paramLiteral(param parameter) string {
+	value := fmt.Sprintf("schreder.Param{Value: %s", genericLiteral(param.example))
+	if param.required {
+		value += ", Required: true"
+	}
+	if param.description != "" {
+		value += fmt.Sprintf(", Description: %q", param.description)
+	}
+
+	return value + "}"
+}
+
+func writeParamMap(w *bytes.Buffer, field string, params map[string]string) {
+	if len(params) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%s: schreder.ParamMap{\n", field)
+	for _, name := range names {
+		fmt.Fprintf(w, "%q: %s,\n", name, params[name])
+	}
+	w.WriteString("},\n")
+}
+
+// goType returns Go type of values described by the schema. Objects with
+// properties that are not models are declared as types named by nameHint
+func (g *generator) goType(schema *spec.Schema, nameHint string) string {
+	if schema == nil {
+		return "interface{}"
+	}
+	if ref := schema.Ref.String(); ref != "" {
+		if name, ok := g.names[refName(ref)]; ok {
+			return name
+		}
+		return "interface{}"
+	}
+	if len(schema.AllOf) > 0 {
+		schema = mergeAllOf(schema, g.api.models)
+	}
+
+	switch schemaType(schema) {
+	case "string":
+		if schema.Format == "date-time" {
+			g.usesTime = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		if schema.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if schema.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(schema.Items.Schema, nameHint+"Item")
+	case "object":
+		if len(schema.Properties) > 0 {
+			name, ok := g.inlineNames[nameHint]
+			if !ok {
+				name = g.uniqueName(nameHint)
+				g.inlineNames[nameHint] = name
+			}
+			g.declareType(name, schema)
+			return name
+		}
+		if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+			return "map[string]" + g.goType(schema.AdditionalProperties.Schema, nameHint+"Value")
+		}
+		return "map[string]interface{}"
+	}
+
+	return "interface{}"
+}
+
+// declareType declares a type of given name for the schema: a struct for
+// objects, a named type of the schema type for others
+func (g *generator) declareType(name string, schema *spec.Schema) {
+	if g.declared[name] {
+		return
+	}
+	g.declared[name] = true
+	// nested types are declared after the type that uses them
+	index := len(g.types)
+	g.types = append(g.types, "")
+
+	if len(schema.AllOf) > 0 {
+		schema = mergeAllOf(schema, g.api.models)
+	}
+
+	w := &bytes.Buffer{}
+	if schema.Description != "" {
+		fmt.Fprintf(w, "// %s is %s\n", name, commentText(schema.Description))
+	}
+
+	if schemaType(schema) != "object" || len(schema.Properties) == 0 {
+		fmt.Fprintf(w, "type %s %s\n", name, g.goType(schema, name))
+		g.types[index] = w.String()
+		return
+	}
+
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	for _, property := range sortedProperties(schema) {
+		propertySchema := schema.Properties[property]
+		fieldName := goName(property)
+		fieldType := g.goType(&propertySchema, name+fieldName)
+		tag := property
+		if !isRequired(schema, property) {
+			tag += ",omitempty"
+			// omitempty does not omit zero structs
+			if g.isStruct(&propertySchema, fieldType) {
+				fieldType = "*" + fieldType
+			}
+		}
+		fmt.Fprintf(w, "%s %s `json:%q`\n", fieldName, fieldType, tag)
+	}
+	w.WriteString("}\n")
+
+	g.types[index] = w.String()
+}
+
+// isStruct reports whether values of the schema are of struct type
+// that is given Go type of the schema
+func (g *generator) isStruct(schema *spec.Schema, goType string) bool {
+	if goType == "time.Time" {
+		return true
+	}
+	if ref := schema.Ref.String(); ref != "" {
+		model, ok := g.api.models[refName(ref)]
+		if !ok {
+			return false
+		}
+		schema = &model
+	}
+	if len(schema.AllOf) > 0 {
+		schema = mergeAllOf(schema, g.api.models)
+	}
+
+	return schemaType(schema) == "object" && len(schema.Properties) > 0
+}
+
+// literal returns Go literal of the value of type described by the schema.
+// Values that do not fit the schema are written as generic JSON values
+func (g *generator) literal(schema *spec.Schema, value interface{}, nameHint string) string {
+	if literal, ok := g.typedLiteral(schema, value, nameHint); ok {
+		return literal
+	}
+
+	return genericLiteral(value)
+}
+
+func (g *generator) typedLiteral(schema *spec.Schema, value interface{}, nameHint string) (string, bool) {
+	if schema == nil {
+		return genericLiteral(value), true
+	}
+
+	goType := g.goType(schema, nameHint)
+	if value == nil {
+		if goType == "interface{}" || strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "map[") {
+			return "nil", true
+		}
+		return "", false
+	}
+
+	if ref := schema.Ref.String(); ref != "" {
+		model, ok := g.api.models[refName(ref)]
+		if !ok {
+			return genericLiteral(value), true
+		}
+		if schemaType(&model) == "object" || len(model.AllOf) > 0 {
+			return g.structLiteral(goType, &model, value)
+		}
+		literal, ok := g.typedLiteral(&model, value, goType)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%s(%s)", goType, literal), true
+	}
+	if len(schema.AllOf) > 0 {
+		return g.structLiteral(goType, mergeAllOf(schema, g.api.models), value)
+	}
+
+	switch goType {
+	case "interface{}":
+		return genericLiteral(value), true
+	case "time.Time":
+		s, ok := value.(string)
+		if !ok {
+			return "", false
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", false
+		}
+		t = t.UTC()
+		return fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, time.UTC)",
+			t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()), true
+	case "string":
+		s, ok := value.(string)
+		return strconv.Quote(s), ok
+	case "int32", "int64":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return "", false
+		}
+		return strconv.FormatInt(int64(n), 10), true
+	case "float32", "float64":
+		n, ok := value.(float64)
+		return strconv.FormatFloat(n, 'g', -1, 64), ok
+	case "bool":
+		b, ok := value.(bool)
+		return strconv.FormatBool(b), ok
+	}
+
+	switch schemaType(schema) {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return "", false
+		}
+		var itemSchema *spec.Schema
+		if schema.Items != nil {
+			itemSchema = schema.Items.Schema
+		}
+
+		literals := make([]string, 0, len(items))
+		for _, item := range items {
+			literal, ok := g.typedLiteral(itemSchema, item, nameHint+"Item")
+			if !ok {
+				return "", false
+			}
+			literals = append(literals, literal)
+		}
+		return fmt.Sprintf("%s{%s}", goType, strings.Join(literals, ", ")), true
+	case "object":
+		if len(schema.Properties) > 0 {
+			return g.structLiteral(goType, schema, value)
+		}
+
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		var valueSchema *spec.Schema
+		if schema.AdditionalProperties != nil {
+			valueSchema = schema.AdditionalProperties.Schema
+		}
+
+		literals := []string{}
+		for _, key := range sortedKeys(fields) {
+			literal, ok := g.typedLiteral(valueSchema, fields[key], nameHint+"Value")
+			if !ok {
+				return "", false
+			}
+			literals = append(literals, fmt.Sprintf("%q: %s", key, literal))
+		}
+		return fmt.Sprintf("%s{%s}", goType, strings.Join(literals, ", ")), true
+	}
+
+	return "", false
+}
+
+// structLiteral writes literal of a struct declared for the schema. Values
+// with unknown properties or with values that do not fit the schema can't be
+// written as the struct without loss of data
+func (g *generator) structLiteral(typeName string, schema *spec.Schema, value interface{}) (string, bool) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	if len(schema.AllOf) > 0 {
+		schema = mergeAllOf(schema, g.api.models)
+	}
+	for key := range fields {
+		if _, ok := schema.Properties[key]; !ok {
+			return "", false
+		}
+	}
+
+	literals := []string{}
+	for _, property := range sortedProperties(schema) {
+		fieldValue, ok := fields[property]
+		if !ok {
+			continue
+		}
+
+		propertySchema := schema.Properties[property]
+		fieldName := goName(property)
+		pointer := !isRequired(schema, property) && g.isStruct(&propertySchema, g.goType(&propertySchema, typeName+fieldName))
+		if pointer && fieldValue == nil {
+			continue
+		}
+
+		literal, ok := g.typedLiteral(&propertySchema, fieldValue, typeName+fieldName)
+		if !ok {
+			return "", false
+		}
+		switch {
+		case pointer && strings.HasPrefix(literal, "time."):
+			g.usesTimePointer = true
+			literal = "timePointer(" + literal + ")"
+		case pointer:
+			literal = "&" + literal
+		}
+		literals = append(literals, fmt.Sprintf("%s: %s", fieldName, literal))
+	}
+
+	if len(literals) == 0 {
+		return typeName + "{}", true
+	}
+
+	return fmt.Sprintf("%s{\n%s,\n}", typeName, strings.Join(literals, ",\n")), true
+}
+
+// genericLiteral returns Go literal of a value decoded from JSON
+func genericLiteral(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case string:
+		return strconv.Quote(value)
+	case []interface{}:
+		literals := make([]string, len(value))
+		for i, item := range value {
+			literals[i] = genericLiteral(item)
+		}
+		return fmt.Sprintf("[]interface{}{%s}", strings.Join(literals, ", "))
+	case map[string]interface{}:
+		literals := make([]string, 0, len(value))
+		for _, key := range sortedKeys(value) {
+			literals = append(literals, fmt.Sprintf("%q: %s", key, genericLiteral(value[key])))
+		}
+		return fmt.Sprintf("map[string]interface{}{%s}", strings.Join(literals, ", "))
+	}
+
+	return fmt.Sprintf("%#v", value)
+}
+
+func (g *generator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	g.used[unique] = true
+
+	return unique
+}
+
+// goName converts a name of the document into exported Go name,
+// e.g. 'avatar_url' into 'AvatarURL'
+func goName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	result := ""
+	for _, word := range words {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			result += upper
+			continue
+		}
+		first, size := utf8.DecodeRuneInString(word)
+		result += string(unicode.ToUpper(first)) + word[size:]
+	}
+
+	if first, _ := utf8.DecodeRuneInString(result); result == "" || unicode.IsDigit(first) {
+		result = "X" + result
+	}
+
+	return result
+}
+
+// operationName builds a name of an operation with no ID from its method and path,
+// e.g. 'GET /users/{id}' is named 'GetUsersByID'
+func operationName(method, path string) string {
+	name := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name += " by " + strings.Trim(segment, "{}")
+			continue
+		}
+		name += " " + segment
+	}
+
+	return name
+}
+
+// commentText joins lines of the text, so it fits a comment line. The first
+// letter is lowered to continue a sentence, unless it starts an abbreviation
+func commentText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	first, size := utf8.DecodeRuneInString(text)
+	if second, _ := utf8.DecodeRuneInString(text[size:]); size == len(text) || unicode.IsUpper(second) {
+		return text
+	}
+
+	return string(unicode.ToLower(first)) + text[size:]
+}
+
+func isRequired(schema *spec.Schema, property string) bool {
+	for _, name := range schema.Required {
+		if name == property {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedProperties(schema *spec.Schema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
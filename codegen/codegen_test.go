@@ -0,0 +1,232 @@
+package codegen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const swaggerDoc = `
+swagger: "2.0"
+info: {title: Pets, version: "1"}
+consumes: [application/json]
+produces: [application/json]
+definitions:
+  Pet:
+    description: A pet of the store
+    required: [id, name]
+    properties:
+      id: {type: integer, format: int64}
+      name: {type: string}
+      tags:
+        type: array
+        items: {type: string}
+      owner:
+        properties:
+          email: {type: string}
+      born_at: {type: string, format: date-time}
+  Status:
+    type: string
+    enum: [available, sold]
+parameters:
+  petID: {name: pet_id, in: path, required: true, type: integer, x-example: 7}
+paths:
+  /pets/{pet_id}:
+    parameters:
+    - $ref: '#/parameters/petID'
+    get:
+      operationId: getPet
+      summary: Get a pet
+      parameters:
+      - {name: X-Api-Key, in: header, type: string, description: API key}
+      - {name: fields, in: query, type: string, default: name}
+      responses:
+        200:
+          description: Pet found
+          schema: {$ref: '#/definitions/Pet'}
+          examples:
+            application/json: {id: 7, name: Rex, tags: [dog], owner: {email: rex@example.com}, born_at: "2016-03-04T05:06:07+01:00", color: brown}
+        404:
+          description: Pet not found
+  /pets:
+    post:
+      parameters:
+      - in: body
+        name: pet
+        schema: {$ref: '#/definitions/Pet'}
+      responses:
+        201:
+          description: Pet created
+          schema:
+            properties:
+              id: {type: integer}
+              status: {$ref: '#/definitions/Status'}
+`
+
+const openAPIDoc = `
+openapi: 3.0.3
+info: {title: Pets, version: "1"}
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: integer, format: int32}
+        name: {type: string}
+    NamedPet:
+      allOf:
+      - $ref: '#/components/schemas/Pet'
+      - properties:
+          nickname: {type: string}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+      - name: limit
+        in: query
+        schema: {type: integer}
+        examples:
+          small: {value: 10}
+      - {name: session, in: cookie, schema: {type: string}}
+      responses:
+        "200":
+          description: Pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/NamedPet'}
+              example: [{id: 1, name: Rex, nickname: Rexy}]
+        default:
+          description: Error
+    post:
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema: {$ref: '#/components/schemas/Pet'}
+            example: {name: Rex}
+      responses:
+        "204":
+          description: Created
+`
+
+// sourceImporter imports packages used by generated code from their sources,
+// it's shared by tests as it caches imported packages
+var sourceImporter = importer.ForCompiler(token.NewFileSet(), "source", nil)
+
+// assertValidGo type checks generated code against the schreder package
+func assertValidGo(t *testing.T, code []byte) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "generated.go", code, 0)
+	if err == nil {
+		config := types.Config{Importer: sourceImporter}
+		_, err = config.Check(file.Name.Name, fset, []*ast.File{file}, nil)
+	}
+	if err != nil {
+		t.Errorf("generated code is invalid: %s\n%s", err.Error(), code)
+	}
+}
+
+func TestGenerateFromSwagger(t *testing.T) {
+	code, err := Generate([]byte(swaggerDoc), Options{Package: "pets", Source: "pets.yml"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertValidGo(t, code)
+
+	for _, expected := range []string{
+		"// Tests generated by schreder-gen from pets.yml.",
+		"package pets",
+		`"time"`,
+		"// Pet is a pet of the store\ntype Pet struct {",
+		"BornAt *time.Time `json:\"born_at,omitempty\"`",
+		"ID     int64      `json:\"id\"`",
+		"Owner  *PetOwner  `json:\"owner,omitempty\"`",
+		"Tags   []string   `json:\"tags,omitempty\"`",
+		"type PetOwner struct {\n\tEmail string `json:\"email,omitempty\"`\n}",
+		"type Status string",
+
+		"// GetPetTest tests GET /pets/{pet_id}\ntype GetPetTest struct{}",
+		`func (t *GetPetTest) Description() string { return "Get a pet" }`,
+		`func (t *GetPetTest) Path() string        { return "/pets/{pet_id}" }`,
+		`"X-Api-Key": schreder.Param{Value: "string", Description: "API key"},`,
+		`"fields": schreder.Param{Value: "name"},`,
+		`"pet_id": schreder.Param{Value: 7, Required: true},`,
+		// the example has a property that Pet does not declare
+		`ExpectedData:     map[string]interface{}{"born_at": "2016-03-04T05:06:07+01:00", "color": "brown", "id": 7,`,
+		"// TODO: set parameters of a request that gets 404 response, the document does not describe them\n" +
+			"\t\t//\n\t\t//\t{\n\t\t//\t\tDescription: \"Pet not found\",",
+		"//\t\tExpectedHttpCode: 404,\n\t\t//\t},",
+
+		"// PostPetsTest tests POST /pets",
+		"RequestBody: Pet{\n\t\t\t\tBornAt: timePointer(time.Date(2016, time.January, 2, 15, 4, 5, 0, time.UTC)),\n" +
+			"\t\t\t\tID:     0,\n\t\t\t\tName:   \"string\",\n" +
+			"\t\t\t\tOwner: &PetOwner{\n\t\t\t\t\tEmail: \"string\",\n\t\t\t\t},\n" +
+			"\t\t\t\tTags: []string{\"string\"},\n\t\t\t},",
+		"ExpectedData: PostPetsResponse{\n\t\t\t\tID:     0,\n\t\t\t\tStatus: Status(\"available\"),\n\t\t\t},",
+		"type PostPetsResponse struct {",
+	} {
+		assert.Contains(t, string(code), expected)
+	}
+	assert.NotContains(t, string(code), "\t\t\tExpectedHttpCode: 404", "404 case would send parameters of 200 one")
+}
+
+func TestGenerateFromOpenAPI(t *testing.T) {
+	code, err := Generate([]byte(openAPIDoc), Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertValidGo(t, code)
+
+	for _, expected := range []string{
+		"package main",
+		"type NamedPet struct {\n\tID       int32  `json:\"id,omitempty\"`",
+		"Nickname string `json:\"nickname,omitempty\"`",
+		"// ListPetsTest tests GET /pets",
+		`"limit": schreder.Param{Value: 10},`,
+		"ExpectedData: []NamedPet{NamedPet{\n\t\t\t\tID:       1,\n\t\t\t\tName:     \"Rex\",\n\t\t\t\tNickname: \"Rexy\",\n\t\t\t}},",
+		"// PostPetsTest tests POST /pets",
+		`"Content-Type": schreder.Param{Value: "application/x-www-form-urlencoded"},`,
+		"RequestBody: Pet{\n\t\t\t\tName: \"Rex\",\n\t\t\t},",
+		"ExpectedHttpCode: 204,\n\t\t},",
+	} {
+		assert.Contains(t, string(code), expected)
+	}
+	assert.NotContains(t, string(code), "session")
+	assert.NotContains(t, string(code), "import (\n\t\"time\"")
+}
+
+func TestGenerateFromFixtures(t *testing.T) {
+	for _, path := range []string{"../fixtures/swagger/swagger.yml", "../fixtures/openapi/openapi.yml"} {
+		code, err := GenerateFile(path, Options{Package: "api"})
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		assertValidGo(t, code)
+		assert.Contains(t, string(code), "type User struct {", path)
+		assert.Contains(t, string(code), "// GetUserByUsernameTest tests GET /user/{username}", path)
+	}
+
+	_, err := Generate([]byte("raml: 1.0"), Options{})
+	assert.EqualError(t, err, "could not load the document: document is neither Swagger 2.0 nor OpenAPI 3")
+}
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"avatar_url": "AvatarURL",
+		"getUser":    "GetUser",
+		"user-id":    "UserID",
+		"2fa":        "X2fa",
+		"":           "X",
+		"émail":      "Émail",
+		"ünit_id":    "ÜnitID",
+	} {
+		assert.Equal(t, expected, goName(name), name)
+	}
+}
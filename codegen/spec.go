@@ -0,0 +1,633 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-openapi/spec"
+)
+
+const (
+	swaggerDefinitionsRefPrefix = "#/definitions/"
+	swaggerParametersRefPrefix  = "#/parameters/"
+	swaggerResponsesRefPrefix   = "#/responses/"
+	openAPISchemasRefPrefix     = "#/components/schemas/"
+	openAPIParametersRefPrefix  = "#/components/parameters/"
+	openAPIRequestBodyRefPrefix = "#/components/requestBodies/"
+	openAPIResponsesRefPrefix   = "#/components/responses/"
+
+	defaultMediaType = "application/json"
+)
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH"}
+
+// api is a description of an API that does not depend on format of the document
+type api struct {
+	models     map[string]spec.Schema
+	operations []operation
+}
+
+type operation struct {
+	id          string
+	method      string
+	path        string
+	description string
+	params      []parameter
+	body        *body
+	responses   []response
+}
+
+type parameter struct {
+	name        string
+	in          string
+	description string
+	required    bool
+	schema      *spec.Schema
+	example     interface{}
+}
+
+type body struct {
+	mediaType string
+	schema    *spec.Schema
+	example   interface{}
+	// documented is set when the example is given by the document,
+	// not built from the schema
+	documented bool
+}
+
+type response struct {
+	code        int
+	description string
+	body        *body
+}
+
+// loadAPI loads Swagger 2.0 or OpenAPI 3 document in JSON or YAML format
+func loadAPI(doc []byte) (*api, error) {
+	js, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var version struct {
+		Swagger string `json:"swagger"`
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.Unmarshal(js, &version); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(version.Swagger, "2."):
+		return loadSwagger(js)
+	case strings.HasPrefix(version.OpenAPI, "3."):
+		return loadOpenAPI(js)
+	}
+
+	return nil, fmt.Errorf("document is neither Swagger 2.0 nor OpenAPI 3")
+}
+
+func loadSwagger(js []byte) (*api, error) {
+	swagger := spec.Swagger{}
+	if err := json.Unmarshal(js, &swagger); err != nil {
+		return nil, err
+	}
+
+	a := &api{models: map[string]spec.Schema{}}
+	for name, schema := range swagger.Definitions {
+		a.models[name] = schema
+	}
+	if swagger.Paths == nil {
+		return a, nil
+	}
+
+	for path, pathItem := range swagger.Paths.Paths {
+		for _, method := range methods {
+			op := swaggerOperation(pathItem, method)
+			if op == nil {
+				continue
+			}
+
+			o := operation{
+				id:          op.ID,
+				method:      method,
+				path:        path,
+				description: firstNonEmpty(op.Summary, op.Description),
+			}
+
+			consumes := append(append([]string{}, op.Consumes...), swagger.Consumes...)
+			produces := append(append([]string{}, op.Produces...), swagger.Produces...)
+			formFields := map[string]interface{}{}
+			for _, param := range append(append([]spec.Parameter{}, pathItem.Parameters...), op.Parameters...) {
+				if ref := param.Ref.String(); ref != "" {
+					resolved, ok := swagger.Parameters[strings.TrimPrefix(ref, swaggerParametersRefPrefix)]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: could not resolve reference '%s'", method, path, ref)
+					}
+					param = resolved
+				}
+
+				switch param.In {
+				case "body":
+					o.body = &body{
+						mediaType: firstNonEmpty(append(consumes, defaultMediaType)...),
+						schema:    param.Schema,
+						example:   schemaExample(param.Schema, a.models),
+					}
+				case "formData":
+					formFields[param.Name] = swaggerParamExample(param, a.models)
+				default:
+					o.params = append(o.params, parameter{
+						name:        param.Name,
+						in:          param.In,
+						description: param.Description,
+						required:    param.Required,
+						schema:      swaggerParamSchema(param),
+						example:     swaggerParamExample(param, a.models),
+					})
+				}
+			}
+			if len(formFields) > 0 {
+				mediaType := "application/x-www-form-urlencoded"
+				for _, consumed := range consumes {
+					if consumed == "multipart/form-data" {
+						mediaType = consumed
+					}
+				}
+				o.body = &body{mediaType: mediaType, example: formFields}
+			}
+
+			if op.Responses != nil {
+				for code, resp := range op.Responses.StatusCodeResponses {
+					if ref := resp.Ref.String(); ref != "" {
+						resolved, ok := swagger.Responses[strings.TrimPrefix(ref, swaggerResponsesRefPrefix)]
+						if !ok {
+							return nil, fmt.Errorf("%s %s: could not resolve reference '%s'", method, path, ref)
+						}
+						resp = resolved
+					}
+
+					r := response{code: code, description: resp.Description}
+					if resp.Schema != nil {
+						mediaType := firstNonEmpty(append(produces, defaultMediaType)...)
+						example, ok := resp.Examples[mediaType]
+						if !ok {
+							example = schemaExample(resp.Schema, a.models)
+						}
+						r.body = &body{mediaType: mediaType, schema: resp.Schema, example: example, documented: ok}
+					}
+					o.responses = append(o.responses, r)
+				}
+			}
+
+			a.addOperation(o)
+		}
+	}
+	a.sortOperations()
+
+	return a, nil
+}
+
+func swaggerOperation(pathItem spec.PathItem, method string) *spec.Operation {
+	switch method {
+	case "GET":
+		return pathItem.Get
+	case "PUT":
+		return pathItem.Put
+	case "POST":
+		return pathItem.Post
+	case "DELETE":
+		return pathItem.Delete
+	case "OPTIONS":
+		return pathItem.Options
+	case "HEAD":
+		return pathItem.Head
+	case "PATCH":
+		return pathItem.Patch
+	}
+
+	return nil
+}
+
+// swaggerParamSchema converts simple schema of a non-body parameter into a schema
+func swaggerParamSchema(param spec.Parameter) *spec.Schema {
+	schema := &spec.Schema{}
+	schema.Type = spec.StringOrArray{param.Type}
+	schema.Format = param.Format
+	schema.Enum = param.Enum
+	schema.Default = param.Default
+	if param.Items != nil {
+		items := &spec.Schema{}
+		items.Type = spec.StringOrArray{param.Items.Type}
+		items.Format = param.Items.Format
+		items.Enum = param.Items.Enum
+		schema.Items = &spec.SchemaOrArray{Schema: items}
+	}
+
+	return schema
+}
+
+// swaggerParamExample returns example of a non-body parameter, that is defined by
+// 'x-example' extension, its default value or its schema
+func swaggerParamExample(param spec.Parameter, models map[string]spec.Schema) interface{} {
+	if example, ok := param.Extensions["x-example"]; ok {
+		return example
+	}
+
+	return schemaExample(swaggerParamSchema(param), models)
+}
+
+type openAPIDocument struct {
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components struct {
+		Schemas       map[string]spec.Schema         `json:"schemas"`
+		Parameters    map[string]openAPIParameter    `json:"parameters"`
+		RequestBodies map[string]openAPIRequestBody  `json:"requestBodies"`
+		Responses     map[string]openAPIResponse     `json:"responses"`
+		Examples      map[string]openAPIExampleValue `json:"examples"`
+	} `json:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation  `json:"get"`
+	Put        *openAPIOperation  `json:"put"`
+	Post       *openAPIOperation  `json:"post"`
+	Delete     *openAPIOperation  `json:"delete"`
+	Options    *openAPIOperation  `json:"options"`
+	Head       *openAPIOperation  `json:"head"`
+	Patch      *openAPIOperation  `json:"patch"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	Parameters  []openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody        `json:"requestBody"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref         string                         `json:"$ref"`
+	Name        string                         `json:"name"`
+	In          string                         `json:"in"`
+	Description string                         `json:"description"`
+	Required    bool                           `json:"required"`
+	Schema      *spec.Schema                   `json:"schema"`
+	Example     interface{}                    `json:"example"`
+	Examples    map[string]openAPIExampleValue `json:"examples"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string                      `json:"$ref"`
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref         string                      `json:"$ref"`
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema   *spec.Schema                   `json:"schema"`
+	Example  interface{}                    `json:"example"`
+	Examples map[string]openAPIExampleValue `json:"examples"`
+}
+
+type openAPIExampleValue struct {
+	Ref   string      `json:"$ref"`
+	Value interface{} `json:"value"`
+}
+
+func loadOpenAPI(js []byte) (*api, error) {
+	doc := openAPIDocument{}
+	if err := json.Unmarshal(js, &doc); err != nil {
+		return nil, err
+	}
+
+	a := &api{models: map[string]spec.Schema{}}
+	for name, schema := range doc.Components.Schemas {
+		a.models[name] = schema
+	}
+
+	for path, pathItem := range doc.Paths {
+		for _, method := range methods {
+			op := openAPIOperationOf(pathItem, method)
+			if op == nil {
+				continue
+			}
+
+			o := operation{
+				id:          op.OperationID,
+				method:      method,
+				path:        path,
+				description: firstNonEmpty(op.Summary, op.Description),
+			}
+
+			for _, param := range append(append([]openAPIParameter{}, pathItem.Parameters...), op.Parameters...) {
+				if param.Ref != "" {
+					resolved, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, openAPIParametersRefPrefix)]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: could not resolve reference '%s'", method, path, param.Ref)
+					}
+					param = resolved
+				}
+				if param.In == "cookie" {
+					continue
+				}
+
+				example, ok := doc.example(param.Example, param.Examples)
+				if !ok {
+					example = schemaExample(param.Schema, a.models)
+				}
+				o.params = append(o.params, parameter{
+					name:        param.Name,
+					in:          param.In,
+					description: param.Description,
+					required:    param.Required,
+					schema:      param.Schema,
+					example:     example,
+				})
+			}
+
+			if requestBody := op.RequestBody; requestBody != nil {
+				if requestBody.Ref != "" {
+					resolved, ok := doc.Components.RequestBodies[strings.TrimPrefix(requestBody.Ref, openAPIRequestBodyRefPrefix)]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: could not resolve reference '%s'", method, path, requestBody.Ref)
+					}
+					requestBody = &resolved
+				}
+				o.body = doc.body(requestBody.Content, a.models)
+			}
+
+			for status, resp := range op.Responses {
+				code, err := strconv.Atoi(status)
+				if err != nil { // 'default' and ranges like '5XX' do not define a status code
+					continue
+				}
+				if resp.Ref != "" {
+					resolved, ok := doc.Components.Responses[strings.TrimPrefix(resp.Ref, openAPIResponsesRefPrefix)]
+					if !ok {
+						return nil, fmt.Errorf("%s %s: could not resolve reference '%s'", method, path, resp.Ref)
+					}
+					resp = resolved
+				}
+
+				o.responses = append(o.responses, response{
+					code:        code,
+					description: resp.Description,
+					body:        doc.body(resp.Content, a.models),
+				})
+			}
+
+			a.addOperation(o)
+		}
+	}
+	a.sortOperations()
+
+	return a, nil
+}
+
+func openAPIOperationOf(pathItem openAPIPathItem, method string) *openAPIOperation {
+	switch method {
+	case "GET":
+		return pathItem.Get
+	case "PUT":
+		return pathItem.Put
+	case "POST":
+		return pathItem.Post
+	case "DELETE":
+		return pathItem.Delete
+	case "OPTIONS":
+		return pathItem.Options
+	case "HEAD":
+		return pathItem.Head
+	case "PATCH":
+		return pathItem.Patch
+	}
+
+	return nil
+}
+
+// body picks a body of JSON media type or the first one of the content
+func (doc *openAPIDocument) body(content map[string]openAPIMediaType, models map[string]spec.Schema) *body {
+	if len(content) == 0 {
+		return nil
+	}
+
+	mediaType := defaultMediaType
+	if _, ok := content[mediaType]; !ok {
+		mediaTypes := []string{}
+		for mediaType := range content {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+		mediaType = mediaTypes[0]
+	}
+
+	media := content[mediaType]
+	example, ok := doc.example(media.Example, media.Examples)
+	if !ok {
+		example = schemaExample(media.Schema, models)
+	}
+
+	return &body{mediaType: mediaType, schema: media.Schema, example: example, documented: ok}
+}
+
+// example returns the example or the first of named examples
+func (doc *openAPIDocument) example(example interface{}, examples map[string]openAPIExampleValue) (interface{}, bool) {
+	if example != nil {
+		return example, true
+	}
+
+	names := []string{}
+	for name := range examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := examples[name]
+		if value.Ref != "" {
+			value = doc.Components.Examples[strings.TrimPrefix(value.Ref, "#/components/examples/")]
+		}
+		if value.Value != nil {
+			return value.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+func (a *api) addOperation(o operation) {
+	sort.Sort(byCode(o.responses))
+	a.operations = append(a.operations, o)
+}
+
+func (a *api) sortOperations() {
+	sort.Sort(byPathAndMethod(a.operations))
+}
+
+type byCode []response
+
+func (r byCode) Len() int           { return len(r) }
+func (r byCode) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byCode) Less(i, j int) bool { return r[i].code < r[j].code }
+
+// byPathAndMethod sorts operations by path, operations of a path go in order of methods
+type byPathAndMethod []operation
+
+func (o byPathAndMethod) Len() int      { return len(o) }
+func (o byPathAndMethod) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o byPathAndMethod) Less(i, j int) bool {
+	if o[i].path != o[j].path {
+		return o[i].path < o[j].path
+	}
+	return methodIndex(o[i].method) < methodIndex(o[j].method)
+}
+
+func methodIndex(method string) int {
+	for i, m := range methods {
+		if m == method {
+			return i
+		}
+	}
+	return len(methods)
+}
+
+// schemaExample builds an example of a value described by the schema: its
+// example, default or first allowed value, or a value of its type
+func schemaExample(schema *spec.Schema, models map[string]spec.Schema) interface{} {
+	return buildSchemaExample(schema, models, map[string]bool{})
+}
+
+func buildSchemaExample(schema *spec.Schema, models map[string]spec.Schema, visited map[string]bool) interface{} {
+	if schema == nil {
+		return nil
+	}
+	if ref := schema.Ref.String(); ref != "" {
+		name := refName(ref)
+		model, ok := models[name]
+		if !ok || visited[name] {
+			return nil
+		}
+
+		visited[name] = true
+		defer delete(visited, name)
+		return buildSchemaExample(&model, models, visited)
+	}
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		return buildSchemaExample(mergeAllOf(schema, models), models, visited)
+	}
+
+	switch schemaType(schema) {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			return "2016-01-02T15:04:05Z"
+		case "date":
+			return "2016-01-02"
+		}
+		return "string"
+	case "integer", "number":
+		return float64(0)
+	case "boolean":
+		return false
+	case "array":
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return []interface{}{}
+		}
+		item := buildSchemaExample(schema.Items.Schema, models, visited)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "object":
+		example := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			if value := buildSchemaExample(&property, models, visited); value != nil {
+				example[name] = value
+			}
+		}
+		return example
+	}
+
+	return nil
+}
+
+// schemaType returns the first non-null type of the schema. Schema with
+// properties is an object even if type is not defined
+func schemaType(schema *spec.Schema) string {
+	for _, t := range schema.Type {
+		if t != "null" {
+			return t
+		}
+	}
+	if len(schema.Properties) > 0 {
+		return "object"
+	}
+
+	return ""
+}
+
+// mergeAllOf merges properties of all schemas of 'allOf' into one object schema
+func mergeAllOf(schema *spec.Schema, models map[string]spec.Schema) *spec.Schema {
+	merged := &spec.Schema{}
+	merged.Type = spec.StringOrArray{"object"}
+	merged.Properties = map[string]spec.Schema{}
+	merged.Description = schema.Description
+
+	parts := append([]spec.Schema{*schema}, schema.AllOf...)
+	for i, part := range parts {
+		if i > 0 && part.Ref.String() != "" {
+			model, ok := models[refName(part.Ref.String())]
+			if !ok {
+				continue
+			}
+			part = model
+		}
+		if i > 0 && len(part.AllOf) > 0 {
+			part = *mergeAllOf(&part, models)
+		}
+
+		for name, property := range part.Properties {
+			merged.Properties[name] = property
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+
+	return merged
+}
+
+func refName(ref string) string {
+	for _, prefix := range []string{swaggerDefinitionsRefPrefix, openAPISchemasRefPrefix} {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix)
+		}
+	}
+
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
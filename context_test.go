@@ -0,0 +1,133 @@
+package schreder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hangingTest struct {
+	setUpDeadline bool
+	tearDownErr   error
+}
+
+func (t *hangingTest) Method() string      { return "GET" }
+func (t *hangingTest) Description() string { return "Test of a hanging API" }
+func (t *hangingTest) Path() string        { return "/slow" }
+func (t *hangingTest) TestCases() []TestCase {
+	return []TestCase{
+		{Description: "hanging response", ExpectedHttpCode: 200},
+	}
+}
+func (t *hangingTest) SetUpContext(ctx context.Context) error {
+	_, t.setUpDeadline = ctx.Deadline()
+	return nil
+}
+func (t *hangingTest) TearDownContext(ctx context.Context) error {
+	t.tearDownErr = ctx.Err()
+	fmt.Printf("hanging test is torn down, context error: %v\n", t.tearDownErr)
+	return nil
+}
+
+func newHangingServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+func TestRunPassesContextToTest(t *testing.T) {
+	server := newHangingServer(0)
+	defer server.Close()
+
+	test := &hangingTest{}
+	runner := NewRunner(server.URL, RunnerConfig{RequestTimeout: time.Second, TestTimeout: time.Minute})
+	runner.Run(t, test)
+
+	assert.True(t, test.setUpDeadline, "SetUpContext is limited by the timeout of the test")
+	assert.NoError(t, test.tearDownErr)
+}
+
+func TestContextError(t *testing.T) {
+	runner := NewRunner("http://testapi.my", RunnerConfig{RequestTimeout: time.Second, TestTimeout: time.Minute})
+	err := errors.New("connection refused")
+
+	newRequest := func(ctx context.Context) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://testapi.my/slow", nil)
+		return req
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	active := newRunContext(context.Background(), context.Background(), runner)
+
+	assert.Equal(t, err, runner.contextError(err, newRequest(context.Background()), context.Background(), active))
+	assert.EqualError(t, runner.contextError(err, newRequest(expired), context.Background(), active),
+		"request GET http://testapi.my/slow timed out after 1s")
+	assert.EqualError(t, runner.contextError(err, newRequest(expired), expired, active),
+		"test timed out after 1m0s while waiting for GET http://testapi.my/slow")
+	assert.EqualError(t, runner.contextError(err, newRequest(canceled), canceled, newRunContext(canceled, canceled, runner)),
+		"run canceled while waiting for GET http://testapi.my/slow")
+	assert.EqualError(t, runner.contextError(err, newRequest(expired), expired, newRunContext(expired, expired, runner)),
+		"deadline of the run exceeded while waiting for GET http://testapi.my/slow")
+}
+
+func TestCleanupTime(t *testing.T) {
+	assert.Equal(t, 5*time.Second, cleanupTime(time.Now().Add(10*time.Minute)))
+	assert.InDelta(t, float64(time.Second), float64(cleanupTime(time.Now().Add(10*time.Second))), float64(10*time.Millisecond))
+}
+
+// TestRunTimesOutRequest runs itself in a separate process, because the test
+// it runs fails
+func TestRunTimesOutRequest(t *testing.T) {
+	if os.Getenv("SCHREDER_PANICKING_TEST") == "1" {
+		server := newHangingServer(time.Minute)
+		defer server.Close()
+
+		runner := NewRunner(server.URL, RunnerConfig{RequestTimeout: 50 * time.Millisecond})
+		runner.Run(t, &hangingTest{})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunTimesOutRequest$")
+	cmd.Env = append(os.Environ(), "SCHREDER_PANICKING_TEST=1")
+	out, err := cmd.CombinedOutput()
+
+	assert.Error(t, err, "test fails")
+	assert.Regexp(t, `request GET http://127\.0\.0\.1:\d+/slow timed out after 50ms`, string(out))
+	assert.Contains(t, string(out), "hanging test is torn down, context error: <nil>")
+}
+
+// TestRunWithCanceledContext runs itself in a separate process, because
+// the test it runs fails
+func TestRunWithCanceledContext(t *testing.T) {
+	if os.Getenv("SCHREDER_PANICKING_TEST") == "1" {
+		server := newHangingServer(time.Minute)
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		runner := NewRunner(server.URL, RunnerConfig{})
+		runner.RunWithContext(ctx, t, &hangingTest{})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunWithCanceledContext$")
+	cmd.Env = append(os.Environ(), "SCHREDER_PANICKING_TEST=1")
+	out, err := cmd.CombinedOutput()
+
+	assert.Error(t, err, "test fails")
+	assert.Regexp(t, `run canceled while waiting for GET http://127\.0\.0\.1:\d+/slow`, string(out))
+	assert.Contains(t, string(out), "hanging test is torn down, context error: context canceled")
+}
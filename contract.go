@@ -0,0 +1,381 @@
+package schreder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/loads/fmts"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+	"github.com/testmeifyoucan/schreder/internal/jsonvalue"
+)
+
+// Contract is an API contract defined by Swagger 2.0 spec, e.g. a spec published
+// by another team. Tests can be checked against the contract to catch drift
+// between them and the API they are supposed to cover.
+type Contract struct {
+	// swagger is the spec with expanded references
+	swagger *spec.Swagger
+}
+
+// LoadContract loads a contract from Swagger 2.0 spec file in JSON or YAML format.
+// path may be a URL as well
+func LoadContract(path string) (*Contract, error) {
+	var raw json.RawMessage
+	var err error
+	if fmts.YAMLMatcher(path) {
+		raw, err = fmts.YAMLDoc(path)
+	} else {
+		raw, err = loads.JSONDoc(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load contract '%s': %s", path, err.Error())
+	}
+
+	doc, err := loads.Analyzed(raw, "")
+	if err == nil {
+		doc, err = doc.Expanded()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load contract '%s': %s", path, err.Error())
+	}
+
+	return newContract(doc.Spec()), nil
+}
+
+// NewContract creates a contract defined by given spec.
+// References of the spec are expanded in a copy, the spec is not changed
+func NewContract(swagger *spec.Swagger) (*Contract, error) {
+	js, err := json.Marshal(swagger)
+	if err != nil {
+		return nil, err
+	}
+	expanded := &spec.Swagger{}
+	if err := json.Unmarshal(js, expanded); err != nil {
+		return nil, err
+	}
+	if err := spec.ExpandSpec(expanded, nil); err != nil {
+		return nil, fmt.Errorf("could not expand contract: %s", err.Error())
+	}
+
+	return newContract(expanded), nil
+}
+
+func newContract(swagger *spec.Swagger) *Contract {
+	allowSpecNulls(swagger)
+
+	return &Contract{
+		swagger: swagger,
+	}
+}
+
+// Check checks that the test uses only paths, methods, parameters and status codes
+// declared by the contract. Returns descriptions of found violations.
+// Bodies are not checked here, runner validates them against the contract
+// when the test is run with the contract.
+func (c *Contract) Check(test Test) []string {
+	path := normalizeTestPath(test.Path())
+	pathItem, op := c.operation(test.Method(), path)
+	if pathItem == nil {
+		return []string{fmt.Sprintf("path '%s' is not declared", path)}
+	}
+	if op == nil {
+		return []string{fmt.Sprintf("method %s is not declared for path '%s'", test.Method(), path)}
+	}
+
+	params := c.parameters(pathItem, op)
+	hasBody := false
+	for _, param := range params {
+		if param.In == "body" || param.In == "formData" {
+			hasBody = true
+		}
+	}
+
+	violations := []string{}
+	for caseIndex, testCase := range test.TestCases() {
+		prefix := fmt.Sprintf("case '%s': ", testCaseName(testCase, caseIndex))
+
+		for _, name := range sortedParamNames(testCase.PathParams) {
+			if findParameter(params, "path", name) == nil {
+				violations = append(violations, prefix+fmt.Sprintf("path parameter '%s' is not declared", name))
+			}
+		}
+		for _, name := range sortedParamNames(testCase.QueryParams) {
+			if findParameter(params, "query", name) == nil {
+				violations = append(violations, prefix+fmt.Sprintf("query parameter '%s' is not declared", name))
+			}
+		}
+		for _, name := range sortedParamNames(testCase.Headers) {
+			// content negotiation and authorization are not described by parameters in Swagger
+			if findParameter(params, "header", name) == nil && !isOpenAPIReservedHeader(name) {
+				violations = append(violations, prefix+fmt.Sprintf("header '%s' is not declared", name))
+			}
+		}
+
+		if testCase.RequestBody != nil && !hasBody {
+			violations = append(violations, prefix+"request body is not declared")
+		}
+
+		if _, err := c.response(op, testCase.ExpectedHttpCode); err != nil {
+			violations = append(violations, prefix+err.Error())
+		}
+	}
+
+	return violations
+}
+
+// validateRequestBody validates request body of the test case against the schema
+// of body parameter of the operation
+func (c *Contract) validateRequestBody(method, path string, body interface{}) ([]string, error) {
+	switch body.(type) {
+	case []byte, io.Reader: // raw bodies are not validated
+		return nil, nil
+	}
+
+	pathItem, op := c.operation(method, normalizeTestPath(path))
+	if op == nil {
+		return nil, nil
+	}
+
+	for _, param := range c.parameters(pathItem, op) {
+		if param.In != "body" || param.Schema == nil {
+			continue
+		}
+
+		value, err := objToJsonValue(body)
+		if err != nil {
+			return nil, err
+		}
+		return validateAgainstSchema(param.Schema, value)
+	}
+
+	return nil, nil
+}
+
+// validateResponseBody validates response body against the schema of
+// the response that is declared by the operation for given status code
+func (c *Contract) validateResponseBody(method, path string, code int, responseBody []byte) ([]string, error) {
+	_, op := c.operation(method, normalizeTestPath(path))
+	if op == nil {
+		return nil, nil
+	}
+
+	response, err := c.response(op, code)
+	if err != nil || response.Schema == nil {
+		return nil, err
+	}
+
+	return validateAgainstSchema(response.Schema, decodeJsonBody(responseBody))
+}
+
+// validateAgainstSchema validates a value decoded from JSON against a schema
+// of the contract. Returns violations prefixed with JSON pointers to invalid values
+func validateAgainstSchema(schema *spec.Schema, value interface{}) (violations []string, err error) {
+	defer func() {
+		// validator panics on references that can't be resolved
+		if r := recover(); r != nil {
+			violations, err = nil, fmt.Errorf("could not validate against schema: %v", r)
+		}
+	}()
+
+	validationErr := validate.AgainstSchema(schema, value, strfmt.Default)
+	if validationErr == nil {
+		return nil, nil
+	}
+
+	violations = flattenValidationErrors(validationErr)
+	sort.Strings(violations)
+
+	return violations, nil
+}
+
+// flattenValidationErrors describes errors of go-openapi/validate the same way
+// violations of schemas of expected data are described: a JSON pointer to
+// invalid value followed by the message without the name of the value
+func flattenValidationErrors(err error) []string {
+	switch err := err.(type) {
+	case *errors.CompositeError:
+		result := []string{}
+		for _, e := range err.Errors {
+			result = append(result, flattenValidationErrors(e)...)
+		}
+		return result
+	case *errors.Validation:
+		path := "/"
+		if err.Name != "" && err.Name != "." {
+			tokens := strings.Split(err.Name, ".")
+			for i, token := range tokens {
+				tokens[i] = jsonvalue.EscapeToken(token)
+			}
+			path += strings.Join(tokens, "/")
+		}
+		message := strings.TrimPrefix(err.Error(), err.Name+" in "+err.In+" ")
+		return []string{path + ": " + message}
+	}
+
+	return []string{"/: " + err.Error()}
+}
+
+// allowSpecNulls adds null type to schemas of the spec that have x-nullable
+// extension, which is not supported by the validator
+func allowSpecNulls(swagger *spec.Swagger) {
+	for name, def := range swagger.Definitions {
+		allowNulls(&def)
+		swagger.Definitions[name] = def
+	}
+	for name, param := range swagger.Parameters {
+		allowNulls(param.Schema)
+		swagger.Parameters[name] = param
+	}
+	for name, response := range swagger.Responses {
+		allowNulls(response.Schema)
+		swagger.Responses[name] = response
+	}
+	if swagger.Paths == nil {
+		return
+	}
+
+	for _, pathItem := range swagger.Paths.Paths {
+		for _, param := range pathItem.Parameters {
+			allowNulls(param.Schema)
+		}
+
+		ops := []*spec.Operation{pathItem.Get, pathItem.Post, pathItem.Put, pathItem.Patch,
+			pathItem.Delete, pathItem.Head, pathItem.Options}
+		for _, op := range ops {
+			if op == nil {
+				continue
+			}
+			for _, param := range op.Parameters {
+				allowNulls(param.Schema)
+			}
+			if op.Responses == nil {
+				continue
+			}
+			if op.Responses.Default != nil {
+				allowNulls(op.Responses.Default.Schema)
+			}
+			for _, response := range op.Responses.StatusCodeResponses {
+				allowNulls(response.Schema)
+			}
+		}
+	}
+}
+
+func allowNulls(schema *spec.Schema) {
+	if schema == nil {
+		return
+	}
+
+	if nullable, _ := schema.Extensions.GetBool("x-nullable"); nullable && len(schema.Type) > 0 && !schema.Type.Contains("null") {
+		schema.Type = append(schema.Type, "null")
+	}
+
+	for name, property := range schema.Properties {
+		allowNulls(&property)
+		schema.Properties[name] = property
+	}
+	for pattern, property := range schema.PatternProperties {
+		allowNulls(&property)
+		schema.PatternProperties[pattern] = property
+	}
+	if schema.AdditionalProperties != nil {
+		allowNulls(schema.AdditionalProperties.Schema)
+	}
+	if schema.Items != nil {
+		allowNulls(schema.Items.Schema)
+		for i := range schema.Items.Schemas {
+			allowNulls(&schema.Items.Schemas[i])
+		}
+	}
+	for i := range schema.AllOf {
+		allowNulls(&schema.AllOf[i])
+	}
+}
+
+func (c *Contract) operation(method, path string) (*spec.PathItem, *spec.Operation) {
+	if c.swagger.Paths == nil {
+		return nil, nil
+	}
+	pathItem, ok := c.swagger.Paths.Paths[path]
+	if !ok {
+		return nil, nil
+	}
+
+	var op *spec.Operation
+	switch strings.ToUpper(method) {
+	case "GET":
+		op = pathItem.Get
+	case "POST":
+		op = pathItem.Post
+	case "PUT":
+		op = pathItem.Put
+	case "PATCH":
+		op = pathItem.Patch
+	case "DELETE":
+		op = pathItem.Delete
+	case "HEAD":
+		op = pathItem.Head
+	case "OPTIONS":
+		op = pathItem.Options
+	}
+
+	return &pathItem, op
+}
+
+// parameters returns parameters of the path and the operation
+func (c *Contract) parameters(pathItem *spec.PathItem, op *spec.Operation) []spec.Parameter {
+	return append(append([]spec.Parameter{}, pathItem.Parameters...), op.Parameters...)
+}
+
+// response returns the response declared by the operation for given status code
+func (c *Contract) response(op *spec.Operation, code int) (*spec.Response, error) {
+	var response *spec.Response
+	if op.Responses != nil {
+		if r, ok := op.Responses.StatusCodeResponses[code]; ok {
+			response = &r
+		} else if op.Responses.Default != nil {
+			response = op.Responses.Default
+		}
+	}
+	if response == nil {
+		return nil, fmt.Errorf("status code %d is not declared", code)
+	}
+
+	return response, nil
+}
+
+func findParameter(params []spec.Parameter, in, name string) *spec.Parameter {
+	for i, param := range params {
+		// header names are case insensitive
+		if param.In == in && (param.Name == name || in == "header" && strings.EqualFold(param.Name, name)) {
+			return &params[i]
+		}
+	}
+
+	return nil
+}
+
+func sortedParamNames(params ParamMap) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func normalizeTestPath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+
+	return path
+}
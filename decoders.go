@@ -0,0 +1,232 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// IResponseDecoder defines an interface of decoder of response bodies
+type IResponseDecoder interface {
+	// Decode decodes body into generic representation (maps, slices
+	// and primitives) that is compared to expected data
+	Decode(body []byte) (interface{}, error)
+}
+
+// IResponseDecoderFunc implements IResponseDecoder in a functional way
+type IResponseDecoderFunc func(body []byte) (interface{}, error)
+
+func (f IResponseDecoderFunc) Decode(body []byte) (interface{}, error) { return f(body) }
+
+// defaultResponseDecoders are used when runner config does not override them
+var defaultResponseDecoders = map[string]IResponseDecoder{
+	"application/json":                  IResponseDecoderFunc(decodeJSON),
+	"application/xml":                   IResponseDecoderFunc(decodeXML),
+	"text/xml":                          IResponseDecoderFunc(decodeXML),
+	"application/x-www-form-urlencoded": IResponseDecoderFunc(decodeForm),
+	"application/x-yaml":                IResponseDecoderFunc(decodeYAML),
+	"application/yaml":                  IResponseDecoderFunc(decodeYAML),
+	"text/yaml":                         IResponseDecoderFunc(decodeYAML),
+	"text/plain":                        IResponseDecoderFunc(decodeText),
+}
+
+// findResponseDecoder looks for a decoder of given media type. Media types
+// with structured syntax suffix, like 'application/hal+json', fall back to
+// decoder of the suffix
+func findResponseDecoder(decoders map[string]IResponseDecoder, mediaType string) (IResponseDecoder, bool) {
+	mediaType = strings.ToLower(mediaType)
+	if decoder, ok := decoders[mediaType]; ok {
+		return decoder, true
+	}
+
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		decoder, ok := decoders["application/json"]
+		return decoder, ok
+	case strings.HasSuffix(mediaType, "+xml"):
+		decoder, ok := decoders["application/xml"]
+		return decoder, ok
+	case strings.HasSuffix(mediaType, "+yaml"):
+		decoder, ok := decoders["application/x-yaml"]
+		return decoder, ok
+	}
+
+	return nil, false
+}
+
+// responseMediaType returns media type of the response body. Responses with
+// no content type are treated as JSON
+func responseMediaType(contentType string) string {
+	if contentType == "" {
+		return defaultMediaType
+	}
+
+	return parseMediaType(contentType)
+}
+
+// decodeResponse processes response data into representation used for comparison
+// to expected data. Body that can't be decoded is compared as a string
+func decodeResponse(data []byte, mediaType string, decoders map[string]IResponseDecoder) interface{} {
+	decoder, ok := findResponseDecoder(decoders, mediaType)
+	if !ok {
+		return string(data)
+	}
+
+	decoded, err := decoder.Decode(data)
+	if err != nil {
+		return string(data)
+	}
+
+	return decoded
+}
+
+// decodeExpected processes expected data into representation used for comparison
+// to actual data. Expected data is encoded the same way as a request body of
+// the media type and decoded back, so both sides go through the same decoder.
+// Expected data of type []byte is a raw body, it's decoded only.
+func decodeExpected(data interface{}, mediaType string,
+	encoders map[string]IRequestEncoder, decoders map[string]IResponseDecoder) interface{} {
+
+	decoder, hasDecoder := findResponseDecoder(decoders, mediaType)
+	if raw, ok := data.([]byte); ok {
+		if !hasDecoder {
+			return string(raw)
+		}
+		return decodeResponse(raw, mediaType, decoders)
+	}
+
+	encoder, hasEncoder := findRequestEncoder(encoders, mediaType)
+	if hasDecoder && hasEncoder {
+		if encoded, _, err := encoder.Encode(data, mediaType); err == nil {
+			if decoded, err := decoder.Decode(encoded); err == nil {
+				return decoded
+			}
+		}
+	}
+
+	if s, ok := data.(string); ok {
+		return s
+	}
+	if decoded, err := objToJsonValue(data); err == nil {
+		return decoded
+	}
+
+	return data
+}
+
+func decodeJSON(body []byte) (interface{}, error) {
+	var value interface{}
+	err := json.Unmarshal(body, &value)
+	return value, err
+}
+
+func decodeYAML(body []byte) (interface{}, error) {
+	js, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSON(js)
+}
+
+func decodeText(body []byte) (interface{}, error) {
+	return string(body), nil
+}
+
+// decodeForm decodes URL encoded form into a map of fields. Fields with
+// several values are decoded as arrays
+func decodeForm(body []byte) (interface{}, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	for name, fieldValues := range values {
+		if len(fieldValues) == 1 {
+			fields[name] = fieldValues[0]
+			continue
+		}
+
+		items := make([]interface{}, len(fieldValues))
+		for i, value := range fieldValues {
+			items[i] = value
+		}
+		fields[name] = items
+	}
+
+	return fields, nil
+}
+
+// decodeXML decodes XML document into a map with the root element. Elements
+// that contain text only are decoded as strings, others as maps of child
+// elements. Repeated elements are collected into arrays, attributes are
+// prefixed with '@' and text of mixed elements is kept as '#text'
+func decodeXML(body []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	fields := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		fields["@"+attr.Name.Local] = attr.Value
+	}
+
+	repeated := map[string]bool{}
+	text := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder, token)
+			if err != nil {
+				return nil, err
+			}
+
+			name := token.Name.Local
+			existing, ok := fields[name]
+			switch {
+			case !ok:
+				fields[name] = value
+			case repeated[name]:
+				fields[name] = append(existing.([]interface{}), value)
+			default:
+				fields[name] = []interface{}{existing, value}
+				repeated[name] = true
+			}
+		case xml.CharData:
+			text += string(token)
+		case xml.EndElement:
+			text = strings.TrimSpace(text)
+			if len(fields) == 0 {
+				return text, nil
+			}
+			if text != "" {
+				fields["#text"] = text
+			}
+			return fields, nil
+		}
+	}
+}
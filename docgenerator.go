@@ -1,5 +1,18 @@
 package schreder
 
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/jsonschema"
+
+	"github.com/testmeifyoucan/schreder/match"
+)
+
 // ITaggable is an interface that can tell doc generator
 // that some test provides a tag. Usable for swagger documentation
 // where tags help to group API endpoints
@@ -12,3 +25,441 @@ type ITaggable interface {
 type IDocGenerator interface {
 	Generate(tests []Test) ([]byte, error)
 }
+
+// ConflictPolicy defines how a doc generator handles several tests
+// that describe the same path and method
+type ConflictPolicy int
+
+const (
+	// ConflictError makes generator fail with an error that names conflicting tests.
+	// Generators use this policy by default
+	ConflictError ConflictPolicy = iota
+	// ConflictMerge merges test cases of conflicting tests into one operation
+	ConflictMerge
+	// ConflictWarn writes a warning, the last of conflicting tests is documented
+	ConflictWarn
+)
+
+type conflictResolvingGenerator struct {
+	generator IDocGenerator
+	policy    ConflictPolicy
+	warnings  io.Writer
+}
+
+// WithConflictPolicy wraps given generator, so conflicting tests are resolved
+// according to the policy before documentation is generated. Warnings of
+// ConflictWarn policy are written to warnings, they are discarded if it's nil
+func WithConflictPolicy(generator IDocGenerator, policy ConflictPolicy, warnings io.Writer) IDocGenerator {
+	return &conflictResolvingGenerator{
+		generator: generator,
+		policy:    policy,
+		warnings:  warnings,
+	}
+}
+
+// Generate implements IDocGenerator
+func (g *conflictResolvingGenerator) Generate(tests []Test) ([]byte, error) {
+	resolved, err := resolveTestConflicts(tests, g.policy, g.warnings)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.generator.Generate(resolved)
+}
+
+// withSecurity implements securedGenerator
+func (g *conflictResolvingGenerator) withSecurity(schemes []SecurityScheme) IDocGenerator {
+	return &conflictResolvingGenerator{
+		generator: WithSecurity(g.generator, schemes...),
+		policy:    g.policy,
+		warnings:  g.warnings,
+	}
+}
+
+// withRedaction implements redactingGenerator
+func (g *conflictResolvingGenerator) withRedaction(redaction Redaction) IDocGenerator {
+	return &conflictResolvingGenerator{
+		generator: WithRedaction(g.generator, redaction),
+		policy:    g.policy,
+		warnings:  g.warnings,
+	}
+}
+
+// withServer implements serverGenerator
+func (g *conflictResolvingGenerator) withServer(base *url.URL) IDocGenerator {
+	served := g.generator
+	if server, ok := g.generator.(serverGenerator); ok {
+		served = server.withServer(base)
+	}
+
+	return &conflictResolvingGenerator{
+		generator: served,
+		policy:    g.policy,
+		warnings:  g.warnings,
+	}
+}
+
+// securedGenerator is implemented by generators that can document security schemes
+type securedGenerator interface {
+	withSecurity(schemes []SecurityScheme) IDocGenerator
+}
+
+// WithSecurity returns a copy of given generator that documents security schemes
+// and makes operations require them, unless their tests are Secured with schemes
+// of their own. Generators that can not document security schemes are returned as is
+func WithSecurity(generator IDocGenerator, schemes ...SecurityScheme) IDocGenerator {
+	if secured, ok := generator.(securedGenerator); ok {
+		return secured.withSecurity(schemes)
+	}
+
+	return generator
+}
+
+// WithAuthenticator returns a copy of given generator that documents the security
+// scheme of the authenticator, the one used by the runner to authenticate requests.
+// Authenticators that do not implement ISecurityDescriber are not documented
+func WithAuthenticator(generator IDocGenerator, authenticator IAuthenticator) IDocGenerator {
+	describer, ok := authenticator.(ISecurityDescriber)
+	if !ok {
+		return generator
+	}
+
+	return WithSecurity(generator, describer.SecurityScheme())
+}
+
+// redactingGenerator is implemented by generators that can leave secrets out
+type redactingGenerator interface {
+	withRedaction(redaction Redaction) IDocGenerator
+}
+
+// WithRedaction returns a copy of given generator that leaves secrets of the
+// redaction out of documentation: headers are documented with no defaults,
+// values of bodies at JSON paths are replaced with Redacted in examples.
+// Headers of DefaultRedaction and parameters with Secret flag are never
+// documented with their values, even with no redaction. Generators that can
+// not redact documentation are returned as is
+func WithRedaction(generator IDocGenerator, redaction Redaction) IDocGenerator {
+	if redacting, ok := generator.(redactingGenerator); ok {
+		return redacting.withRedaction(redaction)
+	}
+
+	return generator
+}
+
+// testSecurity returns security schemes required by the test: its own ones
+// if it declares them, given schemes of the generator otherwise
+func testSecurity(test Test, defaults []SecurityScheme) []SecurityScheme {
+	if secured, ok := test.(Secured); ok {
+		if schemes := secured.SecuritySchemes(); len(schemes) > 0 {
+			return schemes
+		}
+	}
+
+	return defaults
+}
+
+// collectSecuritySchemes lists given schemes and schemes of all tests,
+// a scheme is listed once, the first of the same name wins
+func collectSecuritySchemes(tests []Test, defaults []SecurityScheme) []SecurityScheme {
+	schemes := []SecurityScheme{}
+	seen := map[string]bool{}
+	add := func(list []SecurityScheme) {
+		for _, scheme := range list {
+			if !seen[scheme.Name] {
+				seen[scheme.Name] = true
+				schemes = append(schemes, scheme)
+			}
+		}
+	}
+
+	add(defaults)
+	for _, test := range tests {
+		if secured, ok := test.(Secured); ok {
+			add(secured.SecuritySchemes())
+		}
+	}
+
+	return schemes
+}
+
+// isCredentialParam tells whether the parameter carries credentials of one of
+// the schemes. Such parameters are documented by the schemes rather than as
+// parameters of the operation
+func isCredentialParam(schemes []SecurityScheme, name, location string) bool {
+	for _, scheme := range schemes {
+		switch scheme.Type {
+		case "apiKey":
+			if scheme.In != location {
+				continue
+			}
+			if name == scheme.ParamName || (location == "header" && strings.EqualFold(name, scheme.ParamName)) {
+				return true
+			}
+		default:
+			if location == "header" && strings.EqualFold(name, "Authorization") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// oauth2Grant converts Swagger OAuth2 flow into a grant of RAML 0.8 or RAML 1.0
+func oauth2Grant(flow, ramlVersion string) string {
+	grants := map[string][2]string{
+		"application": {"credentials", "client_credentials"},
+		"password":    {"owner", "password"},
+		"implicit":    {"token", "implicit"},
+		"accessCode":  {"code", "authorization_code"},
+	}
+	grant, ok := grants[flow]
+	if !ok {
+		return flow
+	}
+	if ramlVersion == "0.8" {
+		return grant[0]
+	}
+
+	return grant[1]
+}
+
+// resolveTestConflicts finds tests that describe the same path and method
+// and resolves them according to the policy. Order of tests is kept.
+func resolveTestConflicts(tests []Test, policy ConflictPolicy, warnings io.Writer) ([]Test, error) {
+	resolved := []Test{}
+	positions := map[string]int{}
+	for _, test := range tests {
+		path := test.Path()
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		key := strings.ToUpper(test.Method()) + " " + path
+		position, ok := positions[key]
+		if !ok {
+			positions[key] = len(resolved)
+			resolved = append(resolved, test)
+			continue
+		}
+
+		existing := resolved[position]
+		switch policy {
+		case ConflictMerge:
+			resolved[position] = mergeTests(existing, test)
+		case ConflictWarn:
+			if warnings != nil {
+				fmt.Fprintf(warnings, "schreder: tests '%s' and '%s' both describe %s, only '%s' is documented\n",
+					extractTestName(existing), extractTestName(test), key, extractTestName(test))
+			}
+			resolved[position] = test
+		default:
+			return nil, fmt.Errorf("tests '%s' and '%s' both describe %s",
+				extractTestName(existing), extractTestName(test), key)
+		}
+	}
+
+	return resolved, nil
+}
+
+// mergedTest is a test that joins test cases of several tests describing
+// the same path and method. Meta data is provided by the first test.
+type mergedTest struct {
+	tests []Test
+}
+
+func mergeTests(existing, test Test) Test {
+	merged := &mergedTest{tests: []Test{existing, test}}
+	if m, ok := existing.(*mergedTest); ok {
+		merged.tests = append(append([]Test{}, m.tests...), test)
+	} else if m, ok := existing.(*taggedMergedTest); ok {
+		merged.tests = append(append([]Test{}, m.tests...), test)
+	}
+
+	if taggable, ok := merged.tests[0].(ITaggable); ok {
+		return &taggedMergedTest{mergedTest: merged, tag: taggable.Tag()}
+	}
+
+	return merged
+}
+
+func (t *mergedTest) Method() string      { return t.tests[0].Method() }
+func (t *mergedTest) Description() string { return t.tests[0].Description() }
+func (t *mergedTest) Path() string        { return t.tests[0].Path() }
+func (t *mergedTest) TestCases() []TestCase {
+	testCases := []TestCase{}
+	for _, test := range t.tests {
+		testCases = append(testCases, test.TestCases()...)
+	}
+
+	return testCases
+}
+
+// SecuritySchemes implements Secured, merged test requires schemes of all its tests
+func (t *mergedTest) SecuritySchemes() []SecurityScheme {
+	return collectSecuritySchemes(t.tests, nil)
+}
+
+func (t *mergedTest) Name() string {
+	names := []string{}
+	for _, test := range t.tests {
+		names = append(names, extractTestName(test))
+	}
+
+	return strings.Join(names, "+")
+}
+
+type taggedMergedTest struct {
+	*mergedTest
+	tag string
+}
+
+func (t *taggedMergedTest) Tag() string { return t.tag }
+
+// groupTestCasesByHttpCode groups indexes of test cases by expected HTTP code,
+// so all cases that produce the same code can be documented as one response.
+// Codes are returned in order of their first appearance.
+func groupTestCasesByHttpCode(testCases []TestCase) ([]int, map[int][]int) {
+	codes := []int{}
+	groups := map[int][]int{}
+	for caseIndex, testCase := range testCases {
+		code := testCase.ExpectedHttpCode
+		if _, ok := groups[code]; !ok {
+			codes = append(codes, code)
+		}
+		groups[code] = append(groups[code], caseIndex)
+	}
+
+	return codes, groups
+}
+
+// joinTestCaseDescriptions builds a description of a response that is
+// shared by several test cases
+func joinTestCaseDescriptions(testCases []TestCase, indexes []int) string {
+	descriptions := []string{}
+	seen := map[string]bool{}
+	for _, caseIndex := range indexes {
+		description := testCases[caseIndex].Description
+		if description == "" || seen[description] {
+			continue
+		}
+
+		seen[description] = true
+		descriptions = append(descriptions, description)
+	}
+
+	return strings.Join(descriptions, "\n\n")
+}
+
+// exampleNames names examples of the test cases with given indexes by names
+// of the cases. The number of the case is added to a name shared by several
+// cases, so their examples don't overwrite each other
+func exampleNames(testCases []TestCase, indexes []int) map[int]string {
+	counts := map[string]int{}
+	for _, caseIndex := range indexes {
+		counts[testCaseName(testCases[caseIndex], caseIndex)]++
+	}
+
+	names := map[int]string{}
+	for _, caseIndex := range indexes {
+		name := testCaseName(testCases[caseIndex], caseIndex)
+		if counts[name] > 1 {
+			name = fmt.Sprintf("%s (case %d)", name, caseIndex+1)
+		}
+		names[caseIndex] = name
+	}
+
+	return names
+}
+
+// allTestCaseIndexes returns indexes of all given test cases
+func allTestCaseIndexes(testCases []TestCase) []int {
+	indexes := make([]int, len(testCases))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	return indexes
+}
+
+// reflectJsonSchema reflects JSON schema of given data. Matchers are
+// documented by their examples, matcher with no example allows any value
+func reflectJsonSchema(data interface{}) *jsonschema.Schema {
+	example := match.Example(data)
+	if example == nil {
+		return jsonschema.ReflectFromType(reflect.TypeOf(&example).Elem())
+	}
+
+	return jsonschema.Reflect(example)
+}
+
+// capturedExamples returns examples of variables that test cases capture from
+// JSON bodies of their expected data, by names of the variables
+func capturedExamples(tests []Test) map[string]interface{} {
+	examples := map[string]interface{}{}
+	for _, test := range tests {
+		for _, testCase := range test.TestCases() {
+			var document interface{}
+			decoded := false
+			for _, c := range testCase.Captures {
+				var tokens []string
+				var err error
+				switch {
+				case c.JSONPointer != "":
+					tokens, err = parseJSONPointer(c.JSONPointer)
+				case c.JSONPath != "":
+					tokens, err = parseJSONPath(c.JSONPath)
+				default:
+					continue
+				}
+				if err != nil {
+					continue
+				}
+
+				if !decoded {
+					decoded = true
+					js, ok := testCase.ExpectedData.([]byte)
+					if !ok {
+						if js, err = json.Marshal(match.Example(testCase.ExpectedData)); err != nil {
+							continue
+						}
+					}
+					if err := decodeSuiteJSON(js, &document); err != nil {
+						continue
+					}
+					document = normalizeNumbers(document)
+				}
+
+				if value, err := lookupJSONValue(document, tokens); err == nil {
+					examples[c.Variable] = value
+				}
+			}
+		}
+	}
+
+	return examples
+}
+
+// documentedParam returns the value a parameter is documented with and tells
+// whether it's documented as the default. Secret parameters and the ones that
+// refer variables have no default, the latter are documented with examples of
+// captured variables if they are known
+func documentedParam(param Param, variables map[string]interface{}) (interface{}, bool) {
+	value, ok := param.Value.(string)
+	if !ok {
+		return param.Value, !param.Secret
+	}
+
+	if refersVariables(value) {
+		if reference := variableReference.FindStringSubmatch(value); reference[0] == value {
+			if example, ok := variables[reference[1]]; ok {
+				return example, false
+			}
+		}
+		return value, false
+	}
+
+	// escaped references are documented as literal ones
+	unescaped, _, _ := substitution{lookup: func(string) (interface{}, bool) { return nil, false }}.str(value)
+	return unescaped, !param.Secret
+}
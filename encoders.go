@@ -0,0 +1,330 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// IRequestEncoder defines an interface of encoder of request bodies
+type IRequestEncoder interface {
+	// Encode encodes body into representation of given content type. Returns
+	// encoded body and content type of the request, the latter may differ from
+	// given one, e.g. multipart encoder adds a boundary to it
+	Encode(body interface{}, contentType string) ([]byte, string, error)
+}
+
+// IRequestEncoderFunc implements IRequestEncoder in a functional way
+type IRequestEncoderFunc func(body interface{}, contentType string) ([]byte, string, error)
+
+func (f IRequestEncoderFunc) Encode(body interface{}, contentType string) ([]byte, string, error) {
+	return f(body, contentType)
+}
+
+// File is a file that is sent as a part of multipart/form-data request body
+type File struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// defaultRequestEncoders are used when runner config does not override them
+var defaultRequestEncoders = map[string]IRequestEncoder{
+	"application/json":                  IRequestEncoderFunc(encodeJSON),
+	"application/x-www-form-urlencoded": IRequestEncoderFunc(encodeForm),
+	"multipart/form-data":               IRequestEncoderFunc(encodeMultipart),
+	"application/xml":                   IRequestEncoderFunc(encodeXML),
+	"text/xml":                          IRequestEncoderFunc(encodeXML),
+	"application/x-yaml":                IRequestEncoderFunc(encodeYAML),
+	"application/yaml":                  IRequestEncoderFunc(encodeYAML),
+	"text/yaml":                         IRequestEncoderFunc(encodeYAML),
+	"text/plain":                        IRequestEncoderFunc(encodeText),
+}
+
+// findRequestEncoder looks for an encoder of given media type. Media types
+// with structured syntax suffix, like 'application/hal+json', fall back to
+// encoder of the suffix
+func findRequestEncoder(encoders map[string]IRequestEncoder, mediaType string) (IRequestEncoder, bool) {
+	mediaType = strings.ToLower(mediaType)
+	if encoder, ok := encoders[mediaType]; ok {
+		return encoder, true
+	}
+
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		encoder, ok := encoders["application/json"]
+		return encoder, ok
+	case strings.HasSuffix(mediaType, "+xml"):
+		encoder, ok := encoders["application/xml"]
+		return encoder, ok
+	case strings.HasSuffix(mediaType, "+yaml"):
+		encoder, ok := encoders["application/x-yaml"]
+		return encoder, ok
+	}
+
+	return nil, false
+}
+
+// EncodeBody encodes body with default encoder of given content type, the same
+// way runner encodes request bodies. Returns encoded body and its content type
+func EncodeBody(body interface{}, contentType string) ([]byte, string, error) {
+	return encodeBody(defaultRequestEncoders, body, contentType)
+}
+
+// encodeBody encodes body with the encoder of given content type.
+// Raw bodies are sent as is, body with no content type or with a content type
+// that has no encoder, e.g. 'application/vnd.api.v2', is encoded as JSON.
+// Form bodies can't be encoded as JSON, so their encoders are required
+func encodeBody(encoders map[string]IRequestEncoder, body interface{}, contentType string) ([]byte, string, error) {
+	if raw, ok, err := encodeRawBody(body); ok {
+		return raw, contentType, err
+	}
+
+	mediaType := defaultMediaType
+	if contentType != "" {
+		mediaType = parseMediaType(contentType)
+	}
+
+	encoder, ok := findRequestEncoder(encoders, mediaType)
+	if !ok && !isFormMediaType(mediaType) {
+		encoder, ok = encoders[defaultMediaType]
+	}
+	if !ok {
+		return nil, "", fmt.Errorf("no encoder is registered for media type '%s'", mediaType)
+	}
+
+	return encoder.Encode(body, contentType)
+}
+
+// isFormMediaType reports whether body of given media type consists of form fields
+func isFormMediaType(mediaType string) bool {
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+func encodeJSON(body interface{}, contentType string) ([]byte, string, error) {
+	encoded, err := json.Marshal(body)
+	return encoded, contentType, err
+}
+
+func encodeXML(body interface{}, contentType string) ([]byte, string, error) {
+	encoded, err := xml.Marshal(body)
+	return encoded, contentType, err
+}
+
+func encodeYAML(body interface{}, contentType string) ([]byte, string, error) {
+	encoded, err := yaml.Marshal(body)
+	return encoded, contentType, err
+}
+
+func encodeText(body interface{}, contentType string) ([]byte, string, error) {
+	switch body := body.(type) {
+	case string:
+		return []byte(body), contentType, nil
+	case fmt.Stringer:
+		return []byte(body.String()), contentType, nil
+	}
+
+	return []byte(fmt.Sprintf("%v", body)), contentType, nil
+}
+
+func encodeForm(body interface{}, contentType string) ([]byte, string, error) {
+	fields, err := formFields(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	values := url.Values{}
+	for _, field := range fields {
+		for _, value := range field.values {
+			if _, ok := value.(File); ok {
+				return nil, "", fmt.Errorf("field '%s' contains a file, files can be sent as multipart/form-data only", field.name)
+			}
+			values.Add(field.name, formValueString(value))
+		}
+	}
+
+	return []byte(values.Encode()), contentType, nil
+}
+
+func encodeMultipart(body interface{}, contentType string) ([]byte, string, error) {
+	fields, err := formFields(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buffer := &bytes.Buffer{}
+	writer := multipart.NewWriter(buffer)
+	// boundary defined by the test case is kept
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["boundary"] != "" {
+		if err := writer.SetBoundary(params["boundary"]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, field := range fields {
+		for _, value := range field.values {
+			file, ok := value.(File)
+			if !ok {
+				if err := writer.WriteField(field.name, formValueString(value)); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+
+			fileContentType := file.ContentType
+			if fileContentType == "" {
+				fileContentType = "application/octet-stream"
+			}
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				escapeQuotes(field.name), escapeQuotes(file.Name)))
+			header.Set("Content-Type", fileContentType)
+
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(file.Content); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buffer.Bytes(), writer.FormDataContentType(), nil
+}
+
+// encodeRawBody returns content of the body that is sent as is, with no encoding.
+// Reports whether the body is raw
+func encodeRawBody(body interface{}) ([]byte, bool, error) {
+	switch body := body.(type) {
+	case []byte:
+		return body, true, nil
+	case io.Reader:
+		content, err := ioutil.ReadAll(body)
+		return content, true, err
+	}
+
+	return nil, false, nil
+}
+
+// formField is a field of a form with all its values
+type formField struct {
+	name   string
+	values []interface{}
+}
+
+// formFields splits body into form fields sorted by name. Body may be
+// url.Values, a map or a struct, fields of structs are named after
+// their 'json' tags. Slices are sent as multiple values of the field
+func formFields(body interface{}) ([]formField, error) {
+	fieldsMap := map[string]interface{}{}
+
+	value := reflect.ValueOf(body)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			fieldsMap[fmt.Sprintf("%v", key.Interface())] = value.MapIndex(key).Interface()
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			if tag[0] == "-" {
+				continue
+			}
+			name := field.Name
+			if tag[0] != "" {
+				name = tag[0]
+			}
+
+			fieldValue := value.Field(i)
+			zero := reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(field.Type).Interface())
+			if zero && strings.Contains(field.Tag.Get("json"), ",omitempty") {
+				continue
+			}
+			fieldsMap[name] = fieldValue.Interface()
+		}
+	default:
+		return nil, fmt.Errorf("could not encode %T as form, a map or a struct is expected", body)
+	}
+
+	names := make([]string, 0, len(fieldsMap))
+	for name := range fieldsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]formField, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, formField{name: name, values: formFieldValues(fieldsMap[name])})
+	}
+
+	return fields, nil
+}
+
+func formFieldValues(value interface{}) []interface{} {
+	switch file := value.(type) {
+	case []byte:
+		return []interface{}{value}
+	case *File:
+		return []interface{}{*file}
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+
+	values := make([]interface{}, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+		if file, ok := values[i].(*File); ok {
+			values[i] = *file
+		}
+	}
+	return values
+}
+
+func formValueString(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case encoding.TextMarshaler:
+		if text, err := value.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
@@ -10,9 +10,14 @@ import (
 	"github.com/go-openapi/spec"
 
 	"github.com/testmeifyoucan/schreder"
+	"github.com/testmeifyoucan/schreder/cassette"
 )
 
 var outFile = flag.String("out", "", "where to output swagger yaml doc. Runs only if test is successful")
+var cassetteFile = flag.String("cassette", "", "cassette to replay API traffic from, it's recorded if it does not exist")
+var record = flag.Bool("record", false, "record the cassette again")
+var profilesFile = flag.String("profiles", "profiles.yml", "file of environment profiles")
+var profileName = flag.String("profile", "local", "profile of the API to run tests against")
 
 func TestRunApi(t *testing.T) {
 
@@ -23,7 +28,34 @@ func TestRunApi(t *testing.T) {
 		&DeleteUserTest{},
 	}
 
-	runner := schreder.NewRunner("http://localhost:1323", schreder.RunnerConfig{})
+	profile, err := schreder.SelectProfile(*profilesFile, *profileName)
+	if err != nil {
+		t.Fatalf("could not select profile: %s", err.Error())
+	}
+
+	config := schreder.RunnerConfig{}
+	if *cassetteFile != "" {
+		mode := cassette.ModeAuto
+		if *record {
+			mode = cassette.ModeRecord
+		}
+		recorder, err := cassette.New(*cassetteFile, cassette.Config{Mode: mode})
+		if err != nil {
+			t.Fatalf("could not open cassette: %s", err.Error())
+		}
+		defer func() {
+			if err := recorder.Save(); err != nil {
+				t.Errorf("could not save cassette: %s", err.Error())
+			}
+		}()
+
+		config.HttpClient = recorder
+	}
+
+	runner, err := schreder.NewProfileRunner(profile, config)
+	if err != nil {
+		t.Fatalf("could not create runner: %s", err.Error())
+	}
 	runner.Run(t, tests...)
 
 	if !t.Failed() {
@@ -44,23 +76,24 @@ func TestRunApi(t *testing.T) {
 		} else {
 			writer = os.Stdout
 		}
-		generateSwaggerYAML(t, tests, writer)
+		generateSwaggerYAML(t, tests, profile, writer)
 	}
 }
 
-func generateSwaggerYAML(t *testing.T, tests []schreder.Test, writer io.Writer) {
+func generateSwaggerYAML(t *testing.T, tests []schreder.Test, profile schreder.Profile, writer io.Writer) {
 	seed := spec.Swagger{}
-	seed.Host = "localhost"
 	seed.Produces = []string{"application/json"}
 	seed.Consumes = []string{"application/json"}
-	seed.Schemes = []string{"http"}
 	seed.Info = &spec.Info{}
 	seed.Info.Description = "Example API"
 	seed.Info.Title = "Example API"
 	seed.Info.Version = "0.1"
-	seed.BasePath = "/"
 
-	generator := schreder.NewSwaggerGeneratorYAML(seed)
+	// host, schemes and basePath are taken from the profile
+	generator, err := schreder.WithProfile(schreder.NewSwaggerGeneratorYAML(seed), profile)
+	if err != nil {
+		t.Fatalf("could not generate doc: %s", err.Error())
+	}
 
 	doc, err := generator.Generate(tests)
 	if err != nil {
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/json"
-	"testing"
-
 	"github.com/testmeifyoucan/schreder"
+	"github.com/testmeifyoucan/schreder/match"
 )
 
 type CreateUserTest struct{}
@@ -13,9 +11,6 @@ func (t *CreateUserTest) Method() string      { return "POST" }
 func (t *CreateUserTest) Description() string { return "Test for creating new user API" }
 func (t *CreateUserTest) Path() string        { return "/users" }
 func (t *CreateUserTest) TestCases() []schreder.TestCase {
-	expectedUser := User{
-		Name: "New User",
-	}
 	return []schreder.TestCase{
 		{
 			Description:      "User created successfully",
@@ -28,24 +23,18 @@ func (t *CreateUserTest) TestCases() []schreder.TestCase {
 				Name: "New User",
 			},
 
-			AssertResponse: func(t *testing.T, expected interface{}, responseBody []byte) bool {
-				responseObj := User{}
-				if err := json.Unmarshal(responseBody, &responseObj); err != nil {
-					t.Errorf("could not unmarshal payload into Business model: %s\nPayload: %s", err.Error(), string(responseBody))
-					return false
-				}
-				if responseObj.ID == 0 {
-					t.Errorf("User.ID must not be empty\nPayload: %s", string(responseBody))
-					return false
-				}
-
-				expectedUser.ID = responseObj.ID
-				return schreder.AssertResponse(t, expectedUser, responseBody)
-			},
-
-			ExpectedData: User{
+			// ID is generated by server, so only its type is checked
+			ExpectedData: match.WithExample(map[string]interface{}{
+				"ID":   match.Type(3),
+				"Name": "New User",
+			}, User{
 				ID:   3,
 				Name: "New User",
+			}),
+
+			// ID of the created user is used by DeleteUserTest
+			Captures: []schreder.Capture{
+				{Variable: "user_id", JSONPointer: "/ID"},
 			},
 		},
 	}
@@ -13,7 +13,7 @@ func (t *DeleteUserTest) TestCases() []schreder.TestCase {
 			Description:      "User deleted successfully",
 			ExpectedHttpCode: 204,
 			PathParams: schreder.ParamMap{
-				"user_id": schreder.Param{Value: 3},
+				"user_id": schreder.Param{Value: "${user_id}"},
 			},
 		},
 	}
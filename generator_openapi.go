@@ -0,0 +1,544 @@
+package schreder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-openapi/spec"
+)
+
+const (
+	swaggerDefinitionsRefPrefix = "#/definitions/"
+	openAPISchemasRefPrefix     = "#/components/schemas/"
+	defaultOpenAPIVersion       = "3.0.3"
+	defaultMediaType            = "application/json"
+)
+
+// OpenAPI describes the root of OpenAPI 3.x document. It contains only those
+// parts of the specification that can be either filled by the generator or
+// provided with a seed.
+type OpenAPI struct {
+	OpenAPI      string                      `json:"openapi"`
+	Info         *spec.Info                  `json:"info,omitempty"`
+	Servers      []OpenAPIServer             `json:"servers,omitempty"`
+	Paths        map[string]OpenAPIPathItem  `json:"paths"`
+	Components   *OpenAPIComponents          `json:"components,omitempty"`
+	Tags         []spec.Tag                  `json:"tags,omitempty"`
+	ExternalDocs *spec.ExternalDocumentation `json:"externalDocs,omitempty"`
+}
+
+// OpenAPIServer describes a server that hosts the API
+type OpenAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIComponents holds reusable objects of the document
+type OpenAPIComponents struct {
+	Schemas         map[string]spec.Schema           `json:"schemas,omitempty"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme describes a security scheme that can be used by operations
+type OpenAPISecurityScheme struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Name        string             `json:"name,omitempty"`
+	In          string             `json:"in,omitempty"`
+	Scheme      string             `json:"scheme,omitempty"`
+	Flows       *OpenAPIOAuthFlows `json:"flows,omitempty"`
+}
+
+// OpenAPIOAuthFlows lists supported OAuth2 flows
+type OpenAPIOAuthFlows struct {
+	Implicit          *OpenAPIOAuthFlow `json:"implicit,omitempty"`
+	Password          *OpenAPIOAuthFlow `json:"password,omitempty"`
+	ClientCredentials *OpenAPIOAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OpenAPIOAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+// OpenAPIOAuthFlow describes an OAuth2 flow
+type OpenAPIOAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// OpenAPIPathItem describes operations available on a single path
+type OpenAPIPathItem struct {
+	Get     *OpenAPIOperation `json:"get,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty"`
+	Head    *OpenAPIOperation `json:"head,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty"`
+	Trace   *OpenAPIOperation `json:"trace,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+// OpenAPIParameter describes a single operation parameter
+type OpenAPIParameter struct {
+	Name        string       `json:"name"`
+	In          string       `json:"in"`
+	Description string       `json:"description,omitempty"`
+	Required    bool         `json:"required,omitempty"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody describes a request body, one entry per media type
+type OpenAPIRequestBody struct {
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required,omitempty"`
+	Content     map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response from an API operation
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType provides schema and examples for the media type
+type OpenAPIMediaType struct {
+	Schema   *spec.Schema              `json:"schema,omitempty"`
+	Examples map[string]OpenAPIExample `json:"examples,omitempty"`
+}
+
+// OpenAPIExample is a named example of request or response payload
+type OpenAPIExample struct {
+	Summary string      `json:"summary,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+}
+
+type openAPIGenerator struct {
+	seed       OpenAPI
+	marshaller MarshallerFunc
+	security   []SecurityScheme
+	redaction  Redaction
+}
+
+// NewOpenAPIGeneratorYAML initializes new generator with initial OpenAPI doc
+// as a seed. The generator produces YAML output
+func NewOpenAPIGeneratorYAML(seed OpenAPI) IDocGenerator {
+	return NewOpenAPIGenerator(seed, yaml.Marshal)
+}
+
+// NewOpenAPIGeneratorJSON initializes new generator with initial OpenAPI doc
+// as a seed. The generator produces JSON output with no indentation
+func NewOpenAPIGeneratorJSON(seed OpenAPI) IDocGenerator {
+	return NewOpenAPIGenerator(seed, json.Marshal)
+}
+
+// NewOpenAPIGeneratorJSONIndent initializes new generator with initial OpenAPI doc
+// as a seed. The generator produces indented JSON output
+func NewOpenAPIGeneratorJSONIndent(seed OpenAPI) IDocGenerator {
+	return NewOpenAPIGenerator(seed, func(obj interface{}) ([]byte, error) {
+		return json.MarshalIndent(obj, "", "    ")
+	})
+}
+
+// NewOpenAPIGenerator creates a new instance of OpenAPI 3.x generator with
+// given marshaller. Version of the document is taken from the seed,
+// "3.0.3" is used if it's not provided.
+func NewOpenAPIGenerator(seed OpenAPI, marshaller MarshallerFunc) IDocGenerator {
+	gen := &openAPIGenerator{
+		seed:       seed,
+		marshaller: marshaller,
+	}
+	if gen.seed.OpenAPI == "" {
+		gen.seed.OpenAPI = defaultOpenAPIVersion
+	}
+
+	return gen
+}
+
+// Generate implements IDocGenerator
+func (g *openAPIGenerator) Generate(tests []Test) ([]byte, error) {
+	if !strings.HasPrefix(g.seed.OpenAPI, "3.0.") && !strings.HasPrefix(g.seed.OpenAPI, "3.1.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version '%s', 3.0.x or 3.1.x expected", g.seed.OpenAPI)
+	}
+
+	tests, err := resolveTestConflicts(tests, ConflictError, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := g.seed
+	doc.Paths = map[string]OpenAPIPathItem{}
+	doc.Components = &OpenAPIComponents{}
+	if g.seed.Components != nil {
+		*doc.Components = *g.seed.Components
+	}
+	schemas := map[string]spec.Schema{}
+	for name, schema := range doc.Components.Schemas {
+		schemas[name] = schema
+	}
+	doc.Components.Schemas = schemas
+
+	if schemes := collectSecuritySchemes(tests, g.security); len(schemes) > 0 {
+		securitySchemes := map[string]OpenAPISecurityScheme{}
+		for name, scheme := range doc.Components.SecuritySchemes {
+			securitySchemes[name] = scheme
+		}
+		for _, scheme := range schemes {
+			securitySchemes[scheme.Name] = openAPISecurityScheme(scheme)
+		}
+		doc.Components.SecuritySchemes = securitySchemes
+	}
+
+	variables := capturedExamples(tests)
+	for _, test := range tests {
+		path := doc.Paths[test.Path()]
+		op, err := g.generateOperation(test, schemas, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		switch test.Method() {
+		case "GET":
+			path.Get = &op
+		case "POST":
+			path.Post = &op
+		case "PATCH":
+			path.Patch = &op
+		case "DELETE":
+			path.Delete = &op
+		case "PUT":
+			path.Put = &op
+		case "HEAD":
+			path.Head = &op
+		case "OPTIONS":
+			path.Options = &op
+		case "TRACE":
+			path.Trace = &op
+		}
+
+		doc.Paths[test.Path()] = path
+	}
+
+	if len(doc.Components.Schemas) == 0 && len(doc.Components.SecuritySchemes) == 0 {
+		doc.Components = nil
+	}
+
+	return g.marshaller(doc)
+}
+
+func (g *openAPIGenerator) generateOperation(test Test, schemas map[string]spec.Schema, variables map[string]interface{}) (OpenAPIOperation, error) {
+	op := OpenAPIOperation{
+		Responses: map[string]OpenAPIResponse{},
+	}
+
+	var description string
+	processedQueryParams := map[string]interface{}{}
+	processedPathParams := map[string]interface{}{}
+	processedHeaderParams := map[string]interface{}{}
+	security := testSecurity(test, g.security)
+	testCases := test.TestCases()
+	requestExampleNames := exampleNames(testCases, allTestCaseIndexes(testCases))
+	for caseIndex, testCase := range testCases {
+		// parameter definitions are collected from 2xx tests only
+		if testCase.ExpectedHttpCode >= 200 && testCase.ExpectedHttpCode < 300 {
+			description = testCase.Description
+
+			for key, param := range testCase.Headers {
+				if _, ok := processedHeaderParams[key]; ok {
+					continue
+				}
+				// OpenAPI 3 ignores these header parameters, they are described
+				// by requestBody, responses and security schemes instead
+				if isOpenAPIReservedHeader(key) || isCredentialParam(security, key, "header") {
+					continue
+				}
+
+				specParam, err := generateOpenAPIParam(key, param, "header", variables)
+				if err != nil {
+					return op, err
+				}
+				if g.redaction.IsSecretHeader(key) {
+					specParam.Schema.Default = nil
+				}
+
+				processedHeaderParams[key] = nil
+				op.Parameters = append(op.Parameters, specParam)
+			}
+
+			for key, param := range testCase.PathParams {
+				if _, ok := processedPathParams[key]; ok {
+					continue
+				}
+				param.Required = true // path parameters are always required
+				specParam, err := generateOpenAPIParam(key, param, "path", variables)
+				if err != nil {
+					return op, err
+				}
+
+				processedPathParams[key] = nil
+				op.Parameters = append(op.Parameters, specParam)
+			}
+
+			for key, param := range testCase.QueryParams {
+				if _, ok := processedQueryParams[key]; ok {
+					continue
+				}
+				if isCredentialParam(security, key, "query") {
+					continue
+				}
+
+				specParam, err := generateOpenAPIParam(key, param, "query", variables)
+				if err != nil {
+					return op, err
+				}
+
+				processedQueryParams[key] = nil
+				op.Parameters = append(op.Parameters, specParam)
+			}
+
+			if testCase.RequestBody != nil {
+				if op.RequestBody == nil {
+					op.RequestBody = &OpenAPIRequestBody{
+						Required: true,
+						Content:  map[string]OpenAPIMediaType{},
+					}
+				}
+
+				addOpenAPIExample(op.RequestBody.Content, testCase.requestMediaType(),
+					requestExampleNames[caseIndex], testCase.Description, testCase.RequestBody, schemas, g.redaction)
+			}
+		}
+	}
+
+	// all test cases with the same HTTP code are merged into one response
+	codes, casesByCode := groupTestCasesByHttpCode(testCases)
+	for _, code := range codes {
+		op.Responses[strconv.Itoa(code)] = generateOpenAPIResponse(testCases, casesByCode[code], schemas, g.redaction)
+	}
+
+	op.Summary = description
+	if taggable, ok := test.(ITaggable); ok {
+		op.Tags = []string{taggable.Tag()}
+	}
+	for _, scheme := range security {
+		op.Security = append(op.Security, map[string][]string{scheme.Name: append([]string{}, scheme.Scopes...)})
+	}
+
+	return op, nil
+}
+
+// withSecurity implements securedGenerator
+func (g *openAPIGenerator) withSecurity(schemes []SecurityScheme) IDocGenerator {
+	secured := *g
+	secured.security = append(append([]SecurityScheme{}, g.security...), schemes...)
+
+	return &secured
+}
+
+// withRedaction implements redactingGenerator
+func (g *openAPIGenerator) withRedaction(redaction Redaction) IDocGenerator {
+	redacted := *g
+	redacted.redaction = g.redaction.merge(redaction)
+
+	return &redacted
+}
+
+// withServer implements serverGenerator
+func (g *openAPIGenerator) withServer(base *url.URL) IDocGenerator {
+	served := *g
+	served.seed.Servers = []OpenAPIServer{{URL: base.String()}}
+
+	return &served
+}
+
+// openAPISecurityScheme converts security scheme into OpenAPI security scheme
+func openAPISecurityScheme(scheme SecurityScheme) OpenAPISecurityScheme {
+	result := OpenAPISecurityScheme{Type: scheme.Type, Description: scheme.Description}
+	switch scheme.Type {
+	case "basic":
+		result.Type = "http"
+		result.Scheme = "basic"
+	case "oauth2":
+		flow := &OpenAPIOAuthFlow{
+			AuthorizationURL: scheme.AuthorizationURL,
+			TokenURL:         scheme.TokenURL,
+			Scopes:           map[string]string{},
+		}
+		for _, scope := range scheme.Scopes {
+			flow.Scopes[scope] = ""
+		}
+
+		result.Flows = &OpenAPIOAuthFlows{}
+		switch scheme.Flow {
+		case "implicit":
+			result.Flows.Implicit = flow
+		case "password":
+			result.Flows.Password = flow
+		case "accessCode":
+			result.Flows.AuthorizationCode = flow
+		default:
+			result.Flows.ClientCredentials = flow
+		}
+	default:
+		result.Name = scheme.ParamName
+		result.In = scheme.In
+	}
+
+	return result
+}
+
+// generateOpenAPIResponse merges test cases with given indexes into one response.
+// Every case is kept as a named example, different schemas are combined with oneOf
+func generateOpenAPIResponse(testCases []TestCase, indexes []int, schemas map[string]spec.Schema,
+	redaction Redaction) OpenAPIResponse {
+
+	response := OpenAPIResponse{
+		Description: joinTestCaseDescriptions(testCases, indexes),
+	}
+
+	names := exampleNames(testCases, indexes)
+	for _, caseIndex := range indexes {
+		testCase := testCases[caseIndex]
+		if testCase.ExpectedData == nil {
+			continue
+		}
+
+		if response.Content == nil {
+			response.Content = map[string]OpenAPIMediaType{}
+		}
+		addOpenAPIExample(response.Content, testCase.responseMediaType(),
+			names[caseIndex], testCase.Description, testCase.ExpectedData, schemas, redaction)
+	}
+
+	return response
+}
+
+// addOpenAPIExample adds named example to the content of given media type
+// and extends the schema of the content if it's needed
+func addOpenAPIExample(content map[string]OpenAPIMediaType, mediaType, name, summary string,
+	data interface{}, schemas map[string]spec.Schema, redaction Redaction) {
+
+	mediaTypeContent := content[mediaType]
+	mediaTypeContent.Schema = combineOneOf(mediaTypeContent.Schema, generateOpenAPISchema(data, schemas))
+	if mediaTypeContent.Examples == nil {
+		mediaTypeContent.Examples = map[string]OpenAPIExample{}
+	}
+	mediaTypeContent.Examples[name] = OpenAPIExample{
+		Summary: summary,
+		Value:   redaction.RedactData(data),
+	}
+
+	content[mediaType] = mediaTypeContent
+}
+
+// combineOneOf returns a schema that accepts both existing and given schema
+func combineOneOf(existing, schema *spec.Schema) *spec.Schema {
+	if existing == nil {
+		return schema
+	}
+
+	isCombination := len(existing.OneOf) > 0 && len(existing.Type) == 0 && existing.Ref.String() == ""
+	if isCombination {
+		existing.OneOf = appendUniqueSchema(existing.OneOf, *schema)
+		return existing
+	}
+	if containsSchema([]spec.Schema{*existing}, *schema) {
+		return existing
+	}
+
+	combined := &spec.Schema{}
+	combined.OneOf = []spec.Schema{*existing, *schema}
+	return combined
+}
+
+func generateOpenAPIParam(paramKey string, param Param, location string, variables map[string]interface{}) (OpenAPIParameter, error) {
+	specParam := OpenAPIParameter{
+		Name:        paramKey,
+		In:          location,
+		Required:    param.Required,
+		Description: param.Description,
+	}
+
+	value, isDefault := documentedParam(param, variables)
+	paramType, err := generateSpecSimpleType(value)
+	if err != nil {
+		return specParam, fmt.Errorf("could not guess type of parameter '%s': %s", paramKey, err.Error())
+	}
+	specParam.Schema = &spec.Schema{}
+	specParam.Schema.Type = []string{paramType}
+	if isDefault {
+		specParam.Schema.Default = value
+	}
+
+	return specParam, nil
+}
+
+// generateOpenAPISchema reflects a schema from given item the same way Swagger
+// generator does, but puts definitions to components and fixes references
+func generateOpenAPISchema(item interface{}, schemas map[string]spec.Schema) *spec.Schema {
+	defs := spec.Definitions{}
+	schema := generateSpecSchema(item, defs)
+	rewriteOpenAPIRefs(schema)
+
+	for name, def := range defs {
+		rewriteOpenAPIRefs(&def)
+		schemas[name] = def
+	}
+
+	return schema
+}
+
+func rewriteOpenAPIRefs(s *spec.Schema) {
+	if ref := s.Ref.String(); strings.HasPrefix(ref, swaggerDefinitionsRefPrefix) {
+		s.Ref = spec.MustCreateRef(openAPISchemasRefPrefix + strings.TrimPrefix(ref, swaggerDefinitionsRefPrefix))
+	}
+
+	for key, prop := range s.Properties {
+		rewriteOpenAPIRefs(&prop)
+		s.Properties[key] = prop
+	}
+	for key, prop := range s.PatternProperties {
+		rewriteOpenAPIRefs(&prop)
+		s.PatternProperties[key] = prop
+	}
+	if s.Items != nil {
+		if s.Items.Schema != nil {
+			rewriteOpenAPIRefs(s.Items.Schema)
+		}
+		for i := range s.Items.Schemas {
+			rewriteOpenAPIRefs(&s.Items.Schemas[i])
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		rewriteOpenAPIRefs(s.AdditionalProperties.Schema)
+	}
+	for i := range s.AllOf {
+		rewriteOpenAPIRefs(&s.AllOf[i])
+	}
+	for i := range s.AnyOf {
+		rewriteOpenAPIRefs(&s.AnyOf[i])
+	}
+	for i := range s.OneOf {
+		rewriteOpenAPIRefs(&s.OneOf[i])
+	}
+
+	// definitions are moved to components, schema must not keep them
+	s.Definitions = nil
+}
+
+func isOpenAPIReservedHeader(name string) bool {
+	return strings.EqualFold(name, "Content-Type") ||
+		strings.EqualFold(name, "Accept") ||
+		strings.EqualFold(name, "Authorization")
+}
@@ -3,7 +3,9 @@ package schreder
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/jsonschema"
@@ -12,7 +14,9 @@ import (
 )
 
 type ramlGenerator struct {
-	seed raml.APIDefinition
+	seed      raml.APIDefinition
+	security  []SecurityScheme
+	redaction Redaction
 }
 
 // NewRamlGenerator creates an instance of RAML generator
@@ -29,7 +33,14 @@ func NewRamlGenerator(seed raml.APIDefinition) IDocGenerator {
 }
 
 func (g *ramlGenerator) Generate(tests []Test) ([]byte, error) {
+	tests, err := resolveTestConflicts(tests, ConflictError, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	doc := g.seed // copy seed
+	securedBy := map[string]map[string][]string{}
+	variables := capturedExamples(tests)
 
 	for _, test := range tests {
 		// path MUST begin with '/'
@@ -55,62 +66,61 @@ func (g *ramlGenerator) Generate(tests []Test) ([]byte, error) {
 		processedPathParams := map[string]interface{}{}
 		processedQueryParams := map[string]interface{}{}
 
-		for _, testCase := range test.TestCases() {
+		security := testSecurity(test, g.security)
+		for _, scheme := range security {
+			if securedBy[path] == nil {
+				securedBy[path] = map[string][]string{}
+			}
+			method := strings.ToLower(test.Method())
+			securedBy[path][method] = append(securedBy[path][method], scheme.Name)
+		}
+
+		testCases := test.TestCases()
+		for _, testCase := range testCases {
 			m.Description = testCase.Description
 			for key, param := range testCase.PathParams {
 				if _, ok := processedPathParams[key]; ok {
 					continue
 				}
 
-				uriParam := generateRamlNamedParameter(key, param)
+				uriParam := generateRamlNamedParameter(key, param, variables)
 
 				processedPathParams[key] = nil
 				resource.UriParameters[key] = uriParam
 			}
 
 			for key, param := range testCase.Headers {
-				if _, ok := processedHeaderParams[key]; ok {
+				if _, ok := processedHeaderParams[key]; ok || isCredentialParam(security, key, "header") {
 					continue
 				}
 
-				h := generateRamlNamedParameter(key, param)
+				h := generateRamlNamedParameter(key, param, variables)
+				if g.redaction.IsSecretHeader(key) {
+					h.Default = nil
+				}
 
 				m.Headers[raml.HTTPHeader(key)] = raml.Header(h)
 				processedHeaderParams[key] = nil
 			}
 
 			for key, param := range testCase.QueryParams {
-				if _, ok := processedQueryParams[key]; ok {
+				if _, ok := processedQueryParams[key]; ok || isCredentialParam(security, key, "query") {
 					continue
 				}
 
-				queryParam := generateRamlNamedParameter(key, param)
+				queryParam := generateRamlNamedParameter(key, param, variables)
 
 				processedQueryParams[key] = nil
 				m.QueryParameters[key] = queryParam
 			}
+		}
 
-			response := raml.Response{}
-			response.Description = testCase.Description
-			response.HTTPCode = raml.HTTPCode(testCase.ExpectedHttpCode)
-			if testCase.ExpectedData != nil {
-				schema := jsonschema.Reflect(testCase.ExpectedData)
-
-				// TODO: marshal data according to MIME type, coming soon with RAML 1.0
-				schemaBytes, _ := json.MarshalIndent(schema, "", "  ")
-				response.Bodies.DefaultSchema = string(schemaBytes)
-
-				// TODO: marshal data according to MIME type, coming soon with RAML 1.0
-				exampleBytes, _ := json.MarshalIndent(testCase.ExpectedData, "", "  ")
-				response.Bodies.DefaultExample = string(exampleBytes)
-			}
-
-			m.Responses[raml.HTTPCode(testCase.ExpectedHttpCode)] = response
-
+		// all test cases with the same HTTP code are merged into one response
+		codes, casesByCode := groupTestCasesByHttpCode(testCases)
+		for _, code := range codes {
+			m.Responses[raml.HTTPCode(code)] = generateRamlResponse(testCases, casesByCode[code], g.redaction)
 		}
 
-		// TODO: check if path has already assigned an method to some other test
-		// return error if so
 		switch test.Method() {
 		case "GET":
 			resource.Get = &m
@@ -130,6 +140,9 @@ func (g *ramlGenerator) Generate(tests []Test) ([]byte, error) {
 	}
 
 	generatedDoc, err := yaml.Marshal(doc)
+	if schemes := collectSecuritySchemes(tests, g.security); err == nil && len(schemes) > 0 {
+		generatedDoc, err = addRamlSecurity(generatedDoc, schemes, securedBy)
+	}
 	if err == nil {
 		header := []byte(fmt.Sprintf("#%%RAML %s\n", doc.RAMLVersion))
 		generatedDoc = append(header, generatedDoc...)
@@ -139,14 +152,205 @@ func (g *ramlGenerator) Generate(tests []Test) ([]byte, error) {
 	return generatedDoc, err
 }
 
-func generateRamlNamedParameter(paramKey string, param Param) raml.NamedParameter {
-	return raml.NamedParameter{
+// withSecurity implements securedGenerator
+func (g *ramlGenerator) withSecurity(schemes []SecurityScheme) IDocGenerator {
+	secured := *g
+	secured.security = append(append([]SecurityScheme{}, g.security...), schemes...)
+
+	return &secured
+}
+
+// withRedaction implements redactingGenerator
+func (g *ramlGenerator) withRedaction(redaction Redaction) IDocGenerator {
+	redacted := *g
+	redacted.redaction = g.redaction.merge(redaction)
+
+	return &redacted
+}
+
+// withServer implements serverGenerator
+func (g *ramlGenerator) withServer(base *url.URL) IDocGenerator {
+	served := *g
+	served.seed.BaseUri = base.String()
+	served.seed.Protocols = []string{strings.ToUpper(base.Scheme)}
+
+	return &served
+}
+
+// addRamlSecurity adds security schemes and 'securedBy' of methods to generated
+// document. DefinitionChoice of go-raml can not be marshalled into 'securedBy',
+// so they are added to the document once it's marshalled. securedBy lists names
+// of the schemes by path and method of resources
+func addRamlSecurity(generatedDoc []byte, schemes []SecurityScheme, securedBy map[string]map[string][]string) ([]byte, error) {
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(generatedDoc, &doc); err != nil {
+		return nil, err
+	}
+
+	securitySchemes := []yaml.MapSlice{}
+	for _, scheme := range schemes {
+		securitySchemes = append(securitySchemes, yaml.MapSlice{{Key: scheme.Name, Value: ramlSecurity(scheme)}})
+	}
+
+	secured := yaml.MapSlice{}
+	for _, item := range doc {
+		path, _ := item.Key.(string)
+		if strings.HasPrefix(path, "/") && securitySchemes != nil {
+			// schemes are declared before resources
+			secured = append(secured, yaml.MapItem{Key: "securitySchemes", Value: securitySchemes})
+			securitySchemes = nil
+		}
+
+		if methods, ok := item.Value.(yaml.MapSlice); ok && securedBy[path] != nil {
+			for i, method := range methods {
+				name, _ := method.Key.(string)
+				definition, ok := method.Value.(yaml.MapSlice)
+				if ok && len(securedBy[path][name]) > 0 {
+					methods[i].Value = append(definition, yaml.MapItem{Key: "securedBy", Value: securedBy[path][name]})
+				}
+			}
+		}
+		secured = append(secured, item)
+	}
+	if securitySchemes != nil {
+		secured = append(secured, yaml.MapItem{Key: "securitySchemes", Value: securitySchemes})
+	}
+
+	return yaml.Marshal(secured)
+}
+
+// ramlSecurity converts security scheme into RAML 0.8 security scheme
+func ramlSecurity(scheme SecurityScheme) yaml.MapSlice {
+	result := yaml.MapSlice{}
+	if scheme.Description != "" {
+		result = append(result, yaml.MapItem{Key: "description", Value: scheme.Description})
+	}
+
+	switch scheme.Type {
+	case "basic":
+		result = append(result, yaml.MapItem{Key: "type", Value: "Basic Authentication"})
+	case "oauth2":
+		settings := yaml.MapSlice{
+			{Key: "accessTokenUri", Value: scheme.TokenURL},
+			{Key: "authorizationGrants", Value: []string{oauth2Grant(scheme.Flow, "0.8")}},
+		}
+		if scheme.AuthorizationURL != "" {
+			settings = append(settings, yaml.MapItem{Key: "authorizationUri", Value: scheme.AuthorizationURL})
+		}
+		if len(scheme.Scopes) > 0 {
+			settings = append(settings, yaml.MapItem{Key: "scopes", Value: scheme.Scopes})
+		}
+		result = append(result,
+			yaml.MapItem{Key: "type", Value: "OAuth 2.0"},
+			yaml.MapItem{Key: "describedBy", Value: yaml.MapSlice{
+				{Key: "headers", Value: yaml.MapSlice{{Key: "Authorization", Value: yaml.MapSlice{{Key: "type", Value: "string"}}}}},
+			}},
+			yaml.MapItem{Key: "settings", Value: settings})
+	default:
+		// RAML 0.8 has no scheme of API keys, so a custom one is used
+		location := "headers"
+		if scheme.In == "query" {
+			location = "queryParameters"
+		}
+		result = append(result,
+			yaml.MapItem{Key: "type", Value: "x-" + scheme.Type},
+			yaml.MapItem{Key: "describedBy", Value: yaml.MapSlice{
+				{Key: location, Value: yaml.MapSlice{{Key: scheme.ParamName, Value: yaml.MapSlice{{Key: "type", Value: "string"}}}}},
+			}})
+	}
+
+	return result
+}
+
+// generateRamlResponse merges test cases with given indexes into one response.
+// RAML 0.8 allows only one example per body, so the first test case provides it
+// and examples of all the cases are listed in the description. Different
+// schemas are combined with oneOf.
+func generateRamlResponse(testCases []TestCase, indexes []int, redaction Redaction) raml.Response {
+	response := raml.Response{}
+	response.Description = joinTestCaseDescriptions(testCases, indexes)
+	response.HTTPCode = raml.HTTPCode(testCases[indexes[0]].ExpectedHttpCode)
+
+	var schemas []*jsonschema.Schema
+	var examples []string
+	for _, caseIndex := range indexes {
+		testCase := testCases[caseIndex]
+		if testCase.ExpectedData == nil {
+			continue
+		}
+
+		// TODO: marshal data according to MIME type, coming soon with RAML 1.0
+		exampleBytes, _ := json.MarshalIndent(redaction.RedactData(testCase.ExpectedData), "", "  ")
+		if response.Bodies.DefaultExample == "" {
+			response.Bodies.DefaultExample = string(exampleBytes)
+		}
+		examples = append(examples, fmt.Sprintf("**%s**:\n\n```\n%s\n```",
+			testCaseName(testCase, caseIndex), string(exampleBytes)))
+
+		schemas = appendUniqueJsonSchema(schemas, reflectJsonSchema(testCase.ExpectedData))
+	}
+
+	if len(examples) > 1 {
+		response.Description += "\n\n" + strings.Join(examples, "\n\n")
+	}
+
+	if len(schemas) > 0 {
+		// TODO: marshal data according to MIME type, coming soon with RAML 1.0
+		schemaBytes, _ := json.MarshalIndent(combineJsonSchemas(schemas), "", "  ")
+		response.Bodies.DefaultSchema = string(schemaBytes)
+	}
+
+	return response
+}
+
+func appendUniqueJsonSchema(schemas []*jsonschema.Schema, schema *jsonschema.Schema) []*jsonschema.Schema {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return schemas
+	}
+
+	for _, s := range schemas {
+		if existing, err := json.Marshal(s); err == nil && string(existing) == string(encoded) {
+			return schemas
+		}
+	}
+
+	return append(schemas, schema)
+}
+
+// combineJsonSchemas makes a schema that accepts any of given schemas
+func combineJsonSchemas(schemas []*jsonschema.Schema) *jsonschema.Schema {
+	if len(schemas) == 1 {
+		return schemas[0]
+	}
+
+	combined := &jsonschema.Schema{
+		Type:        &jsonschema.Type{},
+		Definitions: jsonschema.Definitions{},
+	}
+	for _, schema := range schemas {
+		combined.Type.OneOf = append(combined.Type.OneOf, schema.Type)
+		for name, def := range schema.Definitions {
+			combined.Definitions[name] = def
+		}
+	}
+
+	return combined
+}
+
+func generateRamlNamedParameter(paramKey string, param Param, variables map[string]interface{}) raml.NamedParameter {
+	value, isDefault := documentedParam(param, variables)
+	namedParam := raml.NamedParameter{
 		Name:        paramKey,
 		Description: param.Description,
 		Required:    param.Required,
-		Default:     param.Value,
-		Type:        resolveRamlType(param.Value),
+		Type:        resolveRamlType(value),
 	}
+	if isDefault {
+		namedParam.Default = value
+	}
+
+	return namedParam
 }
 
 func resolveRamlType(data interface{}) string {
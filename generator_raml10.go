@@ -0,0 +1,460 @@
+package schreder
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/alecthomas/jsonschema"
+	"github.com/go-raml/raml"
+	"gopkg.in/yaml.v2"
+)
+
+// raml10Document is a root of RAML 1.0 document. go-raml supports RAML 0.8 only,
+// so the generator relies on its own definition of the document
+type raml10Document struct {
+	Title           string                           `yaml:"title"`
+	Version         string                           `yaml:"version,omitempty"`
+	BaseUri         string                           `yaml:"baseUri,omitempty"`
+	Protocols       []string                         `yaml:"protocols,omitempty"`
+	MediaType       string                           `yaml:"mediaType,omitempty"`
+	SecuritySchemes map[string]*raml10SecurityScheme `yaml:"securitySchemes,omitempty"`
+	Types           map[string]*raml10Type           `yaml:"types,omitempty"`
+	Resources       map[string]raml10Resource        `yaml:",inline"`
+}
+
+type raml10SecurityScheme struct {
+	Type        string                     `yaml:"type"`
+	Description string                     `yaml:"description,omitempty"`
+	DescribedBy *raml10SecurityDescription `yaml:"describedBy,omitempty"`
+	Settings    map[string]interface{}     `yaml:"settings,omitempty"`
+}
+
+type raml10SecurityDescription struct {
+	Headers         map[string]*raml10Type `yaml:"headers,omitempty"`
+	QueryParameters map[string]*raml10Type `yaml:"queryParameters,omitempty"`
+}
+
+type raml10Resource struct {
+	UriParameters map[string]*raml10Type `yaml:"uriParameters,omitempty"`
+	Get           *raml10Method          `yaml:"get,omitempty"`
+	Post          *raml10Method          `yaml:"post,omitempty"`
+	Put           *raml10Method          `yaml:"put,omitempty"`
+	Patch         *raml10Method          `yaml:"patch,omitempty"`
+	Delete        *raml10Method          `yaml:"delete,omitempty"`
+	Head          *raml10Method          `yaml:"head,omitempty"`
+	Options       *raml10Method          `yaml:"options,omitempty"`
+}
+
+type raml10Method struct {
+	Description     string                  `yaml:"description,omitempty"`
+	Headers         map[string]*raml10Type  `yaml:"headers,omitempty"`
+	QueryParameters map[string]*raml10Type  `yaml:"queryParameters,omitempty"`
+	Body            map[string]*raml10Type  `yaml:"body,omitempty"`
+	Responses       map[int]*raml10Response `yaml:"responses,omitempty"`
+	SecuredBy       []string                `yaml:"securedBy,omitempty"`
+}
+
+type raml10Response struct {
+	Description string                 `yaml:"description,omitempty"`
+	Body        map[string]*raml10Type `yaml:"body,omitempty"`
+}
+
+// raml10Type is a RAML 1.0 type declaration. It's used to declare types,
+// parameters and bodies
+type raml10Type struct {
+	Type                 string                   `yaml:"type,omitempty"`
+	Description          string                   `yaml:"description,omitempty"`
+	Required             *bool                    `yaml:"required,omitempty"`
+	Default              interface{}              `yaml:"default,omitempty"`
+	Enum                 []interface{}            `yaml:"enum,omitempty"`
+	Pattern              string                   `yaml:"pattern,omitempty"`
+	Properties           map[string]*raml10Type   `yaml:"properties,omitempty"`
+	AdditionalProperties *bool                    `yaml:"additionalProperties,omitempty"`
+	Items                *raml10Type              `yaml:"items,omitempty"`
+	Examples             map[string]raml10Example `yaml:"examples,omitempty"`
+}
+
+type raml10Example struct {
+	Value interface{} `yaml:"value"`
+}
+
+type raml10Generator struct {
+	seed      raml.APIDefinition
+	security  []SecurityScheme
+	redaction Redaction
+}
+
+// NewRaml10Generator creates an instance of RAML 1.0 generator.
+// seed is used as a source of initial data for resulting doc
+func NewRaml10Generator(seed raml.APIDefinition) IDocGenerator {
+	return &raml10Generator{
+		seed: seed,
+	}
+}
+
+// Generate implements IDocGenerator
+func (g *raml10Generator) Generate(tests []Test) ([]byte, error) {
+	tests, err := resolveTestConflicts(tests, ConflictError, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := raml10Document{
+		Title:     g.seed.Title,
+		Version:   g.seed.Version,
+		BaseUri:   g.seed.BaseUri,
+		Protocols: g.seed.Protocols,
+		MediaType: g.seed.MediaType,
+		Types:     map[string]*raml10Type{},
+		Resources: map[string]raml10Resource{},
+	}
+	if schemes := collectSecuritySchemes(tests, g.security); len(schemes) > 0 {
+		doc.SecuritySchemes = map[string]*raml10SecurityScheme{}
+		for _, scheme := range schemes {
+			doc.SecuritySchemes[scheme.Name] = raml10Security(scheme)
+		}
+	}
+
+	variables := capturedExamples(tests)
+	for _, test := range tests {
+		// path MUST begin with '/'
+		path := test.Path()
+		if path[0] != '/' {
+			path = "/" + path
+		}
+
+		resource, ok := doc.Resources[path]
+		if !ok { // new resource created by default
+			resource.UriParameters = map[string]*raml10Type{}
+		}
+
+		m, err := g.generateMethod(test, resource, doc.Types, variables)
+		if err != nil {
+			return nil, err
+		}
+
+		switch test.Method() {
+		case "GET":
+			resource.Get = m
+		case "POST":
+			resource.Post = m
+		case "PATCH":
+			resource.Patch = m
+		case "DELETE":
+			resource.Delete = m
+		case "PUT":
+			resource.Put = m
+		case "HEAD":
+			resource.Head = m
+		case "OPTIONS":
+			resource.Options = m
+		}
+
+		doc.Resources[path] = resource
+	}
+
+	generatedDoc, err := yaml.Marshal(doc)
+	if err == nil {
+		generatedDoc = append([]byte("#%RAML 1.0\n"), generatedDoc...)
+	}
+
+	return generatedDoc, err
+}
+
+func (g *raml10Generator) generateMethod(test Test, resource raml10Resource, types map[string]*raml10Type, variables map[string]interface{}) (*raml10Method, error) {
+	m := &raml10Method{
+		Responses:       map[int]*raml10Response{},
+		Headers:         map[string]*raml10Type{},
+		QueryParameters: map[string]*raml10Type{},
+		Body:            map[string]*raml10Type{},
+		Description:     test.Description(),
+	}
+
+	security := testSecurity(test, g.security)
+	for _, scheme := range security {
+		m.SecuredBy = append(m.SecuredBy, scheme.Name)
+	}
+
+	testCases := test.TestCases()
+	requestExampleNames := exampleNames(testCases, allTestCaseIndexes(testCases))
+	for caseIndex, testCase := range testCases {
+		for key, param := range testCase.PathParams {
+			if _, ok := resource.UriParameters[key]; ok {
+				continue
+			}
+
+			param.Required = true // path parameters are always required
+			resource.UriParameters[key] = generateRaml10Parameter(param, variables)
+		}
+
+		for key, param := range testCase.Headers {
+			if _, ok := m.Headers[key]; ok || isCredentialParam(security, key, "header") {
+				continue
+			}
+
+			m.Headers[key] = generateRaml10Parameter(param, variables)
+			if g.redaction.IsSecretHeader(key) {
+				m.Headers[key].Default = nil
+			}
+		}
+
+		for key, param := range testCase.QueryParams {
+			if _, ok := m.QueryParameters[key]; ok || isCredentialParam(security, key, "query") {
+				continue
+			}
+
+			m.QueryParameters[key] = generateRaml10Parameter(param, variables)
+		}
+
+		if testCase.RequestBody != nil {
+			if err := addRaml10BodyExample(m.Body, testCase.requestMediaType(),
+				requestExampleNames[caseIndex], testCase.RequestBody, types, g.redaction); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// all test cases with the same HTTP code are merged into one response
+	codes, casesByCode := groupTestCasesByHttpCode(testCases)
+	for _, code := range codes {
+		response := &raml10Response{
+			Description: joinTestCaseDescriptions(testCases, casesByCode[code]),
+			Body:        map[string]*raml10Type{},
+		}
+
+		names := exampleNames(testCases, casesByCode[code])
+		for _, caseIndex := range casesByCode[code] {
+			testCase := testCases[caseIndex]
+			if testCase.ExpectedData == nil {
+				continue
+			}
+
+			if err := addRaml10BodyExample(response.Body, testCase.responseMediaType(),
+				names[caseIndex], testCase.ExpectedData, types, g.redaction); err != nil {
+				return nil, err
+			}
+		}
+
+		m.Responses[code] = response
+	}
+
+	return m, nil
+}
+
+// withSecurity implements securedGenerator
+func (g *raml10Generator) withSecurity(schemes []SecurityScheme) IDocGenerator {
+	secured := *g
+	secured.security = append(append([]SecurityScheme{}, g.security...), schemes...)
+
+	return &secured
+}
+
+// withRedaction implements redactingGenerator
+func (g *raml10Generator) withRedaction(redaction Redaction) IDocGenerator {
+	redacted := *g
+	redacted.redaction = g.redaction.merge(redaction)
+
+	return &redacted
+}
+
+// withServer implements serverGenerator
+func (g *raml10Generator) withServer(base *url.URL) IDocGenerator {
+	served := *g
+	served.seed.BaseUri = base.String()
+	served.seed.Protocols = []string{strings.ToUpper(base.Scheme)}
+
+	return &served
+}
+
+// raml10Security converts security scheme into RAML 1.0 security scheme
+func raml10Security(scheme SecurityScheme) *raml10SecurityScheme {
+	result := &raml10SecurityScheme{Description: scheme.Description}
+	authorization := &raml10SecurityDescription{
+		Headers: map[string]*raml10Type{"Authorization": &raml10Type{Type: "string"}},
+	}
+
+	switch scheme.Type {
+	case "basic":
+		result.Type = "Basic Authentication"
+	case "oauth2":
+		result.Type = "OAuth 2.0"
+		result.DescribedBy = authorization
+		result.Settings = map[string]interface{}{
+			"accessTokenUri":      scheme.TokenURL,
+			"authorizationGrants": []string{oauth2Grant(scheme.Flow, "1.0")},
+		}
+		if scheme.AuthorizationURL != "" {
+			result.Settings["authorizationUri"] = scheme.AuthorizationURL
+		}
+		if len(scheme.Scopes) > 0 {
+			result.Settings["scopes"] = scheme.Scopes
+		}
+	default:
+		// API keys are passed through to the API as they are
+		result.Type = "Pass Through"
+		param := map[string]*raml10Type{scheme.ParamName: &raml10Type{Type: "string"}}
+		if scheme.In == "query" {
+			result.DescribedBy = &raml10SecurityDescription{QueryParameters: param}
+		} else {
+			result.DescribedBy = &raml10SecurityDescription{Headers: param}
+		}
+	}
+
+	return result
+}
+
+// addRaml10BodyExample adds an example of given data to a body of given media type.
+// Type of the body is derived from the examples, different types are joined into a union.
+func addRaml10BodyExample(bodies map[string]*raml10Type, mediaType, exampleName string,
+	data interface{}, types map[string]*raml10Type, redaction Redaction) error {
+
+	example, err := objToJsonValue(redaction.RedactData(data))
+	if err != nil {
+		return fmt.Errorf("could not convert example '%s' of '%s' body: %s", exampleName, mediaType, err.Error())
+	}
+
+	bodyType := generateRaml10Type(data, types)
+	body, ok := bodies[mediaType]
+	if !ok {
+		body = bodyType
+		body.Examples = map[string]raml10Example{}
+		bodies[mediaType] = body
+	} else {
+		unionRaml10Types(body, bodyType)
+	}
+	body.Examples[exampleName] = raml10Example{Value: example}
+
+	return nil
+}
+
+// unionRaml10Types extends type of the body to a union with given type.
+// Union types can be built of type expressions only, so if any of the types
+// is declared inline, the body keeps its type as is
+func unionRaml10Types(body, t *raml10Type) {
+	existing, ok := raml10TypeExpression(body)
+	if !ok {
+		return
+	}
+	other, ok := raml10TypeExpression(t)
+	if !ok {
+		return
+	}
+
+	for _, member := range strings.Split(existing, " | ") {
+		if member == other {
+			return
+		}
+	}
+
+	body.Type = existing + " | " + other
+	body.Items = nil
+}
+
+// raml10TypeExpression returns a type expression that refers given declaration.
+// Only named, built-in and array types can be expressed.
+func raml10TypeExpression(t *raml10Type) (string, bool) {
+	if len(t.Properties) > 0 || len(t.Enum) > 0 || t.Pattern != "" || t.AdditionalProperties != nil {
+		return "", false
+	}
+
+	if t.Items != nil {
+		items, ok := raml10TypeExpression(t.Items)
+		if !ok {
+			return "", false
+		}
+		if strings.Contains(items, "|") {
+			items = "(" + items + ")"
+		}
+		return items + "[]", true
+	}
+
+	return t.Type, t.Type != ""
+}
+
+func generateRaml10Parameter(param Param, variables map[string]interface{}) *raml10Type {
+	value, isDefault := documentedParam(param, variables)
+	required := param.Required
+	paramType := resolveRamlType(value)
+	if paramType == "date" {
+		paramType = "datetime"
+	}
+
+	t := &raml10Type{
+		Type:        paramType,
+		Description: param.Description,
+		Required:    &required,
+	}
+	if isDefault {
+		t.Default = value
+	}
+
+	return t
+}
+
+// generateRaml10Type reflects a type declaration of given item.
+// All named types are put to types
+func generateRaml10Type(item interface{}, types map[string]*raml10Type) *raml10Type {
+	refl := reflectJsonSchema(item)
+	for name, def := range refl.Definitions {
+		types[name] = raml10TypeFromJsonType(def)
+	}
+
+	return raml10TypeFromJsonType(refl.Type)
+}
+
+func raml10TypeFromJsonType(schema *jsonschema.Type) *raml10Type {
+	if schema.Ref != "" {
+		return &raml10Type{Type: strings.TrimPrefix(schema.Ref, swaggerDefinitionsRefPrefix)}
+	}
+
+	t := &raml10Type{
+		Type:        schema.Type,
+		Description: schema.Description,
+		Enum:        schema.Enum,
+		Pattern:     schema.Pattern,
+		Default:     schema.Default,
+	}
+
+	switch {
+	case schema.Type == "":
+		t.Type = "any"
+	case schema.Type == "string" && schema.Format == "date-time":
+		t.Type = "datetime"
+	}
+
+	if schema.Items != nil {
+		t.Items = raml10TypeFromJsonType(schema.Items)
+	}
+
+	if schema.Properties != nil || schema.PatternProperties != nil {
+		t.Properties = map[string]*raml10Type{}
+	}
+	for key, prop := range schema.Properties {
+		property := raml10TypeFromJsonType(prop)
+		required := false
+		for _, name := range schema.Required {
+			if name == key {
+				required = true
+				break
+			}
+		}
+		property.Required = &required
+		t.Properties[key] = property
+	}
+	// pattern properties are defined as regular expressions enclosed in slashes
+	for pattern, prop := range schema.PatternProperties {
+		t.Properties["/"+pattern+"/"] = raml10TypeFromJsonType(prop)
+	}
+
+	switch string(schema.AdditionalProperties) {
+	case "true":
+		allows := true
+		t.AdditionalProperties = &allows
+	case "false":
+		allows := false
+		t.AdditionalProperties = &allows
+	}
+
+	return t
+}
@@ -3,6 +3,7 @@ package schreder
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/alecthomas/jsonschema"
 	"github.com/ghodss/yaml"
@@ -14,6 +15,8 @@ type MarshallerFunc func(obj interface{}) ([]byte, error)
 type swaggerGenerator struct {
 	seed       spec.Swagger
 	marshaller MarshallerFunc
+	security   []SecurityScheme
+	redaction  Redaction
 }
 
 // NewSwaggerGeneratorYAML initializes new generator with initial swagger spec
@@ -52,18 +55,31 @@ func NewSwaggerGenerator(seed spec.Swagger, marshaller MarshallerFunc) IDocGener
 // Generate implements IDocGenerator
 // TODO: is there any way to control swagger generator? I don't need it to analyze anonymous fields, I want to expand them
 func (g *swaggerGenerator) Generate(tests []Test) ([]byte, error) {
+	tests, err := resolveTestConflicts(tests, ConflictError, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	doc := g.seed
 	doc.Definitions = spec.Definitions{}
+	if schemes := collectSecuritySchemes(tests, g.security); len(schemes) > 0 {
+		doc.SecurityDefinitions = spec.SecurityDefinitions{}
+		for name, scheme := range g.seed.SecurityDefinitions {
+			doc.SecurityDefinitions[name] = scheme
+		}
+		for _, scheme := range schemes {
+			doc.SecurityDefinitions[scheme.Name] = swaggerSecurityScheme(scheme)
+		}
+	}
 
+	variables := capturedExamples(tests)
 	for _, test := range tests {
-		path := doc.Paths.Paths[test.Path()] // TODO: 2 tests on the same API with the same response code conflict
-		op, err := g.generateSwaggerOperation(test, doc.Definitions)
+		path := doc.Paths.Paths[test.Path()]
+		op, err := g.generateSwaggerOperation(test, doc.Definitions, variables)
 		if err != nil {
 			return nil, err
 		}
 
-		// TODO: check if path has already assigned an operation to some other test
-		// return error if so
 		switch test.Method() {
 		case "GET":
 			path.Get = &op
@@ -89,7 +105,7 @@ func (g *swaggerGenerator) Generate(tests []Test) ([]byte, error) {
 	return d, e
 }
 
-func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definitions) (spec.Operation, error) {
+func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definitions, variables map[string]interface{}) (spec.Operation, error) {
 
 	op := spec.Operation{}
 	op.Responses = &spec.Responses{}
@@ -99,7 +115,11 @@ func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definit
 	processedQueryParams := map[string]interface{}{}
 	processedPathParams := map[string]interface{}{}
 	processedHeaderParams := map[string]interface{}{}
-	for _, testCase := range test.TestCases() {
+	bodyProcessed := false
+	consumes := []string{}
+	security := testSecurity(test, g.security)
+	testCases := test.TestCases()
+	for _, testCase := range testCases {
 		// parameter definitions are collected from 2xx tests only
 		if testCase.ExpectedHttpCode >= 200 && testCase.ExpectedHttpCode < 300 {
 			description = testCase.Description
@@ -108,11 +128,17 @@ func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definit
 				if _, ok := processedHeaderParams[key]; ok {
 					continue
 				}
+				if isCredentialParam(security, key, "header") {
+					continue
+				}
 
-				specParam, err := generateSwaggerSpecParam(key, param, "header")
+				specParam, err := generateSwaggerSpecParam(key, param, "header", variables)
 				if err != nil {
 					return op, err
 				}
+				if g.redaction.IsSecretHeader(key) {
+					specParam.Default = nil
+				}
 
 				processedHeaderParams[key] = nil
 				op.Parameters = append(op.Parameters, specParam)
@@ -123,7 +149,7 @@ func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definit
 					continue
 				}
 				param.Required = true // path parameters are always required
-				specParam, err := generateSwaggerSpecParam(key, param, "path")
+				specParam, err := generateSwaggerSpecParam(key, param, "path", variables)
 				if err != nil {
 					return op, err
 				}
@@ -137,8 +163,11 @@ func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definit
 				if _, ok := processedQueryParams[key]; ok {
 					continue
 				}
+				if isCredentialParam(security, key, "query") {
+					continue
+				}
 
-				specParam, err := generateSwaggerSpecParam(key, param, "query")
+				specParam, err := generateSwaggerSpecParam(key, param, "query", variables)
 				if err != nil {
 					return op, err
 				}
@@ -147,51 +176,198 @@ func (g *swaggerGenerator) generateSwaggerOperation(test Test, defs spec.Definit
 				op.Parameters = append(op.Parameters, specParam)
 			}
 
-			if testCase.RequestBody != nil {
-				specParam := spec.Parameter{}
-				specParam.Name = "body"
-				specParam.In = "body"
-				specParam.Required = true
-
-				// TODO: right now it supports json, but should support marshaller depending on MIME type
-				if content, err := json.MarshalIndent(testCase.RequestBody, "", "  "); err == nil {
-					specParam.Description = string(content)
+			if testCase.RequestBody != nil && !bodyProcessed {
+				mediaType := testCase.requestMediaType()
+				consumes = append(consumes, mediaType)
+				bodyProcessed = true
+
+				// forms are described by a parameter per field
+				if isFormMediaType(mediaType) {
+					formParams, err := generateSwaggerFormParams(testCase.RequestBody, g.redaction)
+					if err != nil {
+						return op, err
+					}
+					op.Parameters = append(op.Parameters, formParams...)
+				} else {
+					specParam := spec.Parameter{}
+					specParam.Name = "body"
+					specParam.In = "body"
+					specParam.Required = true
+
+					if content, err := json.MarshalIndent(g.redaction.RedactData(testCase.RequestBody), "", "  "); err == nil {
+						specParam.Description = string(content)
+					}
+
+					specParam.Schema = generateSpecSchema(testCase.RequestBody, defs)
+					op.Parameters = append(op.Parameters, specParam)
 				}
-
-				specParam.Schema = generateSpecSchema(testCase.RequestBody, defs)
-				op.Parameters = append(op.Parameters, specParam)
 			}
 		}
 
-		response := spec.Response{}
-		response.Description = testCase.Description
-		if testCase.ExpectedData != nil {
-			response.Schema = generateSpecSchema(testCase.ExpectedData, defs)
-			response.Examples = map[string]interface{}{
-				"application/json": testCase.ExpectedData,
-			}
+	}
+
+	// operation overrides global 'consumes' only if it accepts something else
+	for _, mediaType := range consumes {
+		if !containsString(g.seed.Consumes, mediaType) {
+			op.Consumes = consumes
+			break
 		}
+	}
 
-		op.Responses.StatusCodeResponses[testCase.ExpectedHttpCode] = response
+	// all test cases with the same HTTP code are merged into one response
+	codes, casesByCode := groupTestCasesByHttpCode(testCases)
+	for _, code := range codes {
+		op.Responses.StatusCodeResponses[code] = generateSwaggerResponse(testCases, casesByCode[code], defs, g.redaction)
 	}
 
 	op.Summary = description
 	if taggable, ok := test.(ITaggable); ok {
 		op.Tags = []string{taggable.Tag()}
 	}
+	for _, scheme := range security {
+		// requirement with no scopes must be an empty list rather than null
+		op.SecuredWith(scheme.Name, append([]string{}, scheme.Scopes...)...)
+	}
 
 	return op, nil
 }
 
-func generateSwaggerSpecParam(paramKey string, param Param, location string) (spec.Parameter, error) {
+// withSecurity implements securedGenerator
+func (g *swaggerGenerator) withSecurity(schemes []SecurityScheme) IDocGenerator {
+	secured := *g
+	secured.security = append(append([]SecurityScheme{}, g.security...), schemes...)
+
+	return &secured
+}
+
+// withRedaction implements redactingGenerator
+func (g *swaggerGenerator) withRedaction(redaction Redaction) IDocGenerator {
+	redacted := *g
+	redacted.redaction = g.redaction.merge(redaction)
+
+	return &redacted
+}
+
+// withServer implements serverGenerator
+func (g *swaggerGenerator) withServer(base *url.URL) IDocGenerator {
+	served := *g
+	served.seed.Host = base.Host
+	served.seed.Schemes = []string{base.Scheme}
+	served.seed.BasePath = base.Path
+
+	return &served
+}
+
+// swaggerSecurityScheme converts security scheme into Swagger security definition
+func swaggerSecurityScheme(scheme SecurityScheme) *spec.SecurityScheme {
+	var definition *spec.SecurityScheme
+	switch scheme.Type {
+	case "basic":
+		definition = spec.BasicAuth()
+	case "oauth2":
+		definition = &spec.SecurityScheme{}
+		definition.Type = "oauth2"
+		definition.Flow = scheme.Flow
+		definition.AuthorizationURL = scheme.AuthorizationURL
+		definition.TokenURL = scheme.TokenURL
+		definition.Scopes = map[string]string{}
+		for _, scope := range scheme.Scopes {
+			definition.AddScope(scope, "")
+		}
+	default:
+		definition = spec.APIKeyAuth(scheme.ParamName, scheme.In)
+	}
+	definition.Description = scheme.Description
+
+	return definition
+}
+
+// generateSwaggerResponse merges test cases with given indexes into one response.
+// Swagger 2.0 allows only one example per media type and has no oneOf, so the
+// first test case provides them. Every case is kept as a named example in
+// 'x-examples' extension of the schema, different schemas are listed in 'x-oneof'.
+func generateSwaggerResponse(testCases []TestCase, indexes []int, defs spec.Definitions, redaction Redaction) spec.Response {
+	response := spec.Response{}
+	response.Description = joinTestCaseDescriptions(testCases, indexes)
+
+	var schemas []spec.Schema
+	examples := map[string]interface{}{}
+	names := exampleNames(testCases, indexes)
+	for _, caseIndex := range indexes {
+		testCase := testCases[caseIndex]
+		if testCase.ExpectedData == nil {
+			continue
+		}
+
+		example := redaction.RedactData(testCase.ExpectedData)
+		if response.Examples == nil {
+			response.Examples = map[string]interface{}{
+				testCase.responseMediaType(): example,
+			}
+		}
+		examples[names[caseIndex]] = example
+		schemas = appendUniqueSchema(schemas, *generateSpecSchema(testCase.ExpectedData, defs))
+	}
+
+	if len(schemas) == 0 {
+		return response
+	}
+
+	schema := schemas[0]
+	if len(examples) > 1 || len(schemas) > 1 {
+		// siblings of $ref are ignored, so the reference is wrapped
+		if schema.Ref.String() != "" {
+			schema = spec.Schema{}
+			schema.AllOf = []spec.Schema{schemas[0]}
+		}
+
+		schema.AddExtension("x-examples", examples)
+		if len(schemas) > 1 {
+			schema.AddExtension("x-oneof", schemas)
+		}
+	}
+	response.Schema = &schema
+
+	return response
+}
+
+// appendUniqueSchema appends schema to the list if the list does not contain
+// the same schema yet
+func appendUniqueSchema(schemas []spec.Schema, schema spec.Schema) []spec.Schema {
+	if containsSchema(schemas, schema) {
+		return schemas
+	}
+
+	return append(schemas, schema)
+}
+
+func containsSchema(schemas []spec.Schema, schema spec.Schema) bool {
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return false
+	}
+
+	for _, s := range schemas {
+		if existing, err := json.Marshal(s); err == nil && string(existing) == string(encoded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func generateSwaggerSpecParam(paramKey string, param Param, location string, variables map[string]interface{}) (spec.Parameter, error) {
 	specParam := spec.Parameter{}
 	specParam.Name = paramKey
 	specParam.In = location
 	specParam.Required = param.Required
 	specParam.Description = param.Description
-	specParam.Default = param.Value
+	value, isDefault := documentedParam(param, variables)
+	if isDefault {
+		specParam.Default = value
+	}
 
-	paramType, err := generateSpecSimpleType(param.Value)
+	paramType, err := generateSpecSimpleType(value)
 	if err != nil {
 		return specParam, fmt.Errorf("could not guess type of parameter '%s': %s", paramKey, err.Error())
 	}
@@ -200,8 +376,65 @@ func generateSwaggerSpecParam(paramKey string, param Param, location string) (sp
 	return specParam, nil
 }
 
+// generateSwaggerFormParams describes fields of form body as 'formData' parameters
+func generateSwaggerFormParams(body interface{}, redaction Redaction) ([]spec.Parameter, error) {
+	fields, err := formFields(body)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []spec.Parameter{}
+	for _, field := range fields {
+		specParam := spec.Parameter{}
+		specParam.Name = field.name
+		specParam.In = "formData"
+
+		var value interface{} = ""
+		if len(field.values) > 0 {
+			value = field.values[0]
+		}
+
+		if _, ok := value.(File); ok {
+			specParam.Type = "file"
+		} else if paramType, err := generateSpecSimpleType(value); err == nil {
+			specParam.Type = paramType
+			specParam.Default = value
+		} else {
+			// form fields are strings after all
+			specParam.Type = "string"
+			specParam.Default = formValueString(value)
+		}
+
+		// several values of the field are sent as a repeated field
+		if len(field.values) > 1 {
+			specParam.Items = &spec.Items{}
+			specParam.Items.Type = specParam.Type
+			specParam.Type = "array"
+			specParam.CollectionFormat = "multi"
+			specParam.Default = nil
+		}
+		if redaction.isSecretField(field.name) {
+			specParam.Default = nil
+		}
+
+		params = append(params, specParam)
+	}
+
+	return params, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 func generateSpecSchema(item interface{}, defs spec.Definitions) *spec.Schema {
-	refl := jsonschema.Reflect(item)
+	refl := reflectJsonSchema(item)
 	schema := specSchemaFromJsonType(refl.Type)
 
 	schema.Definitions = map[string]spec.Schema{}
@@ -0,0 +1,103 @@
+package schreder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+)
+
+// Hooks are called by the runner at different stages of a run, all of them
+// are optional. A hook that returns an error or panics fails the run, the test
+// case or the request it's called for.
+//
+// Hooks of a test case are called in the order of BeforeCase, SetUpCase of the
+// test, BeforeRequest, AfterResponse, TearDownCase of the test and AfterCase.
+// Hooks after a run or a test case are called even if it fails, panics or calls
+// t.FailNow, but not if hooks before it failed
+type Hooks struct {
+	// BeforeRun is called once before all tests, e.g. to seed a database
+	// through the API. Tests are not run if it fails
+	BeforeRun func(run *RunContext) error
+	// AfterRun is called once all tests are finished
+	AfterRun func(run *RunContext) error
+
+	// BeforeCase is called before every test case and may change it
+	BeforeCase func(run *RunContext, test Test, testCase *TestCase) error
+	// AfterCase is called after every test case, e.g. to reset state of the API
+	AfterCase func(run *RunContext, test Test, testCase TestCase, passed bool) error
+
+	// BeforeRequest is called with every request of test cases before it's
+	// authenticated and sent, it may change the request
+	BeforeRequest func(run *RunContext, req *http.Request) error
+	// AfterResponse is called with every response before its body is read and
+	// asserted, it may inspect or replace the response body
+	AfterResponse func(run *RunContext, req *http.Request, resp *http.Response) error
+}
+
+// RunContext is shared by hooks and tests of one run of the runner.
+// Fixtures created by hooks are usually put into variables of the run,
+// so test cases can refer them as "${name}"
+type RunContext struct {
+	// BaseUrl is the base URL of the API the tests are run against
+	BaseUrl string
+	// Vars are variables of the run, including the ones captured by test cases
+	Vars *Variables
+
+	runner *httpRunner
+	ctx    context.Context
+	// cleanupCtx outlives ctx a bit, so tests can clean up once the run is canceled
+	cleanupCtx context.Context
+}
+
+func newRunContext(ctx, cleanupCtx context.Context, r *httpRunner) *RunContext {
+	vars := newVariables()
+	for name, value := range r.Vars {
+		vars.Set(name, value)
+	}
+
+	return &RunContext{
+		BaseUrl:    r.BaseUrl,
+		Vars:       vars,
+		runner:     r,
+		ctx:        ctx,
+		cleanupCtx: cleanupCtx,
+	}
+}
+
+// Context returns the context of the run. It's canceled when the context given
+// to RunWithContext is or shortly before the deadline of 'go test -timeout'
+func (c *RunContext) Context() context.Context {
+	return c.ctx
+}
+
+// NewRequest creates a request to given path of the API with the context of the run
+func (c *RunContext) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(c.ctx, method, c.BaseUrl+path, body)
+}
+
+// Do sends a request to the API by the HTTP client of the runner, e.g. to
+// create fixtures of tests. The request is authenticated by the authenticator
+// of the runner, request hooks are not called
+func (c *RunContext) Do(req *http.Request) (*http.Response, error) {
+	if c.runner.Authenticator != nil {
+		if err := c.runner.Authenticator.Authenticate(req); err != nil {
+			return nil, fmt.Errorf("could not authenticate request: %s", err.Error())
+		}
+	}
+
+	return c.runner.HttpClient.Do(req)
+}
+
+// protect calls f and turns its panic into an error, so a panicking hook,
+// SetUp or TearDown fails the test rather than the whole run
+func protect(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+
+	return f()
+}
@@ -0,0 +1,183 @@
+package schreder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hookedItemTest struct {
+	calls *[]string
+}
+
+func (t *hookedItemTest) Method() string      { return "GET" }
+func (t *hookedItemTest) Description() string { return "Test for hooks of the runner" }
+func (t *hookedItemTest) Path() string        { return "/items/{id}" }
+func (t *hookedItemTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description:      "seeded item",
+			PathParams:       ParamMap{"id": Param{Value: "${itemID}"}},
+			ExpectedHttpCode: 200,
+			ExpectedData:     map[string]interface{}{"name": "seeded"},
+		},
+	}
+}
+func (t *hookedItemTest) SetUpCase(run *RunContext, testCase *TestCase) error {
+	*t.calls = append(*t.calls, "SetUpCase "+testCase.Description)
+	return nil
+}
+func (t *hookedItemTest) TearDownCase(run *RunContext, testCase TestCase) error {
+	*t.calls = append(*t.calls, "TearDownCase "+testCase.Description)
+	return nil
+}
+
+func TestRunHooks(t *testing.T) {
+	var mu sync.Mutex
+	items := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case "POST":
+			body, _ := ioutil.ReadAll(r.Body)
+			items["1"] = string(body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"1"}`)
+		case "GET":
+			if r.Header.Get("X-Trace") != "hooked" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"name":"%s"}`, items[strings.TrimPrefix(r.URL.Path, "/items/")])
+		}
+	}))
+	defer server.Close()
+
+	calls := []string{}
+	runner := NewRunner(server.URL, RunnerConfig{Hooks: Hooks{
+		BeforeRun: func(run *RunContext) error {
+			calls = append(calls, "BeforeRun")
+			req, err := run.NewRequest("POST", "/items", strings.NewReader("seeded"))
+			if err != nil {
+				return err
+			}
+			resp, err := run.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			run.Vars.Set("itemID", 1)
+			return nil
+		},
+		AfterRun: func(run *RunContext) error {
+			calls = append(calls, "AfterRun")
+			return nil
+		},
+		BeforeCase: func(run *RunContext, test Test, testCase *TestCase) error {
+			calls = append(calls, "BeforeCase "+testCase.Description)
+			return nil
+		},
+		AfterCase: func(run *RunContext, test Test, testCase TestCase, passed bool) error {
+			calls = append(calls, fmt.Sprintf("AfterCase %s passed=%t", testCase.Description, passed))
+			return nil
+		},
+		BeforeRequest: func(run *RunContext, req *http.Request) error {
+			calls = append(calls, "BeforeRequest "+req.URL.Path)
+			req.Header.Set("X-Trace", "hooked")
+			return nil
+		},
+		AfterResponse: func(run *RunContext, req *http.Request, resp *http.Response) error {
+			calls = append(calls, fmt.Sprintf("AfterResponse %d", resp.StatusCode))
+			return nil
+		},
+	}})
+	runner.Run(t, &hookedItemTest{calls: &calls})
+
+	assert.Equal(t, []string{
+		"BeforeRun",
+		"BeforeCase seeded item",
+		"SetUpCase seeded item",
+		"BeforeRequest /items/1",
+		"AfterResponse 200",
+		"TearDownCase seeded item",
+		"AfterCase seeded item passed=true",
+		"AfterRun",
+	}, calls)
+}
+
+func TestProtect(t *testing.T) {
+	err := errors.New("failed")
+	assert.Equal(t, err, protect(func() error { return err }))
+
+	err = protect(func() error { panic("boom") })
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "panic: boom\n"), err.Error())
+	}
+}
+
+type panickingTest struct{}
+
+func (t *panickingTest) Method() string      { return "GET" }
+func (t *panickingTest) Description() string { return "Test that panics" }
+func (t *panickingTest) Path() string        { return "/panic" }
+func (t *panickingTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			Description:      "panicking case",
+			ExpectedHttpCode: 200,
+			AssertResponse:   func(t *testing.T, expected interface{}, responseBody []byte) bool { panic("boom") },
+		},
+		{
+			Description:      "stopped case",
+			ExpectedHttpCode: 200,
+			AssertResponse: func(t *testing.T, expected interface{}, responseBody []byte) bool {
+				t.FailNow()
+				return false
+			},
+		},
+	}
+}
+func (t *panickingTest) TearDown() error {
+	fmt.Println("panicking test is torn down")
+	return nil
+}
+
+// TestRunTearsDownAfterPanic runs itself in a separate process, because
+// the test it runs fails
+func TestRunTearsDownAfterPanic(t *testing.T) {
+	if os.Getenv("SCHREDER_PANICKING_TEST") == "1" {
+		client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		})
+		runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client, Hooks: Hooks{
+			AfterCase: func(run *RunContext, test Test, testCase TestCase, passed bool) error {
+				fmt.Printf("after %s: passed=%t\n", testCase.Description, passed)
+				return nil
+			},
+		}})
+		runner.Run(t, &panickingTest{})
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunTearsDownAfterPanic$")
+	cmd.Env = append(os.Environ(), "SCHREDER_PANICKING_TEST=1")
+	out, err := cmd.CombinedOutput()
+
+	assert.Error(t, err, "test fails")
+	assert.Contains(t, string(out), "test case panicked: boom")
+	assert.Contains(t, string(out), "after panicking case: passed=false")
+	assert.Contains(t, string(out), "after stopped case: passed=false")
+	assert.Contains(t, string(out), "panicking test is torn down")
+}
@@ -0,0 +1,24 @@
+// Package jsonvalue contains helpers for values in generic JSON representation
+// that are shared by schreder packages
+package jsonvalue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Describe formats a value of generic JSON representation for messages
+func Describe(value interface{}) string {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(js)
+}
+
+// EscapeToken escapes a reference token of JSON pointer as defined by RFC 6901
+func EscapeToken(token string) string {
+	token = strings.Replace(token, "~", "~0", -1)
+	return strings.Replace(token, "/", "~1", -1)
+}
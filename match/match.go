@@ -0,0 +1,353 @@
+// Package match provides matchers that can be used in expected data of test
+// cases instead of exact values, e.g. for IDs and timestamps generated by server:
+//
+//	ExpectedData: map[string]interface{}{
+//		"id":      match.Type(1),
+//		"name":    "New User",
+//		"created": match.RFC3339(),
+//	},
+//
+// Matchers can be put anywhere in expected data: into maps, slices and struct
+// fields of interface type. Documentation generators use examples of matchers
+// to derive schemas and examples of the documentation.
+package match
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/testmeifyoucan/schreder/internal/jsonvalue"
+)
+
+// Matcher checks a value decoded from JSON response: a map, a slice,
+// float64, string, bool or nil
+type Matcher interface {
+	// Match returns an error that describes why actual value does not match
+	Match(actual interface{}) error
+	// Example returns a value that represents matched values in documentation
+	Example() interface{}
+}
+
+// Mismatch describes a value of response that does not match expected data
+type Mismatch struct {
+	// Path is a JSON pointer to the value
+	Path    string
+	Message string
+}
+
+func (m Mismatch) String() string {
+	path := m.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, m.Message)
+}
+
+// Mismatches is an error that lists all found mismatches
+type Mismatches []Mismatch
+
+func (m Mismatches) Error() string {
+	lines := make([]string, len(m))
+	for i, mismatch := range m {
+		lines[i] = mismatch.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Match checks that actual value decoded from JSON matches expected data.
+// Returned error is Mismatches if there are differences between the values
+func Match(expected, actual interface{}) error {
+	normalized, _, err := normalize(expected)
+	if err != nil {
+		return err
+	}
+
+	if mismatches := compare("", normalized, actual, false); len(mismatches) > 0 {
+		return mismatches
+	}
+	return nil
+}
+
+// HasMatchers reports whether given data contains any matchers
+func HasMatchers(data interface{}) bool {
+	_, hasMatchers, _ := normalize(data)
+	return hasMatchers
+}
+
+// Example returns a value that represents given data in documentation:
+// example of the matcher or the data itself if it's not a matcher
+func Example(data interface{}) interface{} {
+	for {
+		m, ok := data.(Matcher)
+		if !ok {
+			return data
+		}
+		data = m.Example()
+	}
+}
+
+// normalize converts data into generic JSON representation (maps, slices and
+// primitives) keeping matchers as is. Reports whether data contains matchers
+func normalize(data interface{}) (interface{}, bool, error) {
+	if data == nil {
+		return nil, false, nil
+	}
+
+	switch data.(type) {
+	case Matcher:
+		return data, true, nil
+	case json.Marshaler, encoding.TextMarshaler:
+		value, err := toJSONValue(data)
+		return value, false, err
+	}
+
+	value := reflect.ValueOf(data)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, false, nil
+		}
+		return normalize(value.Elem().Interface())
+
+	case reflect.Map:
+		if value.IsNil() {
+			return nil, false, nil
+		}
+		result := map[string]interface{}{}
+		hasMatchers := false
+		for _, key := range value.MapKeys() {
+			item, itemHasMatchers, err := normalize(value.MapIndex(key).Interface())
+			if err != nil {
+				return nil, false, err
+			}
+			result[fmt.Sprintf("%v", key.Interface())] = item
+			hasMatchers = hasMatchers || itemHasMatchers
+		}
+		return result, hasMatchers, nil
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return nil, false, nil
+		}
+		if value.Type().Elem().Kind() == reflect.Uint8 { // encoded as base64 string
+			result, err := toJSONValue(data)
+			return result, false, err
+		}
+		result := make([]interface{}, value.Len())
+		hasMatchers := false
+		for i := range result {
+			item, itemHasMatchers, err := normalize(value.Index(i).Interface())
+			if err != nil {
+				return nil, false, err
+			}
+			result[i] = item
+			hasMatchers = hasMatchers || itemHasMatchers
+		}
+		return result, hasMatchers, nil
+
+	case reflect.Struct:
+		result := map[string]interface{}{}
+		hasMatchers, err := normalizeStruct(value, result)
+		return result, hasMatchers, err
+	}
+
+	result, err := toJSONValue(data)
+	return result, false, err
+}
+
+// normalizeStruct puts fields of the struct into result following the rules
+// of encoding/json: field names and options are taken from 'json' tags,
+// fields of embedded structs are promoted
+func normalizeStruct(value reflect.Value, result map[string]interface{}) (bool, error) {
+	hasMatchers := false
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+
+		if field.Anonymous && name == "" {
+			for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				embeddedHasMatchers, err := normalizeStruct(fieldValue, result)
+				if err != nil {
+					return false, err
+				}
+				hasMatchers = hasMatchers || embeddedHasMatchers
+				continue
+			}
+		}
+
+		if field.PkgPath != "" || !fieldValue.CanInterface() { // unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if hasOption(options[1:], "omitempty") && isEmptyValue(fieldValue) {
+			continue
+		}
+
+		item, itemHasMatchers, err := normalize(fieldValue.Interface())
+		if err != nil {
+			return false, err
+		}
+		result[name] = item
+		hasMatchers = hasMatchers || itemHasMatchers
+	}
+
+	return hasMatchers, nil
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func toJSONValue(data interface{}) (interface{}, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(js, &result)
+	return result, err
+}
+
+// compare walks through normalized expected data and actual value and collects
+// all mismatches. If partial is set, objects may have properties that are not expected
+func compare(path string, expected, actual interface{}, partial bool) Mismatches {
+	switch expected := expected.(type) {
+	case Matcher:
+		return matchAt(path, expected, actual)
+
+	case map[string]interface{}:
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return mismatchAt(path, "expected an object, got %s", jsonvalue.Describe(actual))
+		}
+
+		mismatches := Mismatches{}
+		for _, key := range sortedKeys(expected) {
+			keyPath := path + "/" + jsonvalue.EscapeToken(key)
+			actualValue, ok := actualMap[key]
+			if !ok {
+				mismatches = append(mismatches, Mismatch{Path: keyPath, Message: "is missing"})
+				continue
+			}
+			mismatches = append(mismatches, compare(keyPath, expected[key], actualValue, partial)...)
+		}
+		if !partial {
+			for _, key := range sortedKeys(actualMap) {
+				if _, ok := expected[key]; !ok {
+					mismatches = append(mismatches, Mismatch{Path: path + "/" + jsonvalue.EscapeToken(key), Message: "is not expected"})
+				}
+			}
+		}
+		return mismatches
+
+	case []interface{}:
+		actualSlice, ok := actual.([]interface{})
+		if !ok {
+			return mismatchAt(path, "expected an array, got %s", jsonvalue.Describe(actual))
+		}
+		if len(expected) != len(actualSlice) {
+			return mismatchAt(path, "expected %d items, got %d", len(expected), len(actualSlice))
+		}
+
+		mismatches := Mismatches{}
+		for i, item := range expected {
+			mismatches = append(mismatches, compare(fmt.Sprintf("%s/%d", path, i), item, actualSlice[i], partial)...)
+		}
+		return mismatches
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return mismatchAt(path, "expected %s, got %s", jsonvalue.Describe(expected), jsonvalue.Describe(actual))
+	}
+	return nil
+}
+
+// matchAt runs the matcher and puts its mismatches under given path
+func matchAt(path string, m Matcher, actual interface{}) Mismatches {
+	err := m.Match(actual)
+	if err == nil {
+		return nil
+	}
+
+	if mismatches, ok := err.(Mismatches); ok {
+		result := make(Mismatches, len(mismatches))
+		for i, mismatch := range mismatches {
+			result[i] = Mismatch{Path: path + mismatch.Path, Message: mismatch.Message}
+		}
+		return result
+	}
+
+	return Mismatches{{Path: path, Message: err.Error()}}
+}
+
+func mismatchAt(path, format string, args ...interface{}) Mismatches {
+	return Mismatches{{Path: path, Message: fmt.Sprintf(format, args...)}}
+}
+
+// jsonType returns a name of JSON type of the value in generic representation
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,87 @@
+package match
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID      interface{} `json:"id"`
+	Login   string      `json:"login,omitempty"`
+	Created interface{} `json:"created,omitempty"`
+	Ignored string      `json:"-"`
+	private string
+}
+
+type admin struct {
+	user
+	Level int `json:"level"`
+}
+
+func decode(t *testing.T, js string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(js), &value); err != nil {
+		t.Fatalf("invalid JSON: %s", err.Error())
+	}
+	return value
+}
+
+func TestMatchers(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected interface{}
+		actual   string
+		err      string
+	}{
+		{"exact", map[string]interface{}{"a": 1, "b": []string{"x"}}, `{"a": 1, "b": ["x"]}`, ""},
+		{"exact mismatch", map[string]interface{}{"a": 1}, `{"a": 2, "b": 3}`, "/a: expected 1, got 2\n/b: is not expected"},
+		{"any", map[string]interface{}{"a": Any()}, `{"a": null}`, ""},
+		{"type", map[string]interface{}{"a": Type(1)}, `{"a": 42}`, ""},
+		{"type mismatch", map[string]interface{}{"a": Type(1)}, `{"a": "42"}`, `/a: expected a value of type number, got "42"`},
+		{"regex", Regex(`^[a-f0-9]{8}$`, "deadbeef"), `"0123abcd"`, ""},
+		{"regex mismatch", Regex(`^[a-f0-9]{8}$`, "deadbeef"), `"xyz"`, `/: expected a string matching '^[a-f0-9]{8}$', got "xyz"`},
+		{"range", []interface{}{Range(1, 10)}, `[10]`, ""},
+		{"range mismatch", []interface{}{Range(1, 10)}, `[11]`, "/0: expected a number in range [1, 10], got 11"},
+		{"rfc3339", RFC3339(), `"2016-10-16T10:00:00.123+02:00"`, ""},
+		{"rfc3339 mismatch", RFC3339(), `"16.10.2016"`, `/: expected RFC 3339 time, got "16.10.2016"`},
+		{"subset", Subset(map[string]interface{}{"a": map[string]interface{}{"b": 1}}), `{"a": {"b": 1, "c": 2}, "d": 3}`, ""},
+		{"subset mismatch", Subset(map[string]interface{}{"a": map[string]interface{}{"b": 1}}), `{"a": {"c": 2}}`, "/a/b: is missing"},
+		{"contains", Contains(2, Subset(map[string]interface{}{"id": 1})), `[{"id": 1, "x": true}, 2, 3]`, ""},
+		{"contains mismatch", Contains(4), `[1, 2, 3]`, "/: expected an array containing 4"},
+		{"unordered", Unordered(1, Type(""), 3), `[3, 1, "2"]`, ""},
+		{"unordered mismatch", Unordered(1, 2), `[2, 2]`, "/: expected items [1,2] in any order, got [2,2]"},
+		{"struct", user{ID: Type(1), Login: "octocat", Ignored: "x", private: "y"}, `{"id": 5, "login": "octocat"}`, ""},
+		{"embedded struct", admin{user: user{ID: 1, Created: RFC3339()}, Level: 2}, `{"id": 1, "created": "2016-10-16T10:00:00Z", "level": 2}`, ""},
+		{"with example", WithExample(map[string]interface{}{"id": Any()}, user{ID: 1}), `{"id": "x"}`, ""},
+	}
+
+	for _, c := range cases {
+		err := Match(c.expected, decode(t, c.actual))
+		if c.err == "" {
+			assert.NoError(t, err, c.name)
+		} else if assert.Error(t, err, c.name) {
+			assert.Equal(t, c.err, err.Error(), c.name)
+		}
+	}
+}
+
+func TestHasMatchers(t *testing.T) {
+	assert.False(t, HasMatchers(user{ID: 1}))
+	assert.False(t, HasMatchers(map[string]interface{}{"a": []int{1}}))
+	assert.True(t, HasMatchers(&user{ID: Any()}))
+	assert.True(t, HasMatchers([]interface{}{map[string]interface{}{"a": Range(0, 1)}}))
+}
+
+func TestExample(t *testing.T) {
+	assert.Equal(t, user{ID: 1}, Example(WithExample(Any(), user{ID: 1})))
+	assert.Equal(t, []int{1, 2}, Example(Unordered(1, Type(2))))
+	assert.Equal(t, []interface{}{1, "a"}, Example(Contains(1, "a")))
+	assert.IsType(t, time.Time{}, Example(RFC3339()))
+
+	js, err := json.Marshal(map[string]interface{}{"id": Type(7), "created": RFC3339()})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id": 7, "created": "2016-01-02T15:04:05Z"}`, string(js))
+}
@@ -0,0 +1,256 @@
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/testmeifyoucan/schreder/internal/jsonvalue"
+)
+
+// matcher is the implementation of all matchers of the package.
+// It's encoded to JSON as its example, so expected data with matchers
+// can be used as an example of documentation as is
+type matcher struct {
+	match   func(actual interface{}) error
+	example interface{}
+}
+
+func (m *matcher) Match(actual interface{}) error { return m.match(actual) }
+func (m *matcher) Example() interface{}           { return m.example }
+
+func (m *matcher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Example(m.example))
+}
+
+// Any matches any value, including null
+func Any() Matcher {
+	return &matcher{
+		match: func(actual interface{}) error { return nil },
+	}
+}
+
+// Type matches any value of the same JSON type as given example
+func Type(example interface{}) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			normalized, _, err := normalize(Example(example))
+			if err != nil {
+				return err
+			}
+
+			expectedType := jsonType(normalized)
+			if actualType := jsonType(actual); actualType != expectedType {
+				return fmt.Errorf("expected a value of type %s, got %s", expectedType, jsonvalue.Describe(actual))
+			}
+			return nil
+		},
+		example: example,
+	}
+}
+
+// Regex matches strings that match given regular expression.
+// Example is used to document the value
+func Regex(pattern string, example string) Matcher {
+	re := regexp.MustCompile(pattern)
+
+	return &matcher{
+		match: func(actual interface{}) error {
+			s, ok := actual.(string)
+			if !ok || !re.MatchString(s) {
+				return fmt.Errorf("expected a string matching '%s', got %s", pattern, jsonvalue.Describe(actual))
+			}
+			return nil
+		},
+		example: example,
+	}
+}
+
+// Range matches numbers between min and max inclusively
+func Range(min, max float64) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			n, ok := toFloat(actual)
+			if !ok || n < min || n > max {
+				return fmt.Errorf("expected a number in range [%v, %v], got %s", min, max, jsonvalue.Describe(actual))
+			}
+			return nil
+		},
+		example: min,
+	}
+}
+
+// RFC3339 matches strings that contain time in RFC 3339 format
+func RFC3339() Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			s, ok := actual.(string)
+			if ok {
+				if _, err := time.Parse(time.RFC3339, s); err == nil {
+					return nil
+				}
+			}
+			return fmt.Errorf("expected RFC 3339 time, got %s", jsonvalue.Describe(actual))
+		},
+		example: time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+}
+
+// Subset matches objects that have all properties of expected object.
+// Properties that are not expected are ignored, the same applies to all
+// nested objects
+func Subset(expected interface{}) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			normalized, _, err := normalize(expected)
+			if err != nil {
+				return err
+			}
+			if mismatches := compare("", normalized, actual, true); len(mismatches) > 0 {
+				return mismatches
+			}
+			return nil
+		},
+		example: expected,
+	}
+}
+
+// Contains matches arrays that contain all given items in any order
+func Contains(items ...interface{}) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			actualItems, expectedItems, err := prepareItems(items, actual)
+			if err != nil {
+				return err
+			}
+
+			for i, expected := range expectedItems {
+				found := false
+				for _, item := range actualItems {
+					if len(compare("", expected, item, false)) == 0 {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return fmt.Errorf("expected an array containing %s", jsonvalue.Describe(Example(items[i])))
+				}
+			}
+			return nil
+		},
+		example: exampleSlice(items),
+	}
+}
+
+// Unordered matches arrays that consist of given items in any order
+func Unordered(items ...interface{}) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			actualItems, expectedItems, err := prepareItems(items, actual)
+			if err != nil {
+				return err
+			}
+			if len(actualItems) != len(expectedItems) {
+				return fmt.Errorf("expected %d items, got %d", len(expectedItems), len(actualItems))
+			}
+			if !matchUnordered(expectedItems, actualItems, make([]bool, len(actualItems))) {
+				return fmt.Errorf("expected items %s in any order, got %s", jsonvalue.Describe(exampleSlice(items)), jsonvalue.Describe(actual))
+			}
+			return nil
+		},
+		example: exampleSlice(items),
+	}
+}
+
+// WithExample matches values that match expected data, but documents them
+// with given example. It's useful when expected data consists of matchers
+// that hide the type of the value, e.g. a map instead of a struct
+func WithExample(expected interface{}, example interface{}) Matcher {
+	return &matcher{
+		match: func(actual interface{}) error {
+			return Match(expected, actual)
+		},
+		example: example,
+	}
+}
+
+// matchUnordered looks for a permutation of actual items that matches expected items
+func matchUnordered(expected, actual []interface{}, used []bool) bool {
+	if len(expected) == 0 {
+		return true
+	}
+
+	for i, item := range actual {
+		if used[i] || len(compare("", expected[0], item, false)) > 0 {
+			continue
+		}
+
+		used[i] = true
+		if matchUnordered(expected[1:], actual, used) {
+			return true
+		}
+		used[i] = false
+	}
+
+	return false
+}
+
+func prepareItems(items []interface{}, actual interface{}) ([]interface{}, []interface{}, error) {
+	actualItems, ok := actual.([]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected an array, got %s", jsonvalue.Describe(actual))
+	}
+
+	expectedItems := make([]interface{}, len(items))
+	for i, item := range items {
+		normalized, _, err := normalize(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		expectedItems[i] = normalized
+	}
+
+	return actualItems, expectedItems, nil
+}
+
+// exampleSlice builds an example of an array from examples of its items.
+// If all the items are of the same type, the slice is typed, so a schema
+// of its items can be derived
+func exampleSlice(items []interface{}) interface{} {
+	examples := make([]interface{}, len(items))
+	var itemType reflect.Type
+	for i, item := range items {
+		examples[i] = Example(item)
+		if examples[i] == nil {
+			return examples
+		}
+
+		t := reflect.TypeOf(examples[i])
+		if itemType != nil && t != itemType {
+			return examples
+		}
+		itemType = t
+	}
+	if itemType == nil {
+		return examples
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(itemType), len(examples), len(examples))
+	for i, example := range examples {
+		slice.Index(i).Set(reflect.ValueOf(example))
+	}
+	return slice.Interface()
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,361 @@
+// Package mock turns test definitions into a mock of the API they describe.
+// Each test case pairs a request with its response, so the mock answers
+// a request with the response of the test case that fits it best:
+//
+//	http.ListenAndServe(":1323", mock.NewHandler(tests...))
+//
+// Frontend teams can develop against the mock before the API is ready,
+// and tests of the definitions themselves can run with no server at all.
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/testmeifyoucan/schreder"
+	"github.com/testmeifyoucan/schreder/match"
+)
+
+var (
+	templateParamRegexp = regexp.MustCompile(`\{([^}]*)\}`)
+	variableRegexp      = regexp.MustCompile(`\$\{[^}]+\}`)
+)
+
+// route is a path template of a test compiled into a regular expression
+type route struct {
+	test    schreder.Test
+	pattern *regexp.Regexp
+	params  []string
+}
+
+type handler struct {
+	routes []route
+}
+
+// NewHandler creates an HTTP handler that responds to requests described
+// by test cases of given tests.
+//
+// A request is handled by tests with the same method and path template.
+// Among their test cases it's answered by the one which path parameters,
+// query parameters, headers and body fit the request best: every value that
+// equals to the one of the request makes the case fit better, a case with
+// a value that differs is not used. Content-Type and Accept headers are compared
+// by media types, JSON and form bodies structurally. Values referring variables
+// of the run, like '${user_id}', are ignored. Of equally fitting cases the first
+// one is used. Requests that no test case fits get 404 response.
+//
+// Response has status code and headers expected by the test case, body is
+// encoded from its expected data by Content-Type of the expected headers.
+// Matchers in expected data are replaced by their examples.
+func NewHandler(tests ...schreder.Test) http.Handler {
+	h := &handler{}
+	for _, test := range tests {
+		h.routes = append(h.routes, newRoute(test))
+	}
+
+	return h
+}
+
+// NewClient creates a client that passes requests directly to the handler
+// of given tests, with no network involved. It can be used as HttpClient
+// of the runner
+func NewClient(tests ...schreder.Test) schreder.IHttpClient {
+	h := NewHandler(tests...)
+
+	return schreder.IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, req)
+
+		resp := recorder.Result()
+		resp.Request = req
+		return resp, nil
+	})
+}
+
+func newRoute(test schreder.Test) route {
+	path := test.Path()
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	r := route{test: test}
+	pattern := "^"
+	last := 0
+	for _, loc := range templateParamRegexp.FindAllStringSubmatchIndex(path, -1) {
+		pattern += regexp.QuoteMeta(path[last:loc[0]])
+		last = loc[1]
+
+		name := path[loc[2]:loc[3]]
+		// query expressions of URI templates, like '{?page}', do not belong to the path
+		if strings.HasPrefix(name, "?") || strings.HasPrefix(name, "&") {
+			continue
+		}
+		r.params = append(r.params, name)
+		pattern += "([^/]+)"
+	}
+	pattern += regexp.QuoteMeta(path[last:]) + "$"
+	r.pattern = regexp.MustCompile(pattern)
+
+	return r
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	testCase, ok := h.findTestCase(req, body)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no test case matches %s %s", req.Method, req.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	if err := writeResponse(w, testCase); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// findTestCase looks for the test case that fits the request best
+func (h *handler) findTestCase(req *http.Request, body []byte) (schreder.TestCase, bool) {
+	var best schreder.TestCase
+	bestScore := 0
+	found := false
+
+	for _, r := range h.routes {
+		if !strings.EqualFold(r.test.Method(), req.Method) {
+			continue
+		}
+		submatches := r.pattern.FindStringSubmatch(req.URL.Path)
+		if submatches == nil {
+			continue
+		}
+
+		pathValues := map[string]string{}
+		for i, name := range r.params {
+			pathValues[name] = submatches[i+1]
+		}
+
+		for _, testCase := range r.test.TestCases() {
+			score := scoreTestCase(testCase, req, pathValues, body)
+			if score < 0 {
+				// the request contradicts parameters or body of the test case
+				continue
+			}
+			if !found || score > bestScore {
+				best, bestScore, found = testCase, score, true
+			}
+		}
+	}
+
+	return best, found
+}
+
+// scoreTestCase counts parameters and body of the test case that are equal to
+// the ones of the request. Returns -1 if any of them contradicts the request
+func scoreTestCase(testCase schreder.TestCase, req *http.Request, pathValues map[string]string, body []byte) int {
+	scores := []int{}
+	for name, param := range testCase.PathParams {
+		value, ok := pathValues[name]
+		scores = append(scores, scoreParam(param, value, ok))
+	}
+
+	query := req.URL.Query()
+	for name, param := range testCase.QueryParams {
+		_, ok := query[name]
+		scores = append(scores, scoreParam(param, query.Get(name), ok))
+	}
+
+	for name, param := range testCase.Headers {
+		_, ok := req.Header[http.CanonicalHeaderKey(name)]
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Type":
+			scores = append(scores, scoreMediaType(param, req.Header.Get(name), ok, false))
+		case "Accept":
+			scores = append(scores, scoreMediaType(param, req.Header.Get(name), ok, true))
+		default:
+			scores = append(scores, scoreParam(param, req.Header.Get(name), ok))
+		}
+	}
+
+	if testCase.RequestBody != nil && !hasVariables(testCase.RequestBody) {
+		if bodyEquals(testCase, req.Header.Get("Content-Type"), body) {
+			scores = append(scores, 1)
+		} else {
+			scores = append(scores, -1)
+		}
+	}
+
+	score := 0
+	for _, s := range scores {
+		if s < 0 {
+			return -1
+		}
+		score += s
+	}
+
+	return score
+}
+
+func scoreParam(param schreder.Param, value string, ok bool) int {
+	expected := fmt.Sprintf("%v", param.Value)
+	switch {
+	case variableRegexp.MatchString(expected):
+		return 0
+	case ok && expected == value:
+		return 1
+	}
+
+	return -1
+}
+
+// scoreMediaType scores a header that holds media types. Media types are
+// compared with parameters the test case declares, e.g. charset, so the request
+// may have more of them, like boundary of multipart body. If the header is
+// a list, like Accept, any media type of the list may match
+func scoreMediaType(param schreder.Param, value string, ok bool, list bool) int {
+	expected := fmt.Sprintf("%v", param.Value)
+	expectedType, expectedParams, err := mime.ParseMediaType(expected)
+	if err != nil || !ok {
+		return scoreParam(param, value, ok)
+	}
+
+	values := []string{value}
+	if list {
+		values = strings.Split(value, ",")
+	}
+	for _, value := range values {
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err == nil && mediaType == expectedType && hasParams(params, expectedParams) {
+			return 1
+		}
+	}
+
+	return -1
+}
+
+func hasParams(params, expected map[string]string) bool {
+	for name, value := range expected {
+		if !strings.EqualFold(params[name], value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bodyEquals checks that request body of given content type equals to the body
+// of the test case. JSON and form bodies are compared structurally
+func bodyEquals(testCase schreder.TestCase, contentType string, body []byte) bool {
+	caseContentType := ""
+	for name, param := range testCase.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			caseContentType = fmt.Sprintf("%v", param.Value)
+		}
+	}
+
+	expected, expectedContentType, err := schreder.EncodeBody(testCase.RequestBody, caseContentType)
+	if err != nil {
+		return false
+	}
+	if bytes.Equal(expected, body) {
+		return true
+	}
+
+	// boundaries of multipart bodies differ unless the test case defines one
+	if expectedForm, ok := parseForm(expected, expectedContentType); ok {
+		form, ok := parseForm(body, contentType)
+		return ok && reflect.DeepEqual(expectedForm, form)
+	}
+
+	var expectedValue, actualValue interface{}
+	if json.Unmarshal(expected, &expectedValue) != nil || json.Unmarshal(body, &actualValue) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(expectedValue, actualValue)
+}
+
+// parseForm parses URL encoded and multipart form bodies into values of their
+// fields, content of a file is the value of its field
+func parseForm(body []byte, contentType string) (map[string][]string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		return map[string][]string(values), err == nil
+
+	case "multipart/form-data":
+		form := map[string][]string{}
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return form, true
+			}
+			if err != nil {
+				return nil, false
+			}
+			content, err := ioutil.ReadAll(part)
+			if err != nil {
+				return nil, false
+			}
+			form[part.FormName()] = append(form[part.FormName()], string(content))
+		}
+	}
+
+	return nil, false
+}
+
+func hasVariables(body interface{}) bool {
+	js, err := json.Marshal(body)
+	return err == nil && variableRegexp.Match(js)
+}
+
+func writeResponse(w http.ResponseWriter, testCase schreder.TestCase) error {
+	contentType := ""
+	for name, value := range testCase.ExpectedHeaders {
+		w.Header().Set(name, value)
+		if strings.EqualFold(name, "Content-Type") {
+			contentType = value
+		}
+	}
+
+	data := match.Example(testCase.ExpectedData)
+	if data == nil {
+		w.WriteHeader(testCase.ExpectedHttpCode)
+		return nil
+	}
+
+	body, contentType, err := schreder.EncodeBody(data, contentType)
+	if err != nil {
+		return fmt.Errorf("could not encode expected data: %s", err.Error())
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(testCase.ExpectedHttpCode)
+	_, err = w.Write(body)
+
+	return err
+}
@@ -0,0 +1,252 @@
+package mock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/testmeifyoucan/schreder"
+	"github.com/testmeifyoucan/schreder/match"
+)
+
+type user struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+type getUserTest struct{}
+
+func (t *getUserTest) Method() string      { return "GET" }
+func (t *getUserTest) Description() string { return "Get user" }
+func (t *getUserTest) Path() string        { return "/users/{id}" }
+func (t *getUserTest) TestCases() []schreder.TestCase {
+	return []schreder.TestCase{
+		{
+			Description:      "user found",
+			PathParams:       schreder.ParamMap{"id": schreder.Param{Value: 1}},
+			ExpectedHttpCode: 200,
+			ExpectedData:     user{ID: 1, Name: "a"},
+		},
+		{
+			Description:      "user not found",
+			PathParams:       schreder.ParamMap{"id": schreder.Param{Value: 2}},
+			ExpectedHttpCode: 404,
+			ExpectedHeaders:  map[string]string{"Content-Type": "text/plain"},
+			ExpectedData:     "user 2 not found",
+		},
+		{
+			Description:      "user as XML",
+			PathParams:       schreder.ParamMap{"id": schreder.Param{Value: 1}},
+			Headers:          schreder.ParamMap{"Accept": schreder.Param{Value: "application/xml"}},
+			ExpectedHttpCode: 200,
+			ExpectedHeaders:  map[string]string{"Content-Type": "application/xml"},
+			ExpectedData:     user{ID: 1, Name: "a"},
+		},
+	}
+}
+
+type createUserTest struct{}
+
+func (t *createUserTest) Method() string      { return "POST" }
+func (t *createUserTest) Description() string { return "Create user" }
+func (t *createUserTest) Path() string        { return "/users" }
+func (t *createUserTest) TestCases() []schreder.TestCase {
+	return []schreder.TestCase{
+		{
+			Description:      "user created",
+			RequestBody:      map[string]interface{}{"name": "b"},
+			ExpectedHttpCode: 201,
+			ExpectedData: match.WithExample(
+				map[string]interface{}{"id": match.Type(3), "name": "b"},
+				user{ID: 3, Name: "b"},
+			),
+		},
+		{
+			Description:      "empty name",
+			RequestBody:      map[string]interface{}{"name": ""},
+			ExpectedHttpCode: 422,
+		},
+	}
+}
+
+type listUsersTest struct{}
+
+func (t *listUsersTest) Method() string      { return "GET" }
+func (t *listUsersTest) Description() string { return "List users" }
+func (t *listUsersTest) Path() string        { return "/users{?page}" }
+func (t *listUsersTest) TestCases() []schreder.TestCase {
+	return []schreder.TestCase{
+		{
+			QueryParams:      schreder.ParamMap{"page": schreder.Param{Value: 1}},
+			ExpectedHttpCode: 200,
+			ExpectedData:     []user{{ID: 1, Name: "a"}},
+		},
+		{
+			QueryParams:      schreder.ParamMap{"page": schreder.Param{Value: 2}},
+			ExpectedHttpCode: 200,
+			ExpectedData:     []user{},
+		},
+	}
+}
+
+type uploadAvatarTest struct{}
+
+func (t *uploadAvatarTest) Method() string      { return "POST" }
+func (t *uploadAvatarTest) Description() string { return "Upload avatar" }
+func (t *uploadAvatarTest) Path() string        { return "/users/{id}/avatar" }
+func (t *uploadAvatarTest) TestCases() []schreder.TestCase {
+	avatar := func(content string) map[string]interface{} {
+		return map[string]interface{}{
+			"title": "me",
+			"file":  &schreder.File{Name: "me.png", ContentType: "image/png", Content: []byte(content)},
+		}
+	}
+
+	return []schreder.TestCase{
+		{
+			Description:      "avatar uploaded",
+			PathParams:       schreder.ParamMap{"id": schreder.Param{Value: 1}},
+			Headers:          schreder.ParamMap{"Content-Type": schreder.Param{Value: "multipart/form-data"}},
+			RequestBody:      avatar("png"),
+			ExpectedHttpCode: 204,
+		},
+		{
+			Description:      "avatar is not an image",
+			PathParams:       schreder.ParamMap{"id": schreder.Param{Value: 1}},
+			Headers:          schreder.ParamMap{"Content-Type": schreder.Param{Value: "multipart/form-data"}},
+			RequestBody:      avatar("txt"),
+			ExpectedHttpCode: 422,
+		},
+	}
+}
+
+func tests() []schreder.Test {
+	return []schreder.Test{&getUserTest{}, &createUserTest{}, &listUsersTest{}, &uploadAvatarTest{}}
+}
+
+func TestRunAgainstMock(t *testing.T) {
+	runner := schreder.NewRunner("http://testapi.my", schreder.RunnerConfig{HttpClient: NewClient(tests()...)})
+	runner.Run(t, tests()...)
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(tests()...))
+	defer server.Close()
+
+	cases := []struct {
+		method, path, body string
+		headers            map[string]string
+		code               int
+		contentType        string
+		expected           string
+	}{
+		{"GET", "/users/1", "", nil, 200, "application/json", `{"id":1,"name":"a"}`},
+		{"GET", "/users/1", "", map[string]string{"Accept": "application/xml"}, 200,
+			"application/xml", `<user><id>1</id><name>a</name></user>`},
+		{"GET", "/users/1", "", map[string]string{"Accept": "text/html, application/xml;q=0.9"}, 200,
+			"application/xml", `<user><id>1</id><name>a</name></user>`},
+		{"GET", "/users/2", "", nil, 404, "text/plain", `user 2 not found`},
+		// requests that contradict every test case are not answered by any of them
+		{"GET", "/users/42", "", nil, 404, "text/plain; charset=utf-8", "no test case matches GET /users/42\n"},
+		{"POST", "/users", `{"name": "c"}`, nil, 404, "text/plain; charset=utf-8", "no test case matches POST /users\n"},
+		{"POST", "/users", `{"name": "b"}`, nil, 201, "application/json", `{"id":3,"name":"b"}`},
+		{"POST", "/users", `{"name":""}`, nil, 422, "", ``},
+		{"GET", "/users?page=2", "", nil, 200, "application/json", `[]`},
+		{"DELETE", "/users/1", "", nil, 404, "text/plain; charset=utf-8", "no test case matches DELETE /users/1\n"},
+		{"GET", "/users/1/avatar", "", nil, 404, "text/plain; charset=utf-8", "no test case matches GET /users/1/avatar\n"},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, server.URL+c.path, strings.NewReader(c.body))
+		for name, value := range c.headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, c.code, resp.StatusCode, "%s %s", c.method, c.path)
+		assert.Equal(t, c.contentType, resp.Header.Get("Content-Type"), "%s %s", c.method, c.path)
+		assert.Equal(t, c.expected, string(body), "%s %s", c.method, c.path)
+	}
+}
+
+func TestHandlerMultipart(t *testing.T) {
+	server := httptest.NewServer(NewHandler(tests()...))
+	defer server.Close()
+
+	for content, code := range map[string]int{"png": 204, "txt": 422, "gif": 404} {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("title", "me")
+		file, _ := writer.CreateFormFile("file", "me.png")
+		file.Write([]byte(content))
+		writer.Close()
+
+		resp, err := http.Post(server.URL+"/users/1/avatar", writer.FormDataContentType(), body)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, code, resp.StatusCode, "multipart body with %s, boundary is not compared", content)
+		}
+	}
+}
+
+func TestTokenHandler(t *testing.T) {
+	tokenServer := httptest.NewServer(NewTokenHandler("client", "secret", 5*time.Second))
+	defer tokenServer.Close()
+
+	authorizations := []string{}
+	mockClient := NewClient(tests()...)
+	client := schreder.IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		// token requests are sent by the client of the runner as well
+		if strings.HasPrefix(req.URL.String(), tokenServer.URL) {
+			return http.DefaultClient.Do(req)
+		}
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		return mockClient.Do(req)
+	})
+
+	// tokens that expire in 5 seconds are too short-lived to be reused
+	runner := schreder.NewRunner("http://testapi.my", schreder.RunnerConfig{
+		HttpClient: client,
+		Authenticator: schreder.NewOAuth2ClientCredentials(schreder.OAuth2Config{
+			TokenURL:     tokenServer.URL,
+			ClientID:     "client",
+			ClientSecret: "secret",
+		}),
+	})
+	runner.Run(t, &getUserTest{})
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2", "Bearer token-3"}, authorizations)
+
+	req, _ := http.NewRequest("GET", "http://testapi.my/users/1", nil)
+	auth := schreder.NewOAuth2ClientCredentials(schreder.OAuth2Config{TokenURL: tokenServer.URL, ClientID: "client"})
+	assert.EqualError(t, auth.Authenticate(req),
+		"could not fetch access token: token endpoint responded with 401: {\"error\":\"invalid_client\"}\n")
+}
+
+func TestTokenHandlerExpiresIn(t *testing.T) {
+	for expiresIn, expected := range map[time.Duration]string{
+		500 * time.Millisecond:  `"expires_in":1`,
+		1500 * time.Millisecond: `"expires_in":2`,
+		time.Minute:             `"expires_in":60`,
+	} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/token", strings.NewReader("grant_type=client_credentials"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("client", "secret")
+		NewTokenHandler("client", "secret", expiresIn).ServeHTTP(recorder, req)
+
+		assert.Contains(t, recorder.Body.String(), expected, "expiry is rounded up to seconds")
+	}
+}
@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type tokenHandler struct {
+	mu           sync.Mutex
+	clientID     string
+	clientSecret string
+	expiresIn    time.Duration
+	issued       int
+}
+
+// NewTokenHandler creates a stand-in of OAuth2 token endpoint that issues
+// access tokens by client credentials grant to the client with given ID and
+// secret. Credentials are accepted in Authorization header as well as in the
+// form. Tokens are named "token-1", "token-2" and so on in order of issue and
+// expire in given time rounded up to seconds, zero means they never expire.
+//
+// Along with the mock of the API it lets tests of authenticated APIs run
+// with no authorization server at all
+func NewTokenHandler(clientID, clientSecret string, expiresIn time.Duration) http.Handler {
+	return &tokenHandler{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		expiresIn:    expiresIn,
+	}
+}
+
+func (h *tokenHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		writeTokenError(w, http.StatusMethodNotAllowed, "invalid_request")
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+	if clientID != h.clientID || clientSecret != h.clientSecret {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if req.PostForm.Get("grant_type") != "client_credentials" {
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	h.mu.Lock()
+	h.issued++
+	token := map[string]interface{}{
+		"access_token": fmt.Sprintf("token-%d", h.issued),
+		"token_type":   "bearer",
+	}
+	h.mu.Unlock()
+
+	if h.expiresIn > 0 {
+		// expires_in is in seconds, zero would mean the token never expires
+		token["expires_in"] = int64((h.expiresIn + time.Second - 1) / time.Second)
+	}
+	if scope := req.PostForm.Get("scope"); scope != "" {
+		token["scope"] = scope
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(token)
+}
+
+func writeTokenError(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": reason})
+}
@@ -0,0 +1,40 @@
+package schreder_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/testmeifyoucan/schreder"
+	"github.com/testmeifyoucan/schreder/mock"
+)
+
+// apiTests are tests of the example API, they are run against the mock of
+// the API that is built from the tests themselves
+func apiTests() []schreder.Test {
+	return []schreder.Test{
+		&schreder.HelloTest{},
+		&schreder.GetUserTest{},
+		&schreder.CreateUserTest{},
+		&schreder.UpdateUserTest{},
+		&schreder.DeleteUserTest{},
+	}
+}
+
+func TestRunApi(t *testing.T) {
+	runner := schreder.NewRunner("http://testapi.my", schreder.RunnerConfig{HttpClient: mock.NewClient(apiTests()...)})
+	runner.Run(t, apiTests()...)
+}
+
+func TestRunApiWithContract(t *testing.T) {
+	contract, err := schreder.LoadContract("fixtures/swagger/swagger.yml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	runner := schreder.NewRunner("http://testapi.my", schreder.RunnerConfig{
+		HttpClient: mock.NewClient(apiTests()...),
+		Contract:   contract,
+	})
+	runner.Run(t, apiTests()...)
+}
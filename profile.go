@@ -0,0 +1,381 @@
+package schreder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// ProfileEnvPrefix prefixes environment variables that define or override
+// a profile, see ProfileFromEnv
+const ProfileEnvPrefix = "SCHREDER_"
+
+// envReference is a value of a profile that refers an environment variable
+// as a whole: $NAME or ${NAME}
+var envReference = regexp.MustCompile(`^\$(?:(\w+)|\{(\w+)\})$`)
+
+// Profile is an environment tests are run against, like local, dev or staging.
+// See NewProfileRunner and WithProfile
+type Profile struct {
+	Name    string `json:"-"`
+	BaseUrl string `json:"baseUrl"`
+	// Headers are default headers of requests
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *ProfileAuth      `json:"auth,omitempty"`
+	// Vars are variables every run starts with, test cases refer them as
+	// "${name}" in parameters and request bodies
+	Vars map[string]interface{} `json:"vars,omitempty"`
+}
+
+// ProfileAuth defines the authenticator of a profile. Type is one of basic,
+// bearer, oauth2, hmac and aws, each of them uses its own fields
+type ProfileAuth struct {
+	Type string `json:"type"`
+
+	// basic
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// bearer
+	Token string `json:"token,omitempty"`
+	// oauth2
+	TokenURL     string   `json:"tokenUrl,omitempty"`
+	ClientID     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// hmac
+	KeyID  string `json:"keyId,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	// aws
+	AccessKeyID     string `json:"accessKeyId,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Service         string `json:"service,omitempty"`
+}
+
+// Authenticator creates the authenticator defined by the profile
+func (a ProfileAuth) Authenticator() (IAuthenticator, error) {
+	switch strings.ToLower(a.Type) {
+	case "basic":
+		return NewBasicAuth(a.Username, a.Password), nil
+	case "bearer":
+		token := Token{AccessToken: a.Token}
+		return NewBearerAuth(func() (Token, error) { return token, nil }), nil
+	case "oauth2":
+		return NewOAuth2ClientCredentials(OAuth2Config{
+			TokenURL:     a.TokenURL,
+			ClientID:     a.ClientID,
+			ClientSecret: a.ClientSecret,
+			Scopes:       a.Scopes,
+		}), nil
+	case "hmac":
+		return NewHMACAuth(a.KeyID, a.Secret), nil
+	case "aws":
+		return NewAWSSigV4Auth(AWSConfig{
+			AccessKeyID:     a.AccessKeyID,
+			SecretAccessKey: a.SecretAccessKey,
+			SessionToken:    a.SessionToken,
+			Region:          a.Region,
+			Service:         a.Service,
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unknown auth type '%s', one of basic, bearer, oauth2, hmac and aws expected", a.Type)
+}
+
+// fields returns pointers to string fields of the auth by their names in
+// environment variables
+func (a *ProfileAuth) fields() map[string]*string {
+	return map[string]*string{
+		"TYPE":              &a.Type,
+		"USERNAME":          &a.Username,
+		"PASSWORD":          &a.Password,
+		"TOKEN":             &a.Token,
+		"TOKEN_URL":         &a.TokenURL,
+		"CLIENT_ID":         &a.ClientID,
+		"CLIENT_SECRET":     &a.ClientSecret,
+		"KEY_ID":            &a.KeyID,
+		"SECRET":            &a.Secret,
+		"ACCESS_KEY_ID":     &a.AccessKeyID,
+		"SECRET_ACCESS_KEY": &a.SecretAccessKey,
+		"SESSION_TOKEN":     &a.SessionToken,
+		"REGION":            &a.Region,
+		"SERVICE":           &a.Service,
+	}
+}
+
+// Override returns a copy of the profile with non empty fields of other
+// profile on top of its own ones. Headers and variables are merged
+func (p Profile) Override(other Profile) Profile {
+	result := p
+	if other.Name != "" {
+		result.Name = other.Name
+	}
+	if other.BaseUrl != "" {
+		result.BaseUrl = other.BaseUrl
+	}
+
+	result.Headers = map[string]string{}
+	for _, headers := range []map[string]string{p.Headers, other.Headers} {
+		for name, value := range headers {
+			result.Headers[name] = value
+		}
+	}
+
+	result.Vars = map[string]interface{}{}
+	for _, vars := range []map[string]interface{}{p.Vars, other.Vars} {
+		for name, value := range vars {
+			result.Vars[name] = value
+		}
+	}
+
+	if other.Auth != nil {
+		auth := ProfileAuth{}
+		if p.Auth != nil && (other.Auth.Type == "" || strings.EqualFold(other.Auth.Type, p.Auth.Type)) {
+			auth = *p.Auth
+		}
+		otherFields := other.Auth.fields()
+		for name, field := range auth.fields() {
+			if *otherFields[name] != "" {
+				*field = *otherFields[name]
+			}
+		}
+		if len(other.Auth.Scopes) > 0 {
+			auth.Scopes = other.Auth.Scopes
+		}
+		result.Auth = &auth
+	}
+
+	return result
+}
+
+// Profiles are named environment profiles
+type Profiles map[string]Profile
+
+// Profile returns the profile with given name
+func (p Profiles) Profile(name string) (Profile, error) {
+	profile, ok := p[name]
+	if !ok {
+		names := []string{}
+		for name := range p {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return Profile{}, fmt.Errorf("profile '%s' is not defined, available ones are: %s", name, strings.Join(names, ", "))
+	}
+
+	return profile, nil
+}
+
+// LoadProfiles loads environment profiles from a YAML or JSON file:
+//
+//	profiles:
+//	  local:
+//	    baseUrl: http://localhost:1323
+//	    vars: {userID: 1}
+//	  staging:
+//	    baseUrl: https://staging.example.com/api
+//	    headers: {Accept: application/json}
+//	    auth: {type: bearer, token: $STAGING_TOKEN}
+//	    vars: {userID: 42}
+//
+// Values of headers and auth that are whole references to environment variables
+// like $STAGING_TOKEN or ${STAGING_TOKEN} are expanded, so secrets stay out of the file
+func LoadProfiles(path string) (Profiles, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := ParseProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not load profiles from '%s': %s", path, err.Error())
+	}
+
+	return profiles, nil
+}
+
+// ParseProfiles parses environment profiles from YAML or JSON contents of
+// a profiles file, see LoadProfiles
+func ParseProfiles(data []byte) (Profiles, error) {
+	js, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Profiles Profiles `json:"profiles"`
+	}
+	if err := decodeSuiteJSON(js, &file); err != nil {
+		return nil, err
+	}
+
+	profiles := Profiles{}
+	for name, profile := range file.Profiles {
+		profile.Name = name
+		for header, value := range profile.Headers {
+			profile.Headers[header] = expandEnvReference(value)
+		}
+		if profile.Auth != nil {
+			for _, field := range profile.Auth.fields() {
+				*field = expandEnvReference(*field)
+			}
+		}
+		normalizeNumbers(profile.Vars)
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// expandEnvReference replaces a value that refers an environment variable
+// with the value of the variable. Other values are kept as is, so secrets like
+// 'pa$$w0rd' are not mangled
+func expandEnvReference(value string) string {
+	match := envReference.FindStringSubmatch(value)
+	if match == nil {
+		return value
+	}
+
+	return os.Getenv(match[1] + match[2])
+}
+
+// ProfileFromEnv reads a profile from environment variables with given prefix:
+//
+//	<prefix>BASE_URL=https://staging.example.com/api
+//	<prefix>HEADER_X_API_KEY=key         sets X-Api-Key header
+//	<prefix>AUTH_TYPE=bearer             AUTH_ variables set fields of ProfileAuth,
+//	<prefix>AUTH_TOKEN=token             like AUTH_CLIENT_SECRET or AUTH_SCOPES
+//	<prefix>VAR_userID=42                sets userID variable
+//
+// Values of variables are strings
+func ProfileFromEnv(prefix string) Profile {
+	profile := Profile{BaseUrl: os.Getenv(prefix + "BASE_URL")}
+	auth := &ProfileAuth{}
+	authFields := auth.fields()
+	hasAuth := false
+
+	for _, entry := range os.Environ() {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) {
+			continue
+		}
+		name, value := strings.TrimPrefix(parts[0], prefix), parts[1]
+
+		switch {
+		case strings.HasPrefix(name, "HEADER_"):
+			if profile.Headers == nil {
+				profile.Headers = map[string]string{}
+			}
+			header := strings.Replace(strings.TrimPrefix(name, "HEADER_"), "_", "-", -1)
+			profile.Headers[http.CanonicalHeaderKey(header)] = value
+		case strings.HasPrefix(name, "VAR_"):
+			if profile.Vars == nil {
+				profile.Vars = map[string]interface{}{}
+			}
+			profile.Vars[strings.TrimPrefix(name, "VAR_")] = value
+		case name == "AUTH_SCOPES":
+			auth.Scopes = strings.Fields(value)
+			hasAuth = true
+		case strings.HasPrefix(name, "AUTH_"):
+			if field, ok := authFields[strings.TrimPrefix(name, "AUTH_")]; ok {
+				*field = value
+				hasAuth = true
+			}
+		}
+	}
+	if hasAuth {
+		profile.Auth = auth
+	}
+
+	return profile
+}
+
+// SelectProfile picks the profile tests are run against. It's the profile with
+// given name from the profiles file at path, SCHREDER_PROFILE environment
+// variable names it if name is empty. With no path, the profile is defined by
+// environment variables only. Either way, environment variables with
+// ProfileEnvPrefix override the profile, see ProfileFromEnv
+func SelectProfile(path, name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnvPrefix + "PROFILE")
+	}
+
+	profile := Profile{Name: name}
+	if path != "" {
+		profiles, err := LoadProfiles(path)
+		if err != nil {
+			return Profile{}, err
+		}
+		if profile, err = profiles.Profile(name); err != nil {
+			return Profile{}, err
+		}
+	}
+
+	return profile.Override(ProfileFromEnv(ProfileEnvPrefix)), nil
+}
+
+// NewProfileRunner creates a runner of tests against the base URL of the profile.
+// Default headers, the authenticator and variables of the profile are added
+// to given config, the ones defined by the config take precedence
+func NewProfileRunner(profile Profile, config RunnerConfig) (*httpRunner, error) {
+	if profile.BaseUrl == "" {
+		return nil, fmt.Errorf("profile '%s' has no base URL", profile.Name)
+	}
+
+	headers := map[string]string{}
+	for _, defaults := range []map[string]string{profile.Headers, config.DefaultHeaders} {
+		for name, value := range defaults {
+			headers[name] = value
+		}
+	}
+	config.DefaultHeaders = headers
+
+	vars := map[string]interface{}{}
+	for _, defaults := range []map[string]interface{}{profile.Vars, config.Vars} {
+		for name, value := range defaults {
+			vars[name] = value
+		}
+	}
+	config.Vars = vars
+
+	if config.Authenticator == nil && profile.Auth != nil {
+		authenticator, err := profile.Auth.Authenticator()
+		if err != nil {
+			return nil, fmt.Errorf("profile '%s': %s", profile.Name, err.Error())
+		}
+		config.Authenticator = authenticator
+	}
+
+	return NewRunner(profile.BaseUrl, config), nil
+}
+
+// serverGenerator is implemented by generators that can document the server of the API
+type serverGenerator interface {
+	withServer(base *url.URL) IDocGenerator
+}
+
+// WithProfile returns a copy of given generator that documents the base URL of
+// the profile as the server of the API: host, schemes and basePath of Swagger,
+// servers of OpenAPI, baseUri and protocols of RAML. They replace the ones of
+// the seed. Generators that can not document the server are returned as is
+func WithProfile(generator IDocGenerator, profile Profile) (IDocGenerator, error) {
+	base, err := url.Parse(profile.BaseUrl)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL '%s' of profile '%s'", profile.BaseUrl, profile.Name)
+	}
+
+	if served, ok := generator.(serverGenerator); ok {
+		return served.withServer(base), nil
+	}
+
+	return generator, nil
+}
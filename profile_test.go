@@ -0,0 +1,176 @@
+package schreder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-raml/raml"
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesYAML = `
+profiles:
+  local:
+    baseUrl: http://localhost:1323
+    vars: {userID: 1}
+  staging:
+    baseUrl: https://staging.example.com/api
+    headers: {Accept: application/json, X-Api-Key: $STAGING_API_KEY}
+    auth: {type: bearer, token: $STAGING_TOKEN}
+    vars: {userID: 42, name: Staging User}
+  dev:
+    baseUrl: https://dev.example.com/api
+    headers: {X-Api-Key: "${DEV_API_KEY}", X-Note: "costs $STAGING_TOKEN"}
+    auth: {type: basic, username: admin, password: pa$$w0rd}
+`
+
+func TestParseProfiles(t *testing.T) {
+	t.Setenv("STAGING_API_KEY", "key")
+	t.Setenv("STAGING_TOKEN", "token")
+	t.Setenv("DEV_API_KEY", "dev-key")
+
+	profiles, err := ParseProfiles([]byte(profilesYAML))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	staging, err := profiles.Profile("staging")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Profile{
+			Name:    "staging",
+			BaseUrl: "https://staging.example.com/api",
+			Headers: map[string]string{"Accept": "application/json", "X-Api-Key": "key"},
+			Auth:    &ProfileAuth{Type: "bearer", Token: "token"},
+			Vars:    map[string]interface{}{"userID": int64(42), "name": "Staging User"},
+		}, staging)
+	}
+
+	dev, err := profiles.Profile("dev")
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"X-Api-Key": "dev-key", "X-Note": "costs $STAGING_TOKEN"}, dev.Headers,
+			"only values that are references as a whole are expanded")
+		assert.Equal(t, &ProfileAuth{Type: "basic", Username: "admin", Password: "pa$$w0rd"}, dev.Auth)
+	}
+
+	_, err = profiles.Profile("prod")
+	assert.EqualError(t, err, "profile 'prod' is not defined, available ones are: dev, local, staging")
+
+	_, err = ParseProfiles([]byte(`profiles: {local: {url: "http://localhost"}}`))
+	assert.EqualError(t, err, `json: unknown field "url"`)
+}
+
+func TestSelectProfile(t *testing.T) {
+	t.Setenv("SCHREDER_PROFILE", "staging")
+	t.Setenv("SCHREDER_BASE_URL", "http://localhost:8080")
+	t.Setenv("SCHREDER_HEADER_X_REQUEST_ID", "test")
+	t.Setenv("SCHREDER_AUTH_TOKEN", "env-token")
+	t.Setenv("SCHREDER_VAR_userID", "7")
+
+	path := t.TempDir() + "/profiles.yml"
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte(profilesYAML), 0644)) {
+		return
+	}
+
+	profile, err := SelectProfile(path, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "staging", profile.Name)
+	assert.Equal(t, "http://localhost:8080", profile.BaseUrl)
+	assert.Equal(t, "test", profile.Headers["X-Request-Id"])
+	assert.Equal(t, "application/json", profile.Headers["Accept"])
+	assert.Equal(t, &ProfileAuth{Type: "bearer", Token: "env-token"}, profile.Auth)
+	assert.Equal(t, map[string]interface{}{"userID": "7", "name": "Staging User"}, profile.Vars)
+
+	_, err = SelectProfile(path, "prod")
+	assert.Error(t, err)
+
+	profile, err = SelectProfile("", "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost:8080", profile.BaseUrl, "profile is defined by environment only")
+	}
+}
+
+func TestNewProfileRunner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"path":          r.URL.Path,
+			"body":          string(body),
+			"authorization": r.Header.Get("Authorization"),
+			"accept":        r.Header.Get("Accept"),
+		})
+	}))
+	defer server.Close()
+
+	profile := Profile{
+		Name:    "local",
+		BaseUrl: server.URL,
+		Headers: map[string]string{"Accept": "text/plain"},
+		Auth:    &ProfileAuth{Type: "basic", Username: "admin", Password: "secret"},
+		Vars:    map[string]interface{}{"userID": 1, "name": "Local User"},
+	}
+	runner, err := NewProfileRunner(profile, RunnerConfig{DefaultHeaders: map[string]string{"Accept": "application/json"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	runner.Run(t, &FileTest{TestMethod: "PUT", TestPath: "/users/{id}", Cases: []TestCase{{
+		PathParams:       ParamMap{"id": Param{Value: "${userID}"}},
+		RequestBody:      map[string]interface{}{"name": "${name}"},
+		ExpectedHttpCode: 200,
+		ExpectedData: map[string]interface{}{
+			"path":          "/users/1",
+			"body":          `{"name":"Local User"}`,
+			"authorization": "Basic YWRtaW46c2VjcmV0",
+			"accept":        "application/json",
+		},
+	}}})
+
+	_, err = NewProfileRunner(Profile{Name: "empty"}, RunnerConfig{})
+	assert.EqualError(t, err, "profile 'empty' has no base URL")
+	_, err = NewProfileRunner(Profile{Name: "local", BaseUrl: server.URL, Auth: &ProfileAuth{Type: "digest"}}, RunnerConfig{})
+	assert.EqualError(t, err, "profile 'local': unknown auth type 'digest', one of basic, bearer, oauth2, hmac and aws expected")
+}
+
+func TestWithProfile(t *testing.T) {
+	profile := Profile{Name: "staging", BaseUrl: "https://staging.example.com/api"}
+	tests := []Test{&HelloTest{}}
+
+	swagger := spec.Swagger{}
+	swagger.Host = "localhost"
+	swagger.Info = &spec.Info{}
+	generator, err := WithProfile(WithConflictPolicy(NewSwaggerGeneratorJSON(swagger), ConflictMerge, nil), profile)
+	if assert.NoError(t, err) {
+		doc, err := generator.Generate(tests)
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(doc), `"schemes":["https"]`)
+			assert.Contains(t, string(doc), `"host":"staging.example.com"`)
+			assert.Contains(t, string(doc), `"basePath":"/api"`)
+		}
+	}
+
+	generator, err = WithProfile(NewOpenAPIGeneratorJSON(OpenAPI{Info: &spec.Info{}}), profile)
+	if assert.NoError(t, err) {
+		doc, err := generator.Generate(tests)
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(doc), `"servers":[{"url":"https://staging.example.com/api"}]`)
+		}
+	}
+
+	generator, err = WithProfile(NewRaml10Generator(raml.APIDefinition{Title: "API"}), profile)
+	if assert.NoError(t, err) {
+		doc, err := generator.Generate(tests)
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(doc), "baseUri: https://staging.example.com/api")
+			assert.Contains(t, string(doc), "- HTTPS")
+		}
+	}
+
+	_, err = WithProfile(generator, Profile{Name: "local", BaseUrl: "localhost"})
+	assert.EqualError(t, err, "invalid base URL 'localhost' of profile 'local'")
+}
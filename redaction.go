@@ -0,0 +1,328 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted replaces secret values in logs and failure output of the runner,
+// in recorded cassettes and in examples of generated documentation
+const Redacted = "[REDACTED]"
+
+// Redaction defines values that are secret: values of headers with given
+// names and values of JSON bodies at given paths. Values of parameters with
+// Secret flag are secret as well, headers of DefaultRedaction always are.
+//
+// The runner masks secrets in its logs and failure output whatever their length,
+// see RunnerConfig. Credentials like <token> of 'Authorization: Bearer <token>'
+// are masked on their own if they are at least 4 characters long. Doc
+// generators leave secrets out of defaults and examples, see WithRedaction
+type Redaction struct {
+	// Headers are names of headers that carry secrets, case insensitive
+	Headers []string
+	// JSONPaths point to secret values of request and response bodies, like
+	// "$.password" or "$.users[*].token". '*' selects all items of an array
+	// or all fields of an object
+	JSONPaths []string
+}
+
+// DefaultRedaction lists headers that carry credentials
+var DefaultRedaction = Redaction{
+	Headers: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+}
+
+// IsSecretHeader tells whether the header carries secrets
+func (r Redaction) IsSecretHeader(name string) bool {
+	for _, headers := range [][]string{DefaultRedaction.Headers, r.Headers} {
+		for _, header := range headers {
+			if strings.EqualFold(name, header) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RedactJSON replaces values of JSON body at paths of the redaction with
+// Redacted. Bodies that are not JSON or have no secrets are returned as is
+func (r Redaction) RedactJSON(body []byte) []byte {
+	if len(r.JSONPaths) == 0 {
+		return body
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // keeps numbers of the body intact
+	if err := decoder.Decode(&document); err != nil {
+		return body
+	}
+
+	document, redacted := r.redactValue(document)
+	if !redacted {
+		return body
+	}
+
+	redactedBody, err := json.Marshal(document)
+	if err != nil {
+		return body
+	}
+
+	return redactedBody
+}
+
+// RedactData returns generic JSON representation of given data with values at
+// paths of the redaction replaced with Redacted. Data that has no secrets is
+// returned as is
+func (r Redaction) RedactData(data interface{}) interface{} {
+	if len(r.JSONPaths) == 0 || data == nil {
+		return data
+	}
+
+	value, err := objToJsonValue(data)
+	if err != nil {
+		return data
+	}
+
+	value, redacted := r.redactValue(value)
+	if !redacted {
+		return data
+	}
+
+	return value
+}
+
+// redactValue replaces values at paths of the redaction in generic JSON value
+func (r Redaction) redactValue(value interface{}) (interface{}, bool) {
+	redacted := false
+	for _, path := range r.JSONPaths {
+		tokens, err := parseJSONPath(path)
+		if err != nil {
+			continue
+		}
+
+		var found bool
+		value, found = replaceJSONPath(value, tokens, func(interface{}) interface{} { return Redacted })
+		redacted = redacted || found
+	}
+
+	return value, redacted
+}
+
+// isSecretField tells whether a top level field of a body is secret,
+// e.g. a field of a form
+func (r Redaction) isSecretField(name string) bool {
+	for _, path := range r.JSONPaths {
+		if tokens, err := parseJSONPath(path); err == nil && len(tokens) == 1 &&
+			(tokens[0] == name || tokens[0] == "*") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Redaction) merge(other Redaction) Redaction {
+	return Redaction{
+		Headers:   append(append([]string{}, r.Headers...), other.Headers...),
+		JSONPaths: append(append([]string{}, r.JSONPaths...), other.JSONPaths...),
+	}
+}
+
+// replaceJSONPath replaces values of generic JSON value found by reference
+// tokens of JSONPath, '*' token stands for every item of an array or field
+// of an object. Reports whether any value was found
+func replaceJSONPath(value interface{}, tokens []string, replace func(interface{}) interface{}) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return replace(value), true
+	}
+
+	found := false
+	token, rest := tokens[0], tokens[1:]
+	switch node := value.(type) {
+	case map[string]interface{}:
+		for key, item := range node {
+			if token != "*" && token != key {
+				continue
+			}
+			if replaced, ok := replaceJSONPath(item, rest, replace); ok {
+				node[key] = replaced
+				found = true
+			}
+		}
+
+	case []interface{}:
+		for i, item := range node {
+			if token != "*" && token != strconv.Itoa(i) {
+				continue
+			}
+			if replaced, ok := replaceJSONPath(item, rest, replace); ok {
+				node[i] = replaced
+				found = true
+			}
+		}
+	}
+
+	return value, found
+}
+
+// minSecretLength is the length of the shortest credential that is taken from
+// a value of a secret header on its own. Shorter parts like '1' or 'no' would
+// mask unrelated parts of texts. Explicit secrets are masked whatever their length
+const minSecretLength = 4
+
+// secrets collects secret values a test case meets, so they can be masked
+// in any text the runner shows
+type secrets struct {
+	redaction Redaction
+	values    []string
+}
+
+func newSecrets(redaction Redaction) *secrets {
+	return &secrets{redaction: redaction}
+}
+
+// add collects given value, all leaves of maps and slices are collected
+func (s *secrets) add(value interface{}) {
+	switch value := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for _, item := range value {
+			s.add(item)
+		}
+	case []interface{}:
+		for _, item := range value {
+			s.add(item)
+		}
+	default:
+		if text := fmt.Sprintf("%v", value); text != "" {
+			s.values = append(s.values, text)
+		}
+	}
+}
+
+// addParams collects values of secret parameters, headers are secret by name as well
+func (s *secrets) addParams(params ParamMap, headers bool) {
+	for name, param := range params {
+		if param.Secret || (headers && s.redaction.IsSecretHeader(name)) {
+			s.add(param.Value)
+		}
+	}
+}
+
+// addHeaders collects values of secret headers. Credentials of values like
+// 'Bearer <token>' are collected on their own as well
+func (s *secrets) addHeaders(header map[string][]string) {
+	for name, values := range header {
+		if !s.redaction.IsSecretHeader(name) {
+			continue
+		}
+		for _, value := range values {
+			s.add(value)
+			if parts := strings.SplitN(value, " ", 2); len(parts) == 2 && len(parts[1]) >= minSecretLength {
+				s.add(parts[1])
+			}
+		}
+	}
+}
+
+// addData collects values of the body at paths of the redaction
+func (s *secrets) addData(data interface{}) {
+	if len(s.redaction.JSONPaths) == 0 || data == nil {
+		return
+	}
+
+	value, err := objToJsonValue(data)
+	if err != nil {
+		return
+	}
+	s.addValue(value)
+}
+
+// addJSON collects values of JSON body at paths of the redaction
+func (s *secrets) addJSON(body []byte) {
+	if len(s.redaction.JSONPaths) == 0 {
+		return
+	}
+
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return
+	}
+	s.addValue(document)
+}
+
+func (s *secrets) addValue(value interface{}) {
+	for _, path := range s.redaction.JSONPaths {
+		if tokens, err := parseJSONPath(path); err == nil {
+			replaceJSONPath(value, tokens, func(item interface{}) interface{} {
+				s.add(item)
+				return item
+			})
+		}
+	}
+}
+
+// mask replaces all collected values in given text with Redacted. Values are
+// also replaced in forms they take in JSON strings and URL queries. Only whole
+// tokens are replaced, so 'true' is not masked in 'untrue'
+func (s *secrets) mask(text string) string {
+	values := append([]string{}, s.values...)
+	// longer values first, so a value that contains another one is masked whole
+	sort.Sort(byLengthDesc(values))
+
+	for _, value := range values {
+		text = replaceToken(text, value)
+		if quoted, err := json.Marshal(value); err == nil {
+			text = replaceToken(text, string(quoted[1:len(quoted)-1]))
+		}
+		text = replaceToken(text, url.QueryEscape(value))
+	}
+
+	return text
+}
+
+// replaceToken replaces occurrences of the value in the text with Redacted
+// unless they are parts of longer words or numbers
+func replaceToken(text, value string) string {
+	result := &bytes.Buffer{}
+	for {
+		i := strings.Index(text, value)
+		if i < 0 {
+			break
+		}
+
+		end := i + len(value)
+		whole := !(isWordChar(value, 0) && i > 0 && isWordChar(text, i-1)) &&
+			!(isWordChar(value, len(value)-1) && end < len(text) && isWordChar(text, end))
+		if whole {
+			result.WriteString(text[:i])
+			result.WriteString(Redacted)
+		} else {
+			result.WriteString(text[:end])
+		}
+		text = text[end:]
+	}
+	result.WriteString(text)
+
+	return result.String()
+}
+
+func isWordChar(text string, i int) bool {
+	c := text[i]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c >= utf8.RuneSelf
+}
+
+type byLengthDesc []string
+
+func (s byLengthDesc) Len() int           { return len(s) }
+func (s byLengthDesc) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLengthDesc) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
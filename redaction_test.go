@@ -0,0 +1,101 @@
+package schreder
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/go-raml/raml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactJSON(t *testing.T) {
+	redaction := Redaction{JSONPaths: []string{"$.password", "$.tokens[*].value", "$['missing']"}}
+
+	body := `{"login":"admin","password":"p","tokens":[{"value":"a","n":12345678901234567890},{"value":"b"}]}`
+	assert.Equal(t,
+		`{"login":"admin","password":"[REDACTED]","tokens":[{"n":12345678901234567890,"value":"[REDACTED]"},{"value":"[REDACTED]"}]}`,
+		string(redaction.RedactJSON([]byte(body))))
+
+	for _, body := range []string{`{ "login": "admin" }`, `password=p`, ``} {
+		assert.Equal(t, body, string(redaction.RedactJSON([]byte(body))), "body with no secrets is returned as is")
+	}
+
+	assert.Equal(t, map[string]interface{}{"login": "admin", "password": Redacted},
+		redaction.RedactData(struct {
+			Login    string `json:"login"`
+			Password string `json:"password"`
+		}{"admin", "p"}))
+}
+
+func TestRedactionIsSecretHeader(t *testing.T) {
+	redaction := Redaction{Headers: []string{"X-Session"}}
+
+	assert.True(t, redaction.IsSecretHeader("x-session"))
+	assert.True(t, redaction.IsSecretHeader("authorization"), "default headers are always secret")
+	assert.False(t, redaction.IsSecretHeader("Accept"))
+}
+
+func TestSecretsMask(t *testing.T) {
+	secrets := newSecrets(Redaction{Headers: []string{"X-Session"}, JSONPaths: []string{"$.token"}})
+	secrets.addParams(ParamMap{
+		"X-Session": Param{Value: "sess-1"},
+		"Accept":    Param{Value: "application/json"},
+	}, true)
+	secrets.addParams(ParamMap{
+		"signature": Param{Value: "a b/c", Secret: true},
+		"page":      Param{Value: 1},
+	}, false)
+	secrets.addHeaders(http.Header{"Authorization": {"Bearer tok-1"}})
+	secrets.addJSON([]byte(`{"token":"tok-\"2\"","id":1}`))
+
+	assert.Equal(t,
+		`[REDACTED] application/json [REDACTED] [REDACTED] ?page=1&signature=[REDACTED] {"token":"[REDACTED]","id":1}`,
+		secrets.mask(`sess-1 application/json Bearer tok-1 tok-1 ?page=1&signature=a+b%2Fc {"token":"tok-\"2\"","id":1}`))
+}
+
+func TestSecretsMaskWholeTokens(t *testing.T) {
+	secrets := newSecrets(Redaction{})
+	secrets.addParams(ParamMap{
+		"pin":     Param{Value: 1, Secret: true},
+		"enabled": Param{Value: true, Secret: true},
+		"session": Param{Value: "sess-1", Secret: true},
+	}, false)
+	secrets.addHeaders(http.Header{"Authorization": {"Basic ab"}})
+
+	assert.Equal(t,
+		`?pin=[REDACTED]&enabled=[REDACTED] untrue sess-12 [REDACTED] [REDACTED] ab`,
+		secrets.mask(`?pin=1&enabled=true untrue sess-12 sess-1 Basic ab ab`),
+		"explicit secrets are masked whatever their length, parts of longer words are not")
+}
+
+func TestGenerateRedaction(t *testing.T) {
+	generators := map[string]IDocGenerator{
+		"swagger": NewSwaggerGeneratorYAML(spec.Swagger{}),
+		"openapi": NewOpenAPIGeneratorYAML(OpenAPI{}),
+		"raml":    NewRamlGenerator(raml.APIDefinition{}),
+		"raml10":  NewRaml10Generator(raml.APIDefinition{}),
+	}
+	redaction := Redaction{Headers: []string{"X-Session"}, JSONPaths: []string{"$.password", "$.tokens[*].value"}}
+
+	for name, generator := range generators {
+		doc, err := generator.Generate([]Test{&LoginTest{}})
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.NotContains(t, string(doc), "secret-api-key", "%s: secret parameters are never documented", name)
+		assert.NotContains(t, string(doc), "secret-signature", "%s: secret parameters are never documented", name)
+		assert.Contains(t, string(doc), "secret-token", name)
+
+		doc, err = WithRedaction(WithConflictPolicy(generator, ConflictMerge, nil), redaction).Generate([]Test{&LoginTest{}})
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.NotContains(t, string(doc), "secret-", name)
+		assert.Contains(t, string(doc), Redacted, name)
+		assert.Contains(t, string(doc), "admin", "%s: values that are not secret are documented", name)
+	}
+
+	custom := &customGenerator{}
+	assert.Equal(t, custom, WithRedaction(custom, redaction), "generators that can not redact docs are returned as is")
+}
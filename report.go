@@ -0,0 +1,436 @@
+package schreder
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Report collects results of tests run by the runner, see RunnerConfig.
+// Once tests are finished, it's written as JUnit XML for CI servers, as JSON
+// or as a standalone HTML page. Secrets are masked the same way they are in
+// the output of the runner
+type Report struct {
+	mu sync.Mutex
+
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Tests    []*TestReport `json:"tests"`
+}
+
+// TestReport is a result of a test and its test cases
+type TestReport struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	// Error tells why test cases of the test were not run, e.g. the test
+	// breaks the contract
+	Error         string            `json:"error,omitempty"`
+	SetUpError    string            `json:"setUpError,omitempty"`
+	TearDownError string            `json:"tearDownError,omitempty"`
+	Duration      time.Duration     `json:"duration"`
+	Cases         []*TestCaseReport `json:"cases"`
+}
+
+// TestCaseReport is a result of a test case
+type TestCaseReport struct {
+	Name           string        `json:"name"`
+	Method         string        `json:"method"`
+	URL            string        `json:"url,omitempty"`
+	ExpectedStatus int           `json:"expectedStatus"`
+	Status         int           `json:"status,omitempty"`
+	Passed         bool          `json:"passed"`
+	Duration       time.Duration `json:"duration"`
+	// Failures are messages of failed assertions, like diffs of response
+	// bodies. Failures of custom AssertResponse functions are not listed
+	Failures []string       `json:"failures,omitempty"`
+	Request  *MessageReport `json:"request,omitempty"`
+	Response *MessageReport `json:"response,omitempty"`
+	// Attempts are all attempts of a test case that may be retried, including
+	// the last one. See RetryPolicy and Eventually
+	Attempts []*AttemptReport `json:"attempts,omitempty"`
+}
+
+// AttemptReport is an attempt of a test case that may be retried, see RetryPolicy
+// and Eventually. The last attempt is the one the test case is reported with
+type AttemptReport struct {
+	Status int `json:"status,omitempty"`
+	// Error tells why the attempt was retried: its request could not be sent,
+	// its status is retried or assertions of its response failed. Failures of
+	// the last attempt are failures of the test case
+	Error    string         `json:"error,omitempty"`
+	Duration time.Duration  `json:"duration"`
+	Request  *MessageReport `json:"request,omitempty"`
+	Response *MessageReport `json:"response,omitempty"`
+}
+
+// MessageReport is a request or a response sent while running a test case.
+// Binary bodies are not reported
+type MessageReport struct {
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// NewReport creates an empty report
+func NewReport() *Report {
+	return &Report{Tests: []*TestReport{}}
+}
+
+// Passed reports whether all tests passed
+func (r *Report) Passed() bool {
+	for _, test := range r.Tests {
+		if !test.Passed() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Passed reports whether the test and all its test cases passed
+func (t *TestReport) Passed() bool {
+	if t.Error != "" || t.SetUpError != "" || t.TearDownError != "" {
+		return false
+	}
+	for _, testCase := range t.Cases {
+		if !testCase.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// start marks start of a run, it's safe to call on nil report
+func (r *Report) start() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Started.IsZero() {
+		r.Started = time.Now()
+	}
+}
+
+// finish marks end of a run, it's safe to call on nil report
+func (r *Report) finish() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Duration = time.Since(r.Started)
+}
+
+// addTest adds a test to the report, it's safe to call on nil report
+func (r *Report) addTest(test *TestReport) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Tests = append(r.Tests, test)
+}
+
+// newMessageReport reports a request or a response with secrets masked
+func newMessageReport(header http.Header, body []byte, secrets *secrets) *MessageReport {
+	message := &MessageReport{Headers: http.Header{}}
+	for name, values := range header {
+		for _, value := range values {
+			if secrets.redaction.IsSecretHeader(name) {
+				value = Redacted
+			}
+			message.Headers[name] = append(message.Headers[name], secrets.mask(value))
+		}
+	}
+
+	switch {
+	case len(body) == 0:
+	case utf8.Valid(body):
+		message.Body = secrets.mask(string(body))
+	default:
+		message.Body = fmt.Sprintf("(%d bytes of binary data)", len(body))
+	}
+
+	return message
+}
+
+// WriteJSON writes the report as indented JSON, durations are in nanoseconds
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML: a test suite per test and
+// a test case per test case. Failures of SetUp, TearDown and the contract
+// are reported as errors of test cases named after them
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	root := junitTestSuites{Time: junitSeconds(r.Duration)}
+	for _, test := range r.Tests {
+		suite := junitTestSuite{Name: test.Name, Time: junitSeconds(test.Duration)}
+		addError := func(name, message string) {
+			if message == "" {
+				return
+			}
+			suite.Errors++
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      name,
+				ClassName: test.Name,
+				Time:      junitSeconds(0),
+				Error:     &junitProblem{Message: firstLine(message), Text: message},
+			})
+		}
+
+		addError("Contract", test.Error)
+		addError("SetUp", test.SetUpError)
+		for _, testCase := range test.Cases {
+			junitCase := junitTestCase{
+				Name:      testCase.Name,
+				ClassName: test.Name,
+				Time:      junitSeconds(testCase.Duration),
+				SystemOut: testCase.exchange(),
+			}
+			if !testCase.Passed {
+				suite.Failures++
+				message := "test case failed"
+				if len(testCase.Failures) > 0 {
+					message = failureSummary(testCase.Failures[0])
+				}
+				junitCase.Failure = &junitProblem{Message: message, Text: strings.Join(testCase.Failures, "\n\n")}
+			}
+			suite.TestCases = append(suite.TestCases, junitCase)
+		}
+		addError("TearDown", test.TearDownError)
+
+		suite.Tests = len(suite.TestCases)
+		root.Tests += suite.Tests
+		root.Failures += suite.Failures
+		root.Errors += suite.Errors
+		root.Suites = append(root.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// exchange dumps the request and the response of the test case as text,
+// preceded by attempts of the test case if it was retried
+func (c *TestCaseReport) exchange() string {
+	if c.Request == nil {
+		return ""
+	}
+
+	lines := []string{}
+	if len(c.Attempts) > 1 {
+		for i, attempt := range c.Attempts {
+			lines = append(lines, fmt.Sprintf("attempt %d: %s", i+1, attempt.summary()))
+		}
+		lines = append(lines, "")
+	}
+	lines = append(lines, fmt.Sprintf("%s %s", c.Method, c.URL))
+	lines = append(lines, c.Request.lines()...)
+	if c.Response != nil {
+		lines = append(lines, "", fmt.Sprintf("%d %s", c.Status, http.StatusText(c.Status)))
+		lines = append(lines, c.Response.lines()...)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// summary describes the attempt in one line
+func (a *AttemptReport) summary() string {
+	parts := []string{}
+	if a.Status != 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", a.Status, http.StatusText(a.Status)))
+	}
+	if a.Error != "" {
+		parts = append(parts, firstLine(a.Error))
+	}
+	parts = append(parts, "took "+a.Duration.Round(time.Millisecond).String())
+
+	return strings.Join(parts, ", ")
+}
+
+func (m *MessageReport) lines() []string {
+	names := []string{}
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		for _, value := range m.Headers[name] {
+			lines = append(lines, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	if m.Body != "" {
+		lines = append(lines, "", m.Body)
+	}
+
+	return lines
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// failureSummary returns a one line summary of a failure reported by an
+// assertion: its message if it has one, the error otherwise
+func failureSummary(failure string) string {
+	summary := ""
+	for _, line := range strings.Split(failure, "\n") {
+		line = strings.TrimSpace(line)
+		for _, label := range []string{"Error:", "Messages:"} {
+			if strings.HasPrefix(line, label) {
+				summary = strings.TrimSpace(line[len(label):])
+			}
+		}
+	}
+	if summary == "" {
+		return firstLine(failure)
+	}
+
+	return summary
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration":   func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"statusText": http.StatusText,
+	"attempt":    (*AttemptReport).summary,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>API test report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h2 { margin-top: 2em; }
+.passed { color: #2a7d2a; }
+.failed { color: #b02a2a; }
+.case { border: 1px solid #ddd; border-radius: 4px; margin: 0.5em 0; padding: 0.5em 1em; }
+.case.failed { border-color: #e0a0a0; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+pre.failure { background: #fbecec; }
+.exchange { display: flex; gap: 1em; }
+.exchange > div { flex: 1; min-width: 0; }
+</style>
+</head>
+<body>
+<h1>API test report</h1>
+<p>{{if .Passed}}<span class="passed">PASSED</span>{{else}}<span class="failed">FAILED</span>{{end}},
+{{len .Tests}} tests, started {{.Started.Format "2006-01-02 15:04:05"}}, took {{duration .Duration}}</p>
+{{range .Tests}}
+<h2 class="{{if .Passed}}passed{{else}}failed{{end}}">{{.Name}}</h2>
+<p>{{.Method}} {{.Path}}{{if .Description}} &mdash; {{.Description}}{{end}}, took {{duration .Duration}}</p>
+{{if .Error}}<pre class="failure">{{.Error}}</pre>{{end}}
+{{if .SetUpError}}<p class="failed">SetUp failed</p><pre class="failure">{{.SetUpError}}</pre>{{end}}
+{{range .Cases}}
+<div class="case {{if .Passed}}passed{{else}}failed{{end}}">
+<h3>{{if .Passed}}<span class="passed">PASS</span>{{else}}<span class="failed">FAIL</span>{{end}} {{.Name}}</h3>
+<p>{{.Method}} {{.URL}} &rarr; {{if .Status}}{{.Status}} {{statusText .Status}}{{else}}no response{{end}}
+(expected {{.ExpectedStatus}}), took {{duration .Duration}}</p>
+{{range .Failures}}<pre class="failure">{{.}}</pre>{{end}}
+{{if gt (len .Attempts) 1}}<ol class="attempts">{{range .Attempts}}<li>{{attempt .}}</li>{{end}}</ol>{{end}}
+{{if .Request}}
+<div class="exchange">
+<div><h4>Request</h4><pre>{{.Method}} {{.URL}}
+{{range $name, $values := .Request.Headers}}{{range $values}}{{$name}}: {{.}}
+{{end}}{{end}}{{if .Request.Body}}
+{{.Request.Body}}{{end}}</pre></div>
+{{if .Response}}<div><h4>Response</h4><pre>{{.Status}} {{statusText .Status}}
+{{range $name, $values := .Response.Headers}}{{range $values}}{{$name}}: {{.}}
+{{end}}{{end}}{{if .Response.Body}}
+{{.Response.Body}}{{end}}</pre></div>{{end}}
+</div>
+{{end}}
+</div>
+{{end}}
+{{if .TearDownError}}<p class="failed">TearDown failed</p><pre class="failure">{{.TearDownError}}</pre>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a standalone HTML page that shows results
+// of test cases along with their requests and responses
+func (r *Report) WriteHTML(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return htmlReportTemplate.Execute(w, r)
+}
@@ -0,0 +1,158 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRecordsReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"login":"admin","tokens":[{"value":"secret-token","scope":"items"}]}`))
+	}))
+	defer server.Close()
+
+	report := NewReport()
+	runner := NewRunner(server.URL, RunnerConfig{
+		Authenticator: NewBasicAuth("admin", "secret-basic"),
+		Redaction:     Redaction{Headers: []string{"X-Session"}, JSONPaths: []string{"$.password", "$.tokens[*].value"}},
+		Report:        report,
+	})
+	runner.Run(t, &LoginTest{})
+
+	if !assert.Len(t, report.Tests, 1) || !assert.Len(t, report.Tests[0].Cases, 1) {
+		return
+	}
+	assert.True(t, report.Passed())
+	assert.Equal(t, "*schreder.LoginTest", report.Tests[0].Name)
+	assert.Equal(t, "/sessions/{tenant}", report.Tests[0].Path)
+
+	testCase := report.Tests[0].Cases[0]
+	assert.Equal(t, "Logged in", testCase.Name)
+	assert.Equal(t, "POST", testCase.Method)
+	assert.Equal(t, server.URL+"/sessions/acme?signature="+Redacted, testCase.URL)
+	assert.Equal(t, http.StatusCreated, testCase.ExpectedStatus)
+	assert.Equal(t, http.StatusCreated, testCase.Status)
+	assert.True(t, testCase.Passed)
+	assert.Empty(t, testCase.Failures)
+	if assert.NotNil(t, testCase.Request) && assert.NotNil(t, testCase.Response) {
+		assert.Equal(t, Redacted, testCase.Request.Headers.Get("Authorization"))
+		assert.Equal(t, Redacted, testCase.Request.Headers.Get("X-Api-Key"))
+		assert.Equal(t, `{"login":"admin","password":"[REDACTED]"}`, testCase.Request.Body)
+		assert.Equal(t, "application/json", testCase.Response.Headers.Get("Content-Type"))
+		assert.Equal(t, `{"login":"admin","tokens":[{"value":"[REDACTED]","scope":"items"}]}`, testCase.Response.Body)
+	}
+
+	out := &bytes.Buffer{}
+	if assert.NoError(t, report.WriteJSON(out)) {
+		assert.NotContains(t, out.String(), "secret-")
+
+		decoded := &Report{}
+		if assert.NoError(t, json.Unmarshal(out.Bytes(), decoded)) {
+			assert.Equal(t, report.Tests, decoded.Tests)
+		}
+	}
+}
+
+func newTestReport() *Report {
+	return &Report{
+		Started:  time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC),
+		Duration: 1500 * time.Millisecond,
+		Tests: []*TestReport{
+			{
+				Name:     "GetUser",
+				Method:   "GET",
+				Path:     "/users/{id}",
+				Duration: time.Second,
+				Cases: []*TestCaseReport{
+					{
+						Name:           "existing user",
+						Method:         "GET",
+						URL:            "http://testapi.my/users/1",
+						ExpectedStatus: 200,
+						Status:         200,
+						Passed:         true,
+						Duration:       250 * time.Millisecond,
+						Request:        &MessageReport{Headers: http.Header{"Accept": {"application/json"}}},
+						Response: &MessageReport{
+							Headers: http.Header{"Content-Type": {"application/json"}},
+							Body:    `{"name":"<b>First</b>"}`,
+						},
+					},
+					{
+						Name:           "missing user",
+						Method:         "GET",
+						URL:            "http://testapi.my/users/2",
+						ExpectedStatus: 404,
+						Status:         200,
+						Duration:       750 * time.Millisecond,
+						Failures: []string{"Error Trace:\ttestrunner.go:42\n" +
+							"Error:      \tNot equal: 404 (expected)\n" +
+							"            \t        != 200 (actual)"},
+					},
+				},
+			},
+			{
+				Name:          "DeleteUser",
+				Method:        "DELETE",
+				Path:          "/users/{id}",
+				SetUpError:    "could not create a user",
+				TearDownError: "could not clean up\nusers are left",
+				Cases:         []*TestCaseReport{},
+			},
+		},
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, newTestReport().WriteJUnit(out)) {
+		return
+	}
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="4" failures="1" errors="2" time="1.500">
+  <testsuite name="GetUser" tests="2" failures="1" errors="0" time="1.000">
+    <testcase name="existing user" classname="GetUser" time="0.250">
+      <system-out>GET http://testapi.my/users/1&#xA;Accept: application/json&#xA;&#xA;200 OK&#xA;Content-Type: application/json&#xA;&#xA;{&#34;name&#34;:&#34;&lt;b&gt;First&lt;/b&gt;&#34;}</system-out>
+    </testcase>
+    <testcase name="missing user" classname="GetUser" time="0.750">
+      <failure message="Not equal: 404 (expected)">Error Trace:&#x9;testrunner.go:42&#xA;Error:      &#x9;Not equal: 404 (expected)&#xA;            &#x9;        != 200 (actual)</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="DeleteUser" tests="2" failures="0" errors="2" time="0.000">
+    <testcase name="SetUp" classname="DeleteUser" time="0.000">
+      <error message="could not create a user">could not create a user</error>
+    </testcase>
+    <testcase name="TearDown" classname="DeleteUser" time="0.000">
+      <error message="could not clean up">could not clean up&#xA;users are left</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, out.String())
+}
+
+func TestWriteHTML(t *testing.T) {
+	report := newTestReport()
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, report.WriteHTML(out)) {
+		return
+	}
+
+	html := out.String()
+	assert.Contains(t, html, "<!DOCTYPE html>")
+	assert.Contains(t, html, "FAILED</span>,\n2 tests")
+	assert.Contains(t, html, `<span class="passed">PASS</span> existing user`)
+	assert.Contains(t, html, `<span class="failed">FAIL</span> missing user`)
+	assert.Contains(t, html, "GET http://testapi.my/users/2 &rarr; 200 OK\n(expected 404), took 750ms")
+	assert.Contains(t, html, "Content-Type: application/json\n\n{&#34;name&#34;:&#34;&lt;b&gt;First&lt;/b&gt;&#34;}</pre>")
+	assert.Contains(t, html, "SetUp failed</p><pre class=\"failure\">could not create a user</pre>")
+	assert.NotContains(t, html, "<b>First</b>", "bodies are escaped")
+}
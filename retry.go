@@ -0,0 +1,155 @@
+package schreder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEventuallyTimeout  = 10 * time.Second
+	defaultEventuallyInterval = 500 * time.Millisecond
+)
+
+// RetryPolicy defines when the request of a test case is sent again, e.g. when
+// the API is restarting and responds with 503. Responses that are retried are
+// not asserted, every attempt is recorded in the report of the test case
+type RetryPolicy struct {
+	// MaxAttempts is a number of attempts including the first one,
+	// zero and one mean the request is never retried
+	MaxAttempts int
+	// Backoff is a delay before the second attempt, it doubles with every next one
+	Backoff time.Duration
+	// MaxBackoff limits the delay between attempts. Zero means no limit
+	MaxBackoff time.Duration
+	// StatusCodes are codes of responses that are retried, like 502 or 503
+	StatusCodes []int
+	// TransportErrors enables retries of requests that failed to be sent or
+	// timed out, see RunnerConfig.RequestTimeout
+	TransportErrors bool
+}
+
+// backoff returns a delay after given failed attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	return delay
+}
+
+func (p RetryPolicy) retriesStatus(status int) bool {
+	for _, code := range p.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Eventually makes the runner send the request of a test case again and again
+// until all assertions of the case pass or the timeout expires, e.g. to wait
+// for a resource created by an asynchronous endpoint. Custom AssertResponse is
+// called once all other assertions pass, its failures are not retried
+type Eventually struct {
+	// Timeout limits time of all attempts, 10 seconds by default
+	Timeout time.Duration
+	// Interval is a delay between attempts, 500ms by default
+	Interval time.Duration
+}
+
+func (e Eventually) timeout() time.Duration {
+	if e.Timeout > 0 {
+		return e.Timeout
+	}
+	return defaultEventuallyTimeout
+}
+
+func (e Eventually) interval() time.Duration {
+	if e.Interval > 0 {
+		return e.Interval
+	}
+	return defaultEventuallyInterval
+}
+
+// caseRequest is a request of a test case, it's sent once per attempt
+type caseRequest struct {
+	testCase    TestCase
+	method      string
+	path        string
+	url         string
+	body        []byte
+	contentType string
+
+	retry RetryPolicy
+	// deadline of the eventual test case, zero for other ones
+	deadline time.Time
+}
+
+// retried tells whether the request may be sent more than once
+func (c *caseRequest) retried() bool {
+	return c.retry.MaxAttempts > 1 || !c.deadline.IsZero()
+}
+
+// canRetry tells whether the request may be sent again after given attempt
+func (c *caseRequest) canRetry(attempt int) bool {
+	if !c.deadline.IsZero() {
+		return time.Now().Add(c.delay(attempt)).Before(c.deadline)
+	}
+	return attempt < c.retry.MaxAttempts
+}
+
+// delay returns time to wait after given failed attempt
+func (c *caseRequest) delay(attempt int) time.Duration {
+	if c.testCase.Eventually != nil {
+		return c.testCase.Eventually.interval()
+	}
+	return c.retry.backoff(attempt)
+}
+
+// attemptT collects failures and logs of an attempt of an eventual test case,
+// they are reported only if no attempts are left
+type attemptT struct {
+	entries []attemptEntry
+}
+
+type attemptEntry struct {
+	failure bool
+	message string
+}
+
+func (t *attemptT) Errorf(format string, args ...interface{}) {
+	t.entries = append(t.entries, attemptEntry{failure: true, message: fmt.Sprintf(format, args...)})
+}
+
+func (t *attemptT) Logf(format string, args ...interface{}) {
+	t.entries = append(t.entries, attemptEntry{message: fmt.Sprintf(format, args...)})
+}
+
+// failures returns a summary of failures of the attempt
+func (t *attemptT) failures() string {
+	failures := []string{}
+	for _, entry := range t.entries {
+		if entry.failure {
+			failures = append(failures, failureSummary(entry.message))
+		}
+	}
+
+	return strings.Join(failures, "\n")
+}
+
+// replay reports collected failures and logs to the test case
+func (t *attemptT) replay(to caseTestingT) {
+	for _, entry := range t.entries {
+		if entry.failure {
+			to.Errorf("%s", entry.message)
+		} else {
+			to.Logf("%s", entry.message)
+		}
+	}
+}
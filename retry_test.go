@@ -0,0 +1,164 @@
+package schreder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/testmeifyoucan/schreder/match"
+)
+
+// newAsyncServer responds with given status until the request with given
+// number, the job is done from then on
+func newAsyncServer(status int, doneAt int32) *httptest.Server {
+	var requests int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < doneAt {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"status":"done"}`)
+	}))
+}
+
+func newJobTest(testCase TestCase) *FileTest {
+	return &FileTest{TestName: "GetJob", TestMethod: "GET", TestPath: "/jobs/1", Cases: []TestCase{testCase}}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 300*time.Millisecond, policy.backoff(10))
+
+	policy.MaxBackoff = 0
+	assert.Equal(t, 800*time.Millisecond, policy.backoff(4))
+}
+
+func TestRunRetriesStatus(t *testing.T) {
+	server := newAsyncServer(http.StatusServiceUnavailable, 3)
+	defer server.Close()
+
+	report := NewReport()
+	runner := NewRunner(server.URL, RunnerConfig{
+		Retry:  RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, StatusCodes: []int{503}},
+		Report: report,
+	})
+	runner.Run(t, newJobTest(TestCase{ExpectedHttpCode: 200, ExpectedData: map[string]interface{}{"status": "done"}}))
+
+	if !assert.Len(t, report.Tests, 1) || !assert.Len(t, report.Tests[0].Cases, 1) {
+		return
+	}
+	testCase := report.Tests[0].Cases[0]
+	assert.True(t, testCase.Passed)
+	if assert.Len(t, testCase.Attempts, 3) {
+		assert.Equal(t, 503, testCase.Attempts[0].Status)
+		assert.Equal(t, "status is retried", testCase.Attempts[1].Error)
+		assert.Equal(t, 200, testCase.Attempts[2].Status)
+		assert.Empty(t, testCase.Attempts[2].Error)
+		assert.Equal(t, testCase.Response, testCase.Attempts[2].Response)
+	}
+}
+
+func TestRunRetriesTransportErrors(t *testing.T) {
+	attempts := 0
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return &http.Response{StatusCode: 204, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	report := NewReport()
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client, Report: report})
+	runner.Run(t, newJobTest(TestCase{
+		ExpectedHttpCode: 204,
+		Retry:            &RetryPolicy{MaxAttempts: 2, TransportErrors: true},
+	}))
+
+	assert.Equal(t, 2, attempts)
+	if assert.Len(t, report.Tests, 1) && assert.Len(t, report.Tests[0].Cases[0].Attempts, 2) {
+		assert.Equal(t, "connection reset by peer", report.Tests[0].Cases[0].Attempts[0].Error)
+	}
+}
+
+func TestRunEventually(t *testing.T) {
+	server := newAsyncServer(http.StatusNotFound, 3)
+	defer server.Close()
+
+	report := NewReport()
+	runner := NewRunner(server.URL, RunnerConfig{Report: report})
+	runner.Run(t, newJobTest(TestCase{
+		ExpectedHttpCode: 200,
+		ExpectedData:     map[string]interface{}{"status": "done"},
+		Eventually:       &Eventually{Timeout: 5 * time.Second, Interval: 10 * time.Millisecond},
+	}))
+
+	if !assert.Len(t, report.Tests, 1) {
+		return
+	}
+	testCase := report.Tests[0].Cases[0]
+	assert.True(t, testCase.Passed)
+	assert.Empty(t, testCase.Failures, "failures of attempts are not failures of the case")
+	if assert.Len(t, testCase.Attempts, 3) {
+		assert.Equal(t, 404, testCase.Attempts[0].Status)
+		assert.Contains(t, testCase.Attempts[0].Error, "Not equal")
+	}
+	assert.Contains(t, testCase.exchange(), "attempt 1: 404 Not Found, Not equal:")
+}
+
+func TestCheckResponseCapturesPassedResponse(t *testing.T) {
+	runner := NewRunner("http://testapi.my", RunnerConfig{})
+	run := newRunContext(context.Background(), context.Background(), runner)
+	c := &caseRequest{method: "GET", path: "/jobs/1", testCase: TestCase{
+		ExpectedHttpCode: 200,
+		ExpectedData:     map[string]interface{}{"status": "done", "result": match.Type("")},
+		Captures:         []Capture{{Variable: "result", JSONPointer: "/result"}},
+	}}
+	resp := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {"application/json"}}}
+
+	assert.False(t, runner.checkResponse(&attemptT{}, nil, c, resp, []byte(`{"status":"pending","result":"partial"}`), run))
+	_, captured := run.Vars.Get("result")
+	assert.False(t, captured, "variables are not captured from failed attempts")
+
+	assert.True(t, runner.checkResponse(&attemptT{}, nil, c, resp, []byte(`{"status":"done","result":"1"}`), run))
+	result, _ := run.Vars.Get("result")
+	assert.Equal(t, "1", result)
+}
+
+// TestRunEventuallyTimesOut runs itself in a separate process, because the test
+// it runs fails
+func TestRunEventuallyTimesOut(t *testing.T) {
+	if os.Getenv("SCHREDER_PANICKING_TEST") == "1" {
+		server := newAsyncServer(http.StatusNotFound, 1000)
+		defer server.Close()
+
+		runner := NewRunner(server.URL, RunnerConfig{})
+		runner.Run(t, newJobTest(TestCase{
+			ExpectedHttpCode: 200,
+			Eventually:       &Eventually{Timeout: 100 * time.Millisecond, Interval: 10 * time.Millisecond},
+		}))
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunEventuallyTimesOut$", "-test.v")
+	cmd.Env = append(os.Environ(), "SCHREDER_PANICKING_TEST=1")
+	out, err := cmd.CombinedOutput()
+
+	assert.Error(t, err, "test fails")
+	assert.Contains(t, string(out), "attempt 1 failed, retrying in 10ms")
+	assert.Equal(t, 1, strings.Count(string(out), "Error Trace:"), "only the last attempt fails the case:\n%s", out)
+}
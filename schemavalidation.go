@@ -0,0 +1,298 @@
+package schreder
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"github.com/testmeifyoucan/schreder/internal/jsonvalue"
+	"github.com/testmeifyoucan/schreder/match"
+)
+
+// validateResponseSchema validates response body against the schema of expected
+// data. The schema is built the same way as it's done for documentation, so
+// the check proves that the documentation describes actual responses.
+// Returns violations of the schema prefixed with JSON pointers to invalid values.
+func validateResponseSchema(expected interface{}, responseBody []byte) ([]string, error) {
+	defs := spec.Definitions{}
+	schema := generateSpecSchema(expected, defs)
+	if example := match.Example(expected); example != nil {
+		markNullable(reflect.TypeOf(example), schema, defs, map[string]bool{})
+	}
+
+	return validateJsonSchema(schema, defs, decodeJsonBody(responseBody))
+}
+
+// validateJsonSchema validates a value decoded from JSON against given schema.
+// References to definitions are resolved with defs
+func validateJsonSchema(schema *spec.Schema, defs spec.Definitions, value interface{}) ([]string, error) {
+	v := &schemaValidator{defs: defs}
+	if err := v.validate("", schema, value); err != nil {
+		return nil, err
+	}
+
+	return v.violations, nil
+}
+
+// markNullable marks schemas of pointer, slice and map fields of given Go type
+// with x-nullable, since such fields of expected data are encoded as null when
+// they are nil. Definitions of struct types are marked once
+func markNullable(t reflect.Type, schema *spec.Schema, defs spec.Definitions, visited map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if ref := schema.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, swaggerDefinitionsRefPrefix)
+		def, ok := defs[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		markNullable(t, &def, defs, visited)
+		defs[name] = def
+		return
+	}
+
+	mark := func(t reflect.Type, schema *spec.Schema) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Map:
+			schema.AddExtension("x-nullable", true)
+		case reflect.Slice:
+			if t.Elem().Kind() != reflect.Uint8 {
+				schema.AddExtension("x-nullable", true)
+			}
+		}
+		markNullable(t, schema, defs, visited)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		forEachJsonField(t, func(name string, fieldType reflect.Type) {
+			if property, ok := schema.Properties[name]; ok {
+				mark(fieldType, &property)
+				schema.Properties[name] = property
+			}
+		})
+	case reflect.Slice, reflect.Array:
+		if schema.Items != nil && schema.Items.Schema != nil {
+			mark(t.Elem(), schema.Items.Schema)
+		}
+	case reflect.Map:
+		for pattern, property := range schema.PatternProperties {
+			mark(t.Elem(), &property)
+			schema.PatternProperties[pattern] = property
+		}
+		if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+			mark(t.Elem(), schema.AdditionalProperties.Schema)
+		}
+	}
+}
+
+// forEachJsonField calls fn for exported fields of a struct type by their names
+// in JSON. Fields of embedded structs are promoted as encoding/json does
+func forEachJsonField(t reflect.Type, fn func(name string, fieldType reflect.Type)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				forEachJsonField(embedded, fn)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fn(name, field.Type)
+	}
+}
+
+// decodeJsonBody decodes body into generic JSON representation.
+// Body that is not a valid JSON is treated as a string
+func decodeJsonBody(body []byte) interface{} {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+
+	return value
+}
+
+// schemaValidator checks a value decoded from JSON against a schema of Swagger spec.
+// Keywords that are used by the generators and the most common ones are supported
+type schemaValidator struct {
+	defs       spec.Definitions
+	violations []string
+}
+
+func (v *schemaValidator) violation(path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "/"
+	}
+	v.violations = append(v.violations, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(path string, schema *spec.Schema, value interface{}) error {
+	if nullable, _ := schema.Extensions.GetBool("x-nullable"); nullable && value == nil {
+		return nil
+	}
+
+	if ref := schema.Ref.String(); ref != "" {
+		def, ok := v.defs[strings.TrimPrefix(ref, swaggerDefinitionsRefPrefix)]
+		if !ok {
+			return fmt.Errorf("could not resolve reference '%s'", ref)
+		}
+		return v.validate(path, &def, value)
+	}
+
+	for i := range schema.AllOf {
+		if err := v.validate(path, &schema.AllOf[i], value); err != nil {
+			return err
+		}
+	}
+
+	if len(schema.Type) > 0 && !hasAnyJsonType(value, schema.Type) {
+		v.violation(path, "expected %s, got %s", strings.Join(schema.Type, " or "), jsonvalue.Describe(value))
+		return nil
+	}
+
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, item := range schema.Enum {
+			if expected, err := objToJsonValue(item); err == nil && reflect.DeepEqual(expected, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.violation(path, "%s is not one of allowed values", jsonvalue.Describe(value))
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.validateString(path, schema, value)
+	case []interface{}:
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return nil
+		}
+		for i, item := range value {
+			if err := v.validate(fmt.Sprintf("%s/%d", path, i), schema.Items.Schema, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		return v.validateObject(path, schema, value)
+	}
+
+	return nil
+}
+
+func (v *schemaValidator) validateString(path string, schema *spec.Schema, value string) {
+	if schema.Pattern != "" {
+		if re, err := regexp.Compile(schema.Pattern); err == nil && !re.MatchString(value) {
+			v.violation(path, "%s does not match pattern '%s'", jsonvalue.Describe(value), schema.Pattern)
+		}
+	}
+
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			v.violation(path, "%s is not a date-time", jsonvalue.Describe(value))
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			v.violation(path, "%s is not a date", jsonvalue.Describe(value))
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(path string, schema *spec.Schema, value map[string]interface{}) error {
+	for _, name := range schema.Required {
+		if _, ok := value[name]; !ok {
+			v.violation(path+"/"+jsonvalue.EscapeToken(name), "required property is missing")
+		}
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "/" + jsonvalue.EscapeToken(key)
+		if property, ok := schema.Properties[key]; ok {
+			if err := v.validate(keyPath, &property, value[key]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		matched := false
+		for pattern, property := range schema.PatternProperties {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(key) {
+				matched = true
+				if err := v.validate(keyPath, &property, value[key]); err != nil {
+					return err
+				}
+			}
+		}
+
+		additional := schema.AdditionalProperties
+		switch {
+		case matched || additional == nil:
+		case additional.Schema != nil:
+			if err := v.validate(keyPath, additional.Schema, value[key]); err != nil {
+				return err
+			}
+		case !additional.Allows:
+			v.violation(keyPath, "property is not allowed")
+		}
+	}
+
+	return nil
+}
+
+func hasAnyJsonType(value interface{}, jsonTypes []string) bool {
+	for _, jsonType := range jsonTypes {
+		if hasJsonType(value, jsonType) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJsonType checks that value decoded from JSON is of given JSON schema type
+func hasJsonType(value interface{}, jsonType string) bool {
+	switch value := value.(type) {
+	case nil:
+		return jsonType == "null"
+	case bool:
+		return jsonType == "boolean"
+	case float64:
+		return jsonType == "number" || jsonType == "integer" && value == float64(int64(value))
+	case string:
+		return jsonType == "string"
+	case []interface{}:
+		return jsonType == "array"
+	case map[string]interface{}:
+		return jsonType == "object"
+	}
+	return false
+}
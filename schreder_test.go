@@ -1,6 +1,9 @@
 package schreder
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -13,20 +16,10 @@ import (
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/validate"
 	"github.com/go-raml/raml"
-	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
-)
-
-func TestRunApi(t *testing.T) {
-	httpmock.Activate()
-	defer httpmock.DeactivateAndReset()
-	setupMock()
 
-	tests := getTests()
-
-	runner := NewRunner("http://testapi.my", RunnerConfig{})
-	runner.Run(t, tests...)
-}
+	"github.com/testmeifyoucan/schreder/match"
+)
 
 func TestGenerateSwaggerYAML(t *testing.T) {
 	seed := spec.Swagger{}
@@ -75,6 +68,42 @@ func TestGenerateSwaggerYAML(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestGenerateOpenAPIYAML(t *testing.T) {
+	seed := OpenAPI{}
+	seed.Servers = []OpenAPIServer{{URL: "http://testapi.my/"}}
+	seed.Info = &spec.Info{}
+	seed.Info.Description = "Our very little example API with 2 endpoints"
+	seed.Info.Title = "Example API"
+	seed.Info.Version = "0.1"
+
+	generator := NewOpenAPIGeneratorYAML(seed)
+	tests := getTests()
+
+	doc, err := generator.Generate(tests)
+	assert.NoError(t, err, "could not generate docs")
+
+	// checking equality of generated and expected doc
+	actual := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(doc, &actual)
+	assert.NoError(t, err, "could not unmarshal generated doc into map")
+
+	fixture, err := ioutil.ReadFile("fixtures/openapi/openapi.yml")
+	assert.NoError(t, err, "could not read fixture file")
+
+	expected := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(fixture, &expected)
+	assert.NoError(t, err, "could not unmarshal fixture into map")
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestGenerateOpenAPIUnsupportedVersion(t *testing.T) {
+	generator := NewOpenAPIGeneratorYAML(OpenAPI{OpenAPI: "2.0"})
+
+	_, err := generator.Generate(getTests())
+	assert.Error(t, err)
+}
+
 func TestGenerateRaml(t *testing.T) {
 	seed := raml.APIDefinition{}
 	seed.Version = "0.1"
@@ -106,99 +135,416 @@ func TestGenerateRaml(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
-func getTests() []Test {
-	return []Test{
-		&HelloTest{},
-		&GetUserTest{},
-		&CreateUserTest{},
-		&UpdateUserTest{},
-		&DeleteUserTest{},
+func TestGenerateRaml10(t *testing.T) {
+	seed := raml.APIDefinition{}
+	seed.Version = "0.1"
+	seed.Title = "Example API"
+	seed.BaseUri = "http://testapi.my/"
+	seed.Protocols = []string{"HTTP", "HTTPS"}
+	seed.MediaType = "application/json"
+
+	generator := NewRaml10Generator(seed)
+	tests := getTests()
+
+	doc, err := generator.Generate(tests)
+	assert.NoError(t, err, "could not generate docs")
+	assert.Equal(t, "#%RAML 1.0", string(doc[0:10]), "Specific RAML header is expected")
+
+	// checking equality of generated and expected doc
+	actual := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(doc, &actual)
+	assert.NoError(t, err, "could not unmarshal generated doc into map")
+
+	fixture, err := ioutil.ReadFile("fixtures/raml/raml10.yml")
+	assert.NoError(t, err, "could not read fixture file")
+
+	expected := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(fixture, &expected)
+	assert.NoError(t, err, "could not unmarshal fixture into map")
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestGenerateMergesTestCasesWithSameCode(t *testing.T) {
+	tests := []Test{&ListUsersTest{}}
+	expectedExamples := []interface{}{"All users", "Users filtered by login", "Number of users"}
+
+	// swagger keeps the first case as an example and all the cases in x-examples
+	doc, err := NewSwaggerGeneratorYAML(spec.Swagger{}).Generate(tests)
+	assert.NoError(t, err, "could not generate swagger doc")
+	response := yamlPath(t, doc, "paths", "/users", "get", "responses", "200")
+	assert.Equal(t, "All users\n\nUsers filtered by login\n\nNumber of users", yamlPath(t, response, "description"))
+	assert.Len(t, yamlPath(t, response, "examples", "application/json"), 2)
+	assert.Len(t, yamlPath(t, response, "schema", "x-oneof"), 2)
+	assert.Subset(t, mapKeys(yamlPath(t, response, "schema", "x-examples")), expectedExamples)
+
+	// OpenAPI keeps all the cases as named examples and combines schemas with oneOf
+	doc, err = NewOpenAPIGeneratorYAML(OpenAPI{}).Generate(tests)
+	assert.NoError(t, err, "could not generate OpenAPI doc")
+	content := yamlPath(t, doc, "paths", "/users", "get", "responses", "200", "content", "application/json")
+	assert.Len(t, yamlPath(t, content, "schema", "oneOf"), 2)
+	assert.Len(t, yamlPath(t, content, "examples"), 3)
+	assert.Subset(t, mapKeys(yamlPath(t, content, "examples")), expectedExamples)
+
+	// RAML 1.0 keeps all the cases as named examples and joins types into a union
+	doc, err = NewRaml10Generator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	assert.NoError(t, err, "could not generate RAML 1.0 doc")
+	body := yamlPath(t, doc, "/users", "get", "responses", 200, "body", "application/json")
+	assert.Equal(t, "User[] | integer", yamlPath(t, body, "type"))
+	assert.Len(t, yamlPath(t, body, "examples"), 3)
+	assert.Subset(t, mapKeys(yamlPath(t, body, "examples")), expectedExamples)
+
+	// RAML 0.8 combines schemas with oneOf and lists examples in description
+	doc, err = NewRamlGenerator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	assert.NoError(t, err, "could not generate RAML 0.8 doc")
+	response = yamlPath(t, doc, "/users", "get", "responses", 200)
+	assert.Contains(t, yamlPath(t, response, "body", "schema"), `"oneOf"`)
+	for _, name := range expectedExamples {
+		assert.Contains(t, yamlPath(t, response, "description"), fmt.Sprintf("**%s**", name))
 	}
 }
 
-func setupMock() {
-
-	testUser := User{
-		EventsURL:         "https://api.github.com/users/octocat/events{/privacy}",
-		Followers:         20,
-		FollowersURL:      "https://api.github.com/users/octocat/followers",
-		Following:         0,
-		FollowingURL:      "https://api.github.com/users/octocat/following{/other_user}",
-		GistsURL:          "https://api.github.com/users/octocat/gists{/gist_id}",
-		Hireable:          false,
-		HTMLURL:           "https://github.com/octocat",
-		Location:          "San Francisco",
-		Login:             "octocat",
-		Name:              "monalisa octocat",
-		OrganizationsURL:  "https://api.github.com/users/octocat/orgs",
-		PublicRepos:       2,
-		ReceivedEventsURL: "https://api.github.com/users/octocat/received_events",
-		ReposURL:          "https://api.github.com/users/octocat/repos",
-		StarredURL:        "https://api.github.com/users/octocat/starred{/owner}{/repo}",
-		SubscriptionsURL:  "https://api.github.com/users/octocat/subscriptions",
-		Type:              "User",
-		URL:               "https://api.github.com/users/octocat",
-	}
-
-	httpmock.RegisterResponder("GET", "http://testapi.my/hello",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewStringResponse(200, "Hello World!")
-			return resp, nil
-		},
-	)
+func TestGenerateKeepsExamplesWithSameName(t *testing.T) {
+	tests := []Test{&FileTest{TestName: "GetUser", TestMethod: "GET", TestPath: "/users/{id}", Cases: []TestCase{
+		{Description: "User", PathParams: ParamMap{"id": Param{Value: 1}}, ExpectedHttpCode: 200,
+			ExpectedData: map[string]interface{}{"id": 1, "name": "First"}},
+		{Description: "User", PathParams: ParamMap{"id": Param{Value: 2}}, ExpectedHttpCode: 200,
+			ExpectedData: map[string]interface{}{"id": 2, "name": "Second"}},
+	}}}
+	expectedExamples := []interface{}{"User (case 1)", "User (case 2)"}
+
+	doc, err := NewSwaggerGeneratorYAML(spec.Swagger{}).Generate(tests)
+	if assert.NoError(t, err, "could not generate swagger doc") {
+		response := yamlPath(t, doc, "paths", "/users/{id}", "get", "responses", "200")
+		assert.Len(t, yamlPath(t, response, "schema", "x-examples"), 2)
+		assert.Subset(t, mapKeys(yamlPath(t, response, "schema", "x-examples")), expectedExamples)
+	}
 
-	httpmock.RegisterResponder("GET", "http://testapi.my/user/octocat",
-		func(req *http.Request) (*http.Response, error) {
-			return httpmock.NewJsonResponse(200, testUser)
-		},
-	)
+	doc, err = NewOpenAPIGeneratorYAML(OpenAPI{}).Generate(tests)
+	if assert.NoError(t, err, "could not generate OpenAPI doc") {
+		content := yamlPath(t, doc, "paths", "/users/{id}", "get", "responses", "200", "content", "application/json")
+		assert.Len(t, yamlPath(t, content, "examples"), 2)
+		assert.Subset(t, mapKeys(yamlPath(t, content, "examples")), expectedExamples)
+	}
 
-	httpmock.RegisterResponder("GET", "http://testapi.my/user/someveryunknown",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewStringResponse(404, "user someveryunknown not found")
-			return resp, nil
-		},
-	)
+	doc, err = NewRaml10Generator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	if assert.NoError(t, err, "could not generate RAML 1.0 doc") {
+		body := yamlPath(t, doc, "/users/{id}", "get", "responses", 200, "body", "application/json")
+		assert.Len(t, yamlPath(t, body, "examples"), 2)
+		assert.Subset(t, mapKeys(yamlPath(t, body, "examples")), expectedExamples)
+	}
+}
 
-	httpmock.RegisterResponder("GET", "http://testapi.my/user/BadGuy",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewStringResponse(500, "BadGuy failed me :(")
-			return resp, nil
-		},
-	)
+func TestGenerateConflictingTests(t *testing.T) {
+	tests := []Test{&GetUserTest{}, &FindUserTest{}}
+	generators := map[string]IDocGenerator{
+		"swagger":  NewSwaggerGeneratorYAML(spec.Swagger{}),
+		"openapi":  NewOpenAPIGeneratorYAML(OpenAPI{}),
+		"raml":     NewRamlGenerator(raml.APIDefinition{Title: "Example API"}),
+		"raml 1.0": NewRaml10Generator(raml.APIDefinition{Title: "Example API"}),
+	}
 
-	httpmock.RegisterResponder("POST", "http://testapi.my/user",
-		func(req *http.Request) (*http.Response, error) {
-			return httpmock.NewJsonResponse(201, testUser)
-		},
-	)
+	for name, generator := range generators {
+		_, err := generator.Generate(tests)
+		if assert.Error(t, err, "conflict is expected by default in %s generator", name) {
+			assert.Contains(t, err.Error(), "*schreder.GetUserTest")
+			assert.Contains(t, err.Error(), "*schreder.FindUserTest")
+		}
 
-	httpmock.RegisterResponder("PATCH", "http://testapi.my/user/octocat",
-		func(req *http.Request) (*http.Response, error) {
-			patchedUser := testUser
-			patchedUser.Name = "I Am Updated!"
-			return httpmock.NewJsonResponse(200, patchedUser)
-		},
-	)
+		_, err = WithConflictPolicy(generator, ConflictError, nil).Generate(tests)
+		assert.Error(t, err, "conflict is expected in %s generator", name)
 
-	httpmock.RegisterResponder("DELETE", "http://testapi.my/user/octocat",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewBytesResponse(204, nil)
-			return resp, nil
-		},
-	)
+		warnings := &bytes.Buffer{}
+		_, err = WithConflictPolicy(generator, ConflictWarn, warnings).Generate(tests)
+		assert.NoError(t, err, "only warning is expected in %s generator", name)
+		assert.Contains(t, warnings.String(), "both describe GET /user/{username}", name)
 
-	httpmock.RegisterResponder("DELETE", "http://testapi.my/user/someveryunknown",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewStringResponse(404, "user someveryunknown not found")
-			return resp, nil
-		},
-	)
+		_, err = WithConflictPolicy(generator, ConflictWarn, nil).Generate(tests)
+		assert.NoError(t, err, "warning is discarded in %s generator", name)
+	}
+
+	doc, err := WithConflictPolicy(NewOpenAPIGeneratorYAML(OpenAPI{}), ConflictMerge, nil).Generate(tests)
+	assert.NoError(t, err)
+	examples := yamlPath(t, doc, "paths", "/user/{username}", "get", "responses", "200", "content", "application/json", "examples")
+	assert.Subset(t, mapKeys(examples), []interface{}{"Successful getting of user details", "User with dash in login"})
+}
+
+func TestRunWithMatchers(t *testing.T) {
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body: ioutil.NopCloser(bytes.NewBufferString(`{
+				"total_count": 2,
+				"updated_at": "2016-10-16T10:00:00+02:00",
+				"items": [{"login": "monalisa", "id": 7}, {"login": "octocat", "id": 8, "site_admin": true}]
+			}`)),
+		}, nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client})
+	runner.Run(t, &SearchUsersTest{})
+}
+
+func TestGenerateWithMatchers(t *testing.T) {
+	tests := []Test{&SearchUsersTest{}}
+
+	doc, err := NewSwaggerGeneratorYAML(spec.Swagger{}).Generate(tests)
+	assert.NoError(t, err, "could not generate swagger doc")
+	response := yamlPath(t, doc, "paths", "/search/users", "get", "responses", "200")
+	assert.Equal(t, "#/definitions/SearchResult", yamlPath(t, response, "schema", "$ref"))
+	assert.Equal(t, "2016-01-02T15:04:05Z", yamlPath(t, response, "examples", "application/json", "updated_at"))
+	assert.NotNil(t, yamlPath(t, doc, "definitions", "SearchResult"))
+
+	doc, err = NewRaml10Generator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	assert.NoError(t, err, "could not generate RAML 1.0 doc")
+	body := yamlPath(t, doc, "/search/users", "get", "responses", 200, "body", "application/json")
+	assert.Equal(t, "SearchResult", yamlPath(t, body, "type"))
+	assert.Equal(t, 1, yamlPath(t, body, "examples", "Users found", "value", "total_count"))
+}
+
+func TestGenerateParamsReferringVariables(t *testing.T) {
+	tests := []Test{
+		&FileTest{TestMethod: "POST", TestPath: "/items", Cases: []TestCase{{
+			ExpectedHttpCode: 201,
+			ExpectedData:     match.WithExample(map[string]interface{}{"id": match.Any()}, map[string]interface{}{"id": 7}),
+			Captures:         []Capture{{Variable: "id", JSONPointer: "/id"}, {Variable: "token", Header: "X-Token"}},
+		}}},
+		&FileTest{TestMethod: "DELETE", TestPath: "/items/{id}", Cases: []TestCase{{
+			PathParams:       ParamMap{"id": Param{Value: "${id}"}},
+			QueryParams:      ParamMap{"token": Param{Value: "${token}"}, "comment": Param{Value: "$${id}"}},
+			ExpectedHttpCode: 204,
+		}}},
+	}
+
+	doc, err := NewSwaggerGeneratorYAML(spec.Swagger{}).Generate(tests)
+	if !assert.NoError(t, err) {
+		return
+	}
+	params := map[interface{}]interface{}{}
+	for _, param := range yamlPath(t, doc, "paths", "/items/{id}", "delete", "parameters").([]interface{}) {
+		params[yamlPath(t, param, "name")] = param
+	}
+	assert.Equal(t, "integer", yamlPath(t, params["id"], "type"), "type of the captured value is documented")
+	assert.Nil(t, yamlPath(t, params["id"], "default"), "references to variables are not documented")
+	assert.Equal(t, "string", yamlPath(t, params["token"], "type"))
+	assert.Nil(t, yamlPath(t, params["token"], "default"))
+	assert.Equal(t, "${id}", yamlPath(t, params["comment"], "default"))
+
+	doc, err = NewRaml10Generator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	if assert.NoError(t, err) {
+		id := yamlPath(t, doc, "/items/{id}", "uriParameters", "id")
+		assert.Equal(t, "integer", yamlPath(t, id, "type"))
+		assert.Nil(t, yamlPath(t, id, "default"))
+	}
+}
 
-	httpmock.RegisterResponder("DELETE", "http://testapi.my/user/BadGuy",
-		func(req *http.Request) (*http.Response, error) {
-			resp := httpmock.NewStringResponse(500, "BadGuy failed me :(")
-			return resp, nil
+func TestContractCheck(t *testing.T) {
+	contract, err := LoadContract("fixtures/swagger/swagger.yml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, test := range getTests() {
+		assert.Empty(t, contract.Check(test), "%T is expected to follow the contract", test)
+	}
+
+	assert.Equal(t, []string{"path '/users' is not declared"}, contract.Check(&ListUsersTest{}))
+	assert.Equal(t, []string{
+		"case 'User by login': path parameter 'login' is not declared",
+		"case 'User by login': query parameter 'fields' is not declared",
+		"case 'User by login': header 'X-Api-Version' is not declared",
+		"case 'User by login': request body is not declared",
+		"case 'User is a teapot': status code 418 is not declared",
+	}, contract.Check(&OutdatedGetUserTest{}))
+
+	violations, err := contract.validateResponseBody("GET", "/user/{username}", 200, []byte(`{"id": "1", "login": "octocat"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`/id: must be of type integer: "string"`}, violations)
+
+	violations, err = contract.validateRequestBody("POST", "/user", User{Login: "octocat"})
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+const contractKeywordsJSON = `{
+	"swagger": "2.0",
+	"info": {"title": "Items", "version": "1"},
+	"paths": {
+		"/items": {
+			"post": {
+				"parameters": [{"$ref": "#/parameters/item"}],
+				"responses": {"201": {"$ref": "#/responses/item"}}
+			}
+		}
+	},
+	"parameters": {
+		"item": {"name": "body", "in": "body", "schema": {"$ref": "#/definitions/Item"}}
+	},
+	"responses": {
+		"item": {"description": "Created item", "schema": {"$ref": "#/definitions/Item"}}
+	},
+	"definitions": {
+		"Item": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "minLength": 3, "maxLength": 5},
+				"price": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "multipleOf": 0.5},
+				"count": {"type": "integer", "maximum": 10},
+				"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+				"comment": {"type": "string", "x-nullable": true},
+				"owner": {"$ref": "#/definitions/Owner"}
+			}
 		},
-	)
+		"Owner": {"type": "object", "properties": {"id": {"type": "integer"}}, "x-nullable": true}
+	}
+}`
+
+func TestContractValidatesSchemaKeywords(t *testing.T) {
+	swagger := &spec.Swagger{}
+	if !assert.NoError(t, json.Unmarshal([]byte(contractKeywordsJSON), swagger)) {
+		return
+	}
+	contract, err := NewContract(swagger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "#/definitions/Item", swagger.Parameters["item"].Schema.Ref.String(), "the spec is not changed")
+
+	violations, err := contract.validateResponseBody("POST", "/items", 201,
+		[]byte(`{"name": "first", "price": 1.5, "count": 10, "tags": ["a", "b"], "comment": null, "owner": null}`))
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = contract.validateResponseBody("POST", "/items", 201,
+		[]byte(`{"name": "ab", "price": 0.7, "count": 11, "tags": ["a", "a", "b"], "owner": {"id": "1"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/count: should be less than or equal to 10",
+		"/name: should be at least 3 chars long",
+		`/owner/id: must be of type integer: "string"`,
+		"/price: should be a multiple of 0.5",
+		"/tags: should have at most 2 items",
+		"/tags: shouldn't contain duplicates",
+	}, violations)
+
+	violations, err = contract.validateRequestBody("POST", "/items", map[string]interface{}{"name": "too long", "price": 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/name: should be at most 5 chars long", "/price: should be greater than 0"}, violations)
+}
+
+func TestGenerateSwaggerFormParams(t *testing.T) {
+	seed := spec.Swagger{}
+	seed.Consumes = []string{"application/json"}
+
+	doc, err := NewSwaggerGeneratorYAML(seed).Generate([]Test{&UploadAvatarTest{}, &CreateUserTest{}})
+	assert.NoError(t, err, "could not generate swagger doc")
+
+	op := yamlPath(t, doc, "paths", "/user/{username}/avatar", "post")
+	assert.Equal(t, []interface{}{"multipart/form-data"}, yamlPath(t, op, "consumes"))
+	params := yamlPath(t, op, "parameters").([]interface{})
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"name": "avatar", "in": "formData", "type": "file"},
+		map[interface{}]interface{}{"name": "public", "in": "formData", "type": "boolean", "default": true},
+		map[interface{}]interface{}{"name": "tags", "in": "formData", "type": "array", "items": map[interface{}]interface{}{"type": "string"}, "collectionFormat": "multi"},
+	}, params[len(params)-3:])
+
+	// JSON body is accepted globally
+	assert.Nil(t, yamlPath(t, doc, "paths", "/user", "post", "consumes"))
+}
+
+func TestGenerateSecurity(t *testing.T) {
+	tests := []Test{&ListItemsTest{}, &DeleteItemsTest{}}
+	oauth2 := map[interface{}]interface{}{"oauth2": []interface{}{"items"}}
+	apiKey := map[interface{}]interface{}{"apiKey": []interface{}{}}
+
+	doc, err := NewSwaggerGeneratorYAML(spec.Swagger{}).Generate(tests)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(doc), "secret")
+		assert.Equal(t, map[interface{}]interface{}{"type": "apiKey", "name": "api_key", "in": "query"},
+			yamlPath(t, doc, "securityDefinitions", "apiKey"))
+		assert.Equal(t, "application", yamlPath(t, doc, "securityDefinitions", "oauth2", "flow"))
+		assert.Equal(t, []interface{}{oauth2, apiKey}, yamlPath(t, doc, "paths", "/items", "get", "security"))
+		assert.Len(t, yamlPath(t, doc, "paths", "/items", "get", "parameters"), 2, "only Accept and page are documented")
+		assert.Nil(t, yamlPath(t, doc, "paths", "/items", "delete", "security"))
+		assert.Len(t, yamlPath(t, doc, "paths", "/items", "delete", "parameters"), 1, "header is documented with no value")
+	}
+
+	doc, err = NewOpenAPIGeneratorYAML(OpenAPI{}).Generate(tests)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(doc), "secret")
+		assert.Equal(t, map[interface{}]interface{}{"type": "apiKey", "name": "api_key", "in": "query"},
+			yamlPath(t, doc, "components", "securitySchemes", "apiKey"))
+		assert.Equal(t, "http://testapi.my/token",
+			yamlPath(t, doc, "components", "securitySchemes", "oauth2", "flows", "clientCredentials", "tokenUrl"))
+		assert.Equal(t, []interface{}{oauth2, apiKey}, yamlPath(t, doc, "paths", "/items", "get", "security"))
+		assert.Len(t, yamlPath(t, doc, "paths", "/items", "get", "parameters"), 1, "only page is documented")
+	}
+
+	doc, err = NewRamlGenerator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(doc), "secret")
+		assert.Equal(t, "#%RAML 0.8", string(doc[0:10]))
+		schemes := yamlPath(t, doc, "securitySchemes").([]interface{})
+		if assert.Len(t, schemes, 2) {
+			assert.Equal(t, "OAuth 2.0", yamlPath(t, schemes[0], "oauth2", "type"))
+			assert.Equal(t, []interface{}{"credentials"}, yamlPath(t, schemes[0], "oauth2", "settings", "authorizationGrants"))
+			assert.Equal(t, "x-apiKey", yamlPath(t, schemes[1], "apiKey", "type"))
+		}
+		assert.Equal(t, []interface{}{"oauth2", "apiKey"}, yamlPath(t, doc, "/items", "get", "securedBy"))
+		assert.Equal(t, []interface{}{"page"}, mapKeys(yamlPath(t, doc, "/items", "get", "queryParameters")))
+		assert.Nil(t, yamlPath(t, doc, "/items", "delete", "securedBy"))
+	}
+
+	doc, err = NewRaml10Generator(raml.APIDefinition{Title: "Example API"}).Generate(tests)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(doc), "secret")
+		assert.Equal(t, "OAuth 2.0", yamlPath(t, doc, "securitySchemes", "oauth2", "type"))
+		assert.Equal(t, []interface{}{"client_credentials"},
+			yamlPath(t, doc, "securitySchemes", "oauth2", "settings", "authorizationGrants"))
+		assert.Equal(t, "Pass Through", yamlPath(t, doc, "securitySchemes", "apiKey", "type"))
+		assert.NotNil(t, yamlPath(t, doc, "securitySchemes", "apiKey", "describedBy", "queryParameters", "api_key"))
+		assert.Equal(t, []interface{}{"oauth2", "apiKey"}, yamlPath(t, doc, "/items", "get", "securedBy"))
+		assert.Equal(t, []interface{}{"Accept"}, mapKeys(yamlPath(t, doc, "/items", "get", "headers")))
+	}
+}
+
+// yamlPath unmarshals YAML document if needed and returns a value by given path
+func yamlPath(t *testing.T, doc interface{}, path ...interface{}) interface{} {
+	if raw, ok := doc.([]byte); ok {
+		doc = map[interface{}]interface{}{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			t.Fatalf("could not unmarshal YAML: %s", err.Error())
+		}
+	}
+
+	for _, key := range path {
+		m, ok := doc.(map[interface{}]interface{})
+		if !ok {
+			t.Fatalf("could not find key '%v' in %v", key, doc)
+		}
+		doc = m[key]
+	}
+
+	return doc
+}
+
+func mapKeys(m interface{}) []interface{} {
+	keys := []interface{}{}
+	for key := range m.(map[interface{}]interface{}) {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func getTests() []Test {
+	return []Test{
+		&HelloTest{},
+		&GetUserTest{},
+		&CreateUserTest{},
+		&UpdateUserTest{},
+		&DeleteUserTest{},
+	}
 }
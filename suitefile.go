@@ -0,0 +1,325 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// FileTest is a test defined by a suite file rather than by Go code.
+// See LoadTests for the format of suite files
+type FileTest struct {
+	TestName        string
+	TestDescription string
+	TestMethod      string
+	TestPath        string
+	Cases           []TestCase
+}
+
+func (t *FileTest) Name() string          { return t.TestName }
+func (t *FileTest) Description() string   { return t.TestDescription }
+func (t *FileTest) Method() string        { return t.TestMethod }
+func (t *FileTest) Path() string          { return t.TestPath }
+func (t *FileTest) TestCases() []TestCase { return t.Cases }
+
+// suiteFile is a root of a suite file
+type suiteFile struct {
+	Tests []suiteFileTest `json:"tests"`
+}
+
+type suiteFileTest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	Cases       []suiteFileTestCase `json:"cases"`
+}
+
+type suiteFileTestCase struct {
+	Description string                    `json:"description"`
+	Headers     map[string]suiteFileParam `json:"headers"`
+	QueryParams map[string]suiteFileParam `json:"queryParams"`
+	PathParams  map[string]suiteFileParam `json:"pathParams"`
+	RequestBody interface{}               `json:"requestBody"`
+
+	ExpectedHttpCode int               `json:"expectedHttpCode"`
+	ExpectedHeaders  map[string]string `json:"expectedHeaders"`
+	ExpectedData     interface{}       `json:"expectedData"`
+
+	Captures []suiteFileCapture `json:"captures"`
+
+	Retry      *suiteFileRetry      `json:"retry"`
+	Eventually *suiteFileEventually `json:"eventually"`
+
+	// Rows expand the case into a case per row, see ExpandTestCases
+	Rows Table `json:"rows"`
+}
+
+// suiteFileRetry is a RetryPolicy with durations like "500ms"
+type suiteFileRetry struct {
+	MaxAttempts     int    `json:"maxAttempts"`
+	Backoff         string `json:"backoff"`
+	MaxBackoff      string `json:"maxBackoff"`
+	StatusCodes     []int  `json:"statusCodes"`
+	TransportErrors bool   `json:"transportErrors"`
+}
+
+// suiteFileEventually is Eventually with durations like "30s"
+type suiteFileEventually struct {
+	Timeout  string `json:"timeout"`
+	Interval string `json:"interval"`
+}
+
+type suiteFileCapture struct {
+	Variable    string `json:"variable"`
+	JSONPointer string `json:"jsonPointer"`
+	JSONPath    string `json:"jsonPath"`
+	Header      string `json:"header"`
+}
+
+// suiteFileParam is a parameter of a suite file. It's either a plain value
+// or an object with value, required, description and secret fields
+type suiteFileParam Param
+
+func (p *suiteFileParam) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := decodeSuiteJSON(data, &value); err != nil {
+		return err
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		p.Value = normalizeNumbers(value)
+		return nil
+	}
+
+	var param struct {
+		Value       interface{} `json:"value"`
+		Required    bool        `json:"required"`
+		Description string      `json:"description"`
+		Secret      bool        `json:"secret"`
+	}
+	if err := decodeSuiteJSON(data, &param); err != nil {
+		return err
+	}
+	if _, ok := object["value"]; !ok {
+		return fmt.Errorf("parameter must be either a plain value or an object with 'value' field")
+	}
+
+	p.Value = normalizeNumbers(param.Value)
+	p.Required = param.Required
+	p.Description = param.Description
+	p.Secret = param.Secret
+
+	return nil
+}
+
+// LoadTests loads tests from a YAML or JSON suite file. Suite file lists
+// tests with their test cases, fields of a test case mirror fields of TestCase:
+//
+//	tests:
+//	- name: GetUser
+//	  description: Get user by ID
+//	  method: GET
+//	  path: /users/{id}
+//	  cases:
+//	  - description: existing user
+//	    headers:
+//	      Accept: application/json
+//	    pathParams:
+//	      id: {value: 1, required: true, description: ID of the user}
+//	    expectedHttpCode: 200
+//	    expectedHeaders:
+//	      Content-Type: application/json
+//	    expectedData: {id: 1, name: First User}
+//
+// Parameters are either plain values or objects with value, required,
+// description and secret fields. Test cases may also have queryParams,
+// requestBody and captures with variable, jsonPointer, jsonPath and header fields.
+// Retried test cases have retry with maxAttempts, backoff, maxBackoff, statusCodes
+// and transportErrors fields or eventually with timeout and interval fields,
+// durations are written like "500ms" or "30s". A case with rows is a template
+// that is expanded into a case per row, see ExpandTestCases
+func LoadTests(path string) ([]Test, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tests, err := ParseTests(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not load tests from '%s': %s", path, err.Error())
+	}
+
+	return tests, nil
+}
+
+// ParseTests parses tests from YAML or JSON contents of a suite file,
+// see LoadTests
+func ParseTests(data []byte) ([]Test, error) {
+	js, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var suite suiteFile
+	if err := decodeSuiteJSON(js, &suite); err != nil {
+		return nil, err
+	}
+
+	tests := []Test{}
+	for i, fileTest := range suite.Tests {
+		test, err := fileTest.test()
+		if err != nil {
+			return nil, fmt.Errorf("test %d: %s", i+1, err.Error())
+		}
+		tests = append(tests, test)
+	}
+
+	return tests, nil
+}
+
+func (ft suiteFileTest) test() (*FileTest, error) {
+	if ft.Method == "" || ft.Path == "" {
+		return nil, fmt.Errorf("method and path are required")
+	}
+
+	test := &FileTest{
+		TestName:        ft.Name,
+		TestDescription: ft.Description,
+		TestMethod:      ft.Method,
+		TestPath:        ft.Path,
+	}
+	if test.TestName == "" {
+		test.TestName = ft.Method + " " + ft.Path
+	}
+
+	for i, fc := range ft.Cases {
+		testCase := TestCase{
+			Description:      fc.Description,
+			Headers:          suiteFileParams(fc.Headers),
+			QueryParams:      suiteFileParams(fc.QueryParams),
+			PathParams:       suiteFileParams(fc.PathParams),
+			RequestBody:      normalizeNumbers(fc.RequestBody),
+			ExpectedHttpCode: fc.ExpectedHttpCode,
+			ExpectedHeaders:  fc.ExpectedHeaders,
+			ExpectedData:     normalizeNumbers(fc.ExpectedData),
+		}
+		for _, capture := range fc.Captures {
+			testCase.Captures = append(testCase.Captures, Capture(capture))
+		}
+		if err := fc.retries(&testCase); err != nil {
+			return nil, fmt.Errorf("case %d: %s", i+1, err.Error())
+		}
+
+		testCases := []TestCase{testCase}
+		if fc.Rows != nil {
+			for _, row := range fc.Rows {
+				normalizeNumbers(map[string]interface{}(row))
+			}
+
+			var err error
+			if testCases, err = ExpandTestCases(testCase, fc.Rows); err != nil {
+				return nil, fmt.Errorf("case %d: %s", i+1, err.Error())
+			}
+		}
+		for _, testCase := range testCases {
+			if testCase.ExpectedHttpCode == 0 {
+				return nil, fmt.Errorf("case %d: expectedHttpCode is required", i+1)
+			}
+		}
+
+		test.Cases = append(test.Cases, testCases...)
+	}
+
+	return test, nil
+}
+
+// retries sets retry policy and eventual mode of the test case
+func (fc suiteFileTestCase) retries(testCase *TestCase) error {
+	var err error
+	if fc.Retry != nil {
+		policy := &RetryPolicy{
+			MaxAttempts:     fc.Retry.MaxAttempts,
+			StatusCodes:     fc.Retry.StatusCodes,
+			TransportErrors: fc.Retry.TransportErrors,
+		}
+		if policy.Backoff, err = parseSuiteDuration(fc.Retry.Backoff); err != nil {
+			return fmt.Errorf("retry backoff: %s", err.Error())
+		}
+		if policy.MaxBackoff, err = parseSuiteDuration(fc.Retry.MaxBackoff); err != nil {
+			return fmt.Errorf("retry maxBackoff: %s", err.Error())
+		}
+		testCase.Retry = policy
+	}
+	if fc.Eventually != nil {
+		eventually := &Eventually{}
+		if eventually.Timeout, err = parseSuiteDuration(fc.Eventually.Timeout); err != nil {
+			return fmt.Errorf("eventually timeout: %s", err.Error())
+		}
+		if eventually.Interval, err = parseSuiteDuration(fc.Eventually.Interval); err != nil {
+			return fmt.Errorf("eventually interval: %s", err.Error())
+		}
+		testCase.Eventually = eventually
+	}
+
+	return nil
+}
+
+// parseSuiteDuration parses a duration like "500ms", empty one is zero
+func parseSuiteDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func suiteFileParams(params map[string]suiteFileParam) ParamMap {
+	if params == nil {
+		return nil
+	}
+
+	result := ParamMap{}
+	for name, param := range params {
+		result[name] = Param(param)
+	}
+
+	return result
+}
+
+// decodeSuiteJSON decodes JSON keeping numbers precise and rejecting
+// unknown fields, so typos in suite files do not go unnoticed
+func decodeSuiteJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+// normalizeNumbers replaces JSON numbers with int64 or float64 values,
+// so they are described as integers or numbers by generated documentation
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeNumbers(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,136 @@
+package schreder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const suiteYAML = `
+tests:
+- name: GetItem
+  description: Get an item
+  method: GET
+  path: /items/{id}
+  cases:
+  - description: existing item
+    headers:
+      Accept: application/json
+    pathParams:
+      id: {value: 1, required: true, description: ID of the item}
+    queryParams:
+      fields: name
+    expectedHttpCode: 200
+    expectedHeaders:
+      Content-Type: application/json
+    expectedData: {id: 1, name: first, price: 1.5}
+    captures:
+    - {variable: name, jsonPointer: /name}
+- method: POST
+  path: /items
+  cases:
+  - requestBody: {name: "${name}"}
+    expectedHttpCode: 201
+    retry: {maxAttempts: 3, backoff: 100ms, statusCodes: [503]}
+`
+
+func TestParseTests(t *testing.T) {
+	tests, err := ParseTests([]byte(suiteYAML))
+	if !assert.NoError(t, err) || !assert.Len(t, tests, 2) {
+		return
+	}
+
+	get := tests[0].(*FileTest)
+	assert.Equal(t, "GetItem", get.Name())
+	assert.Equal(t, "Get an item", get.Description())
+	assert.Equal(t, "GET", get.Method())
+	assert.Equal(t, "/items/{id}", get.Path())
+	assert.Equal(t, []TestCase{{
+		Description:      "existing item",
+		Headers:          ParamMap{"Accept": Param{Value: "application/json"}},
+		PathParams:       ParamMap{"id": Param{Value: int64(1), Required: true, Description: "ID of the item"}},
+		QueryParams:      ParamMap{"fields": Param{Value: "name"}},
+		ExpectedHttpCode: 200,
+		ExpectedHeaders:  map[string]string{"Content-Type": "application/json"},
+		ExpectedData:     map[string]interface{}{"id": int64(1), "name": "first", "price": 1.5},
+		Captures:         []Capture{{Variable: "name", JSONPointer: "/name"}},
+	}}, get.TestCases())
+
+	post := tests[1]
+	assert.Equal(t, "POST /items", extractTestName(post))
+	assert.Equal(t, map[string]interface{}{"name": "${name}"}, post.TestCases()[0].RequestBody)
+	assert.Equal(t, &RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, StatusCodes: []int{503}},
+		post.TestCases()[0].Retry)
+
+	_, err = ParseTests([]byte(`{"tests": [{"method": "GET", "path": "/items", "cases": [{"expectedHttpCode": 200}]}]}`))
+	assert.NoError(t, err, "JSON suites are supported")
+
+	tests, err = ParseTests([]byte(`
+tests:
+  - method: GET
+    path: /items/{id}
+    cases:
+      - description: item ${id}
+        pathParams: {id: "${id}"}
+        expectedHttpCode: 200
+        rows: [{id: 1}, {id: 2, expectedHttpCode: 404}]
+`))
+	if assert.NoError(t, err) && assert.Len(t, tests[0].TestCases(), 2) {
+		notFound := tests[0].TestCases()[1]
+		assert.Equal(t, "item 2", notFound.Description)
+		assert.Equal(t, int64(2), notFound.PathParams["id"].Value)
+		assert.Equal(t, 404, notFound.ExpectedHttpCode)
+	}
+}
+
+func TestParseTestsErrors(t *testing.T) {
+	for suite, expected := range map[string]string{
+		`tests: [{path: /items}]`:                                          "test 1: method and path are required",
+		`tests: [{method: GET, path: /items, cases: [{}]}]`:                "test 1: case 1: expectedHttpCode is required",
+		`tests: [{method: GET, path: /items, cases: [{expectedCode: 1}]}]`: `json: unknown field "expectedCode"`,
+		`tests: [{method: GET, path: /items, cases: [{headers: {Accept: {description: any}}}]}]`: "parameter must be " +
+			"either a plain value or an object with 'value' field",
+		`tests: [{method: GET, path: /items, cases: [{expectedHttpCode: 200, eventually: {timeout: soon}}]}]`: "test 1: " +
+			`case 1: eventually timeout: time: invalid duration "soon"`,
+		`tests: [{method: GET, path: /items, cases: [{rows: [{id: 1}]}]}]`:                 "test 1: case 1: expectedHttpCode is required",
+		`tests: [{method: GET, path: /items, cases: [{expectedHttpCode: 200, rows: []}]}]`: "test 1: case 1: table has no rows",
+	} {
+		_, err := ParseTests([]byte(suite))
+		assert.EqualError(t, err, expected, suite)
+	}
+
+	_, err := LoadTests("fixtures/suite/missing.yml")
+	assert.Error(t, err)
+}
+
+func TestRunFileTests(t *testing.T) {
+	tests, err := ParseTests([]byte(suiteYAML))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	requests := []string{}
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.String())
+		if req.Method == "POST" {
+			body, _ := ioutil.ReadAll(req.Body)
+			assert.JSONEq(t, `{"name": "first"}`, string(body))
+			return &http.Response{StatusCode: 201, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"id": 1, "name": "first", "price": 1.5}`)),
+		}, nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client})
+	runner.Run(t, tests...)
+
+	assert.Equal(t, []string{"GET http://testapi.my/items/1?fields=name", "POST http://testapi.my/items"}, requests)
+}
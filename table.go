@@ -0,0 +1,237 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/testmeifyoucan/schreder/match"
+)
+
+// Row is a row of a table of test data, values by names of columns
+type Row map[string]interface{}
+
+// Table is test data a template test case is expanded against, see ExpandTestCases
+type Table []Row
+
+// LoadTable loads a table from a CSV, JSON or YAML file, the format is
+// chosen by extension of the file. See ParseCSVTable and ParseTable
+func LoadTable(path string) (Table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table Table
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		table, err = ParseCSVTable(data)
+	} else {
+		table, err = ParseTable(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load table from '%s': %s", path, err.Error())
+	}
+
+	return table, nil
+}
+
+// ParseCSVTable parses a table from CSV with names of columns in its first
+// line. Values that look like integers, floats or booleans are converted to
+// them, other ones are strings
+func ParseCSVTable(data []byte) (Table, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read columns: %s", err.Error())
+	}
+
+	table := Table{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := Row{}
+		for i, column := range columns {
+			row[column] = csvValue(record[i])
+		}
+		table = append(table, row)
+	}
+
+	return table, nil
+}
+
+// csvValue converts a value of CSV to a number or a boolean if it's written
+// exactly like one
+func csvValue(value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(i, 10) == value {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == value {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil && strconv.FormatBool(b) == value {
+		return b
+	}
+
+	return value
+}
+
+// ParseTable parses a table from YAML or JSON list of objects
+func ParseTable(data []byte) (Table, error) {
+	js, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var table Table
+	if err := decodeSuiteJSON(js, &table); err != nil {
+		return nil, err
+	}
+	for _, row := range table {
+		normalizeNumbers(map[string]interface{}(row))
+	}
+
+	return table, nil
+}
+
+// TableOf makes a table of a slice of structs or maps, e.g. a table of test
+// data defined in Go. Columns of structs are named by their JSON names
+func TableOf(rows interface{}) (Table, error) {
+	js, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := ParseTable(js)
+	if err != nil {
+		return nil, fmt.Errorf("rows must be a slice of structs or maps: %s", err.Error())
+	}
+
+	return table, nil
+}
+
+// ExpandTestCases expands the template test case against every row of the
+// table: references to columns like "${email}" in PathParams, QueryParams,
+// Headers, RequestBody, ExpectedHeaders and ExpectedData are replaced with
+// values of the row. If the whole string is a reference, the value keeps its
+// type. References to other names are left for variables of the run.
+//
+// Every row produces a test case of its own, so it's reported and documented
+// separately. It's named by "description" column or by the description of the
+// template with references replaced, the number of the row is added to it if
+// the name is not unique. "expectedHttpCode" column overrides the expected status.
+// A table with no rows is an error, the template would silently vanish otherwise
+func ExpandTestCases(template TestCase, table Table) ([]TestCase, error) {
+	if len(table) == 0 {
+		return nil, fmt.Errorf("table has no rows")
+	}
+
+	testCases := []TestCase{}
+	names := map[string]int{}
+	for i, row := range table {
+		testCase, err := row.expand(template)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", i+1, err.Error())
+		}
+		names[testCase.Description]++
+		testCases = append(testCases, testCase)
+	}
+
+	for i := range testCases {
+		if description := testCases[i].Description; description == "" || names[description] > 1 {
+			testCases[i].Description = strings.TrimSpace(fmt.Sprintf("%s (row %d)", description, i+1))
+		}
+	}
+
+	return testCases, nil
+}
+
+// expand returns a copy of the template test case with references to columns
+// of the row replaced
+func (row Row) expand(template TestCase) (TestCase, error) {
+	testCase := template
+	testCase.Description = strings.TrimSpace(fmt.Sprintf("%v", row.substitute(template.Description)))
+	if description, ok := row["description"]; ok {
+		testCase.Description = fmt.Sprintf("%v", description)
+	}
+
+	testCase.PathParams = row.substituteParams(template.PathParams)
+	testCase.QueryParams = row.substituteParams(template.QueryParams)
+	testCase.Headers = row.substituteParams(template.Headers)
+	testCase.RequestBody = row.substituteBody(template.RequestBody)
+	testCase.ExpectedData = row.substituteBody(template.ExpectedData)
+
+	if template.ExpectedHeaders != nil {
+		testCase.ExpectedHeaders = map[string]string{}
+		for name, value := range template.ExpectedHeaders {
+			testCase.ExpectedHeaders[name] = fmt.Sprintf("%v", row.substitute(value))
+		}
+	}
+
+	if status, ok := row["expectedHttpCode"]; ok {
+		code, err := strconv.Atoi(fmt.Sprintf("%v", status))
+		if err != nil {
+			return testCase, fmt.Errorf("expectedHttpCode must be an integer, got '%v'", status)
+		}
+		testCase.ExpectedHttpCode = code
+	}
+
+	return testCase, nil
+}
+
+func (row Row) substituteParams(params ParamMap) ParamMap {
+	if params == nil {
+		return nil
+	}
+
+	substituted := ParamMap{}
+	for name, param := range params {
+		if value, ok := param.Value.(string); ok {
+			param.Value = row.substitute(value)
+		}
+		substituted[name] = param
+	}
+
+	return substituted
+}
+
+// substituteBody replaces references to columns in strings of the body the way
+// variables of the run are resolved, so typed bodies keep their types and
+// their definitions in docs. Bodies with matchers are never changed
+func (row Row) substituteBody(body interface{}) interface{} {
+	switch body.(type) {
+	case nil, []byte, io.Reader:
+		return body
+	}
+	if match.HasMatchers(body) {
+		return body
+	}
+
+	// partial substitution never fails, unknown references are left as is
+	substituted, _, _ := substitution{lookup: row.lookup, partial: true}.value(body)
+	return substituted
+}
+
+// substitute replaces references to columns in given string, see substitution
+func (row Row) substitute(s string) interface{} {
+	substituted, _, _ := substitution{lookup: row.lookup, partial: true}.str(s)
+	return substituted
+}
+
+func (row Row) lookup(name string) (interface{}, bool) {
+	value, ok := row[name]
+	return value, ok
+}
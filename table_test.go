@@ -0,0 +1,211 @@
+package schreder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSVTable(t *testing.T) {
+	table, err := ParseCSVTable([]byte("email,age,admin,score,code\n" +
+		"first@example.com,30,true,1.5,007\n" +
+		"\"second, the one\",-1,no,2,400\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, Table{
+		{"email": "first@example.com", "age": int64(30), "admin": true, "score": 1.5, "code": "007"},
+		{"email": "second, the one", "age": int64(-1), "admin": "no", "score": int64(2), "code": int64(400)},
+	}, table)
+
+	_, err = ParseCSVTable([]byte("email,age\nfirst@example.com\n"))
+	assert.Error(t, err, "rows must have all columns")
+}
+
+func TestLoadTable(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(dir+"/rows.csv", []byte("email,age\nfirst@example.com,30\n"), 0644)
+	ioutil.WriteFile(dir+"/rows.yml", []byte("- {email: first@example.com, age: 30}\n"), 0644)
+	expected := Table{{"email": "first@example.com", "age": int64(30)}}
+
+	for _, name := range []string{"rows.csv", "rows.yml"} {
+		table, err := LoadTable(dir + "/" + name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, expected, table, name)
+		}
+	}
+
+	_, err := LoadTable(dir + "/missing.json")
+	assert.Error(t, err)
+}
+
+func TestTableOf(t *testing.T) {
+	type user struct {
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}
+
+	table, err := TableOf([]user{{Email: "first@example.com", Age: 30}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, Table{{"email": "first@example.com", "age": int64(30)}}, table)
+	}
+
+	_, err = TableOf([]string{"first@example.com"})
+	assert.Error(t, err)
+}
+
+func newValidationTemplate() TestCase {
+	return TestCase{
+		Description:      "email ${email}",
+		Headers:          ParamMap{"Authorization": Param{Value: "Bearer ${token}"}},
+		QueryParams:      ParamMap{"notify": Param{Value: "${notify}"}},
+		PathParams:       ParamMap{"tenant": Param{Value: "${tenant}", Required: true}},
+		RequestBody:      map[string]interface{}{"email": "${email}", "age": "${age}"},
+		ExpectedHttpCode: 201,
+		ExpectedHeaders:  map[string]string{"X-Tenant": "${tenant}"},
+		ExpectedData:     map[string]interface{}{"error": "${error}"},
+	}
+}
+
+func TestExpandTestCases(t *testing.T) {
+	table := Table{
+		{"tenant": "acme", "email": "", "age": int64(30), "notify": true, "error": "email is required", "expectedHttpCode": int64(422)},
+		{"tenant": "acme", "email": "first@example.com", "age": int64(-1), "notify": false, "error": "age is invalid", "expectedHttpCode": "422"},
+		{"tenant": "acme", "email": "first@example.com", "age": int64(200), "notify": false, "error": "age is invalid", "expectedHttpCode": int64(422)},
+		{"description": "valid user", "tenant": "acme", "email": "valid@example.com", "age": int64(30), "notify": false, "error": ""},
+	}
+
+	testCases, err := ExpandTestCases(newValidationTemplate(), table)
+	if !assert.NoError(t, err) || !assert.Len(t, testCases, 4) {
+		return
+	}
+
+	assert.Equal(t, TestCase{
+		Description:      "email",
+		Headers:          ParamMap{"Authorization": Param{Value: "Bearer ${token}"}},
+		QueryParams:      ParamMap{"notify": Param{Value: true}},
+		PathParams:       ParamMap{"tenant": Param{Value: "acme", Required: true}},
+		RequestBody:      map[string]interface{}{"email": "", "age": int64(30)},
+		ExpectedHttpCode: 422,
+		ExpectedHeaders:  map[string]string{"X-Tenant": "acme"},
+		ExpectedData:     map[string]interface{}{"error": "email is required"},
+	}, testCases[0], "references to other names are left for variables of the run")
+
+	assert.Equal(t, "email first@example.com (row 2)", testCases[1].Description)
+	assert.Equal(t, "email first@example.com (row 3)", testCases[2].Description)
+	assert.Equal(t, 422, testCases[1].ExpectedHttpCode)
+	assert.Equal(t, "valid user", testCases[3].Description)
+	assert.Equal(t, 201, testCases[3].ExpectedHttpCode)
+
+	template := newValidationTemplate()
+	assert.Equal(t, "email ${email}", template.Description, "template is not changed")
+	assert.Equal(t, "${email}", template.RequestBody.(map[string]interface{})["email"])
+
+	_, err = ExpandTestCases(template, Table{{"expectedHttpCode": "created"}})
+	assert.EqualError(t, err, "row 1: expectedHttpCode must be an integer, got 'created'")
+
+	testCases, err = ExpandTestCases(TestCase{ExpectedHttpCode: 200}, Table{{}, {}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "(row 1)", testCases[0].Description)
+	}
+
+	_, err = ExpandTestCases(template, Table{})
+	assert.EqualError(t, err, "table has no rows")
+}
+
+type tableUserBody struct {
+	Email string `json:"email"`
+	Age   int64  `json:"age"`
+	Note  string `json:"note"`
+}
+
+func TestExpandTestCasesTypedBody(t *testing.T) {
+	template := TestCase{
+		RequestBody:      tableUserBody{Email: "${email}", Age: 30, Note: "${note}"},
+		ExpectedHttpCode: 201,
+		ExpectedData:     &tableUserBody{Email: "${email}", Age: 30},
+	}
+
+	testCases, err := ExpandTestCases(template, Table{{"email": "first@example.com", "note": int64(7)}})
+	if !assert.NoError(t, err) || !assert.Len(t, testCases, 1) {
+		return
+	}
+	assert.Equal(t, tableUserBody{Email: "first@example.com", Age: 30, Note: "7"}, testCases[0].RequestBody,
+		"typed bodies keep their types")
+	assert.Equal(t, &tableUserBody{Email: "first@example.com", Age: 30}, testCases[0].ExpectedData)
+	assert.Equal(t, "${email}", template.ExpectedData.(*tableUserBody).Email, "template is not changed")
+
+	doc, err := NewSwaggerGeneratorJSON(spec.Swagger{}).Generate([]Test{&tableUserTest{cases: testCases}})
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(doc), `"#/definitions/tableUserBody"`, "docs keep named definitions of typed bodies")
+	}
+}
+
+type tableUserTest struct {
+	cases []TestCase
+}
+
+func (t *tableUserTest) Method() string        { return "POST" }
+func (t *tableUserTest) Description() string   { return "Create a user" }
+func (t *tableUserTest) Path() string          { return "/tenants/{tenant}/users" }
+func (t *tableUserTest) TestCases() []TestCase { return t.cases }
+
+func TestRunExpandedTestCases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&user)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Tenant", strings.Split(r.URL.Path, "/")[2])
+		switch {
+		case user["email"] == "":
+			w.WriteHeader(422)
+			w.Write([]byte(`{"error":"email is required"}`))
+		case user["age"].(float64) < 0:
+			w.WriteHeader(422)
+			w.Write([]byte(`{"error":"age is invalid"}`))
+		default:
+			w.WriteHeader(201)
+			w.Write([]byte(`{"error":""}`))
+		}
+	}))
+	defer server.Close()
+
+	table, err := ParseCSVTable([]byte("description,tenant,email,age,notify,error,expectedHttpCode\n" +
+		"no email,acme,,30,true,email is required,422\n" +
+		"negative age,acme,first@example.com,-1,false,age is invalid,422\n" +
+		"valid user,acme,first@example.com,30,false,,201\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	testCases, err := ExpandTestCases(newValidationTemplate(), table)
+	if !assert.NoError(t, err) {
+		return
+	}
+	test := &tableUserTest{cases: testCases}
+
+	report := NewReport()
+	runner := NewRunner(server.URL, RunnerConfig{Report: report, Vars: map[string]interface{}{"token": "secret"}})
+	runner.Run(t, test)
+
+	if assert.Len(t, report.Tests, 1) && assert.Len(t, report.Tests[0].Cases, 3) {
+		for i, name := range []string{"no email", "negative age", "valid user"} {
+			assert.Equal(t, name, report.Tests[0].Cases[i].Name)
+			assert.True(t, report.Tests[0].Cases[i].Passed)
+		}
+	}
+
+	doc, err := NewOpenAPIGeneratorJSON(OpenAPI{Info: &spec.Info{}}).Generate([]Test{test})
+	if assert.NoError(t, err) {
+		for _, name := range []string{"no email", "negative age", "valid user"} {
+			assert.Contains(t, string(doc), name, "every row is documented")
+		}
+	}
+}
@@ -2,15 +2,22 @@ package schreder
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"runtime/debug"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/elgris/jsondiff"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/testmeifyoucan/schreder/match"
 )
 
 // ITestRunner is responsible for
@@ -42,6 +49,21 @@ type httpRunner struct {
 	DefaultHeaders map[string]string
 	BaseUrl        string
 	HttpClient     IHttpClient
+	Parallel       bool
+	MaxParallel    int
+	ValidateSchema bool
+	Contract       *Contract
+	Encoders       map[string]IRequestEncoder
+	Decoders       map[string]IResponseDecoder
+	OnResult       func(result TestCaseResult)
+	Authenticator  IAuthenticator
+	Redaction      Redaction
+	Report         *Report
+	Hooks          Hooks
+	RequestTimeout time.Duration
+	TestTimeout    time.Duration
+	Retry          RetryPolicy
+	Vars           map[string]interface{}
 }
 
 // RunnerConfig contains list of possible options that can be used to initialize
@@ -49,6 +71,83 @@ type httpRunner struct {
 type RunnerConfig struct {
 	DefaultHeaders map[string]string
 	HttpClient     IHttpClient
+
+	// Parallel enables parallel execution of tests. Each test runs as a parallel
+	// subtest, test cases of a test still run one by one between its SetUp and TearDown.
+	Parallel bool
+	// MaxParallel limits number of tests running simultaneously in parallel mode.
+	// Zero means the limit is defined by 'go test -parallel' only.
+	MaxParallel int
+
+	// ValidateSchema enables validation of response bodies against the schema
+	// of ExpectedData, the one that is put into generated documentation.
+	ValidateSchema bool
+
+	// Contract enables contract mode: tests are checked to use only paths, methods,
+	// parameters and status codes declared by the contract, request and response
+	// bodies are validated against its schemas. Test cases with no ExpectedData
+	// and AssertResponse rely on the contract to check response bodies.
+	Contract *Contract
+
+	// Encoders encode request bodies by media type of the request. They override
+	// default encoders of JSON, XML, text, URL encoded and multipart forms.
+	// Bodies of type []byte and io.Reader are always sent as is.
+	Encoders map[string]IRequestEncoder
+
+	// Decoders decode response bodies by media type of the response before
+	// they are compared to expected data. They override default decoders of
+	// JSON, XML, YAML, text and URL encoded forms.
+	Decoders map[string]IResponseDecoder
+
+	// OnResult is called with the result of every test case once it's finished,
+	// e.g. to print a summary of the run. In parallel mode it's called concurrently.
+	OnResult func(result TestCaseResult)
+
+	// Authenticator authenticates every request right before it's sent, e.g. adds
+	// OAuth2 token or signs the request. See NewBasicAuth, NewBearerAuth,
+	// NewOAuth2ClientCredentials, NewHMACAuth and NewAWSSigV4Auth
+	Authenticator IAuthenticator
+
+	// Redaction defines secrets that are masked in logs and failure output of
+	// test cases, along with headers of DefaultRedaction and parameters with
+	// Secret flag. Custom AssertResponse functions are not covered
+	Redaction Redaction
+
+	// Report collects results of tests, their requests and responses, so they
+	// can be written as JUnit XML, JSON or HTML once tests are finished
+	Report *Report
+
+	// Hooks are called before and after the run, test cases, requests and
+	// responses, see Hooks
+	Hooks Hooks
+
+	// RequestTimeout limits time of every request of test cases, including
+	// reading of the response body. Zero means no limit
+	RequestTimeout time.Duration
+	// TestTimeout limits time of every test: its SetUp and all test cases.
+	// TearDown is not limited, so the test can clean up. Zero means no limit
+	TestTimeout time.Duration
+
+	// Retry is a retry policy of requests of all test cases, a test case may
+	// override it. See RetryPolicy
+	Retry RetryPolicy
+
+	// Vars are variables every run starts with, test cases refer them as
+	// "${name}", e.g. IDs of fixtures of an environment. See Profile
+	Vars map[string]interface{}
+}
+
+// TestCaseResult describes the outcome of a test case run by the runner
+type TestCaseResult struct {
+	// Test is a name of the test the case belongs to
+	Test   string
+	Method string
+	Path   string
+	// TestCase is a name of the test case. It's empty for failures of the test
+	// itself, like broken contract or failed SetUp and TearDown
+	TestCase string
+	Passed   bool
+	Duration time.Duration
 }
 
 // NewRunner creates new instance of HTTP runner
@@ -57,6 +156,8 @@ func NewRunner(baseUrl string, config RunnerConfig) *httpRunner {
 		DefaultHeaders: make(map[string]string),
 		BaseUrl:        baseUrl,
 		HttpClient:     &http.Client{},
+		Encoders:       map[string]IRequestEncoder{},
+		Decoders:       map[string]IResponseDecoder{},
 	}
 
 	if config.DefaultHeaders != nil {
@@ -65,107 +166,594 @@ func NewRunner(baseUrl string, config RunnerConfig) *httpRunner {
 	if config.HttpClient != nil {
 		r.HttpClient = config.HttpClient
 	}
+	r.Parallel = config.Parallel
+	r.MaxParallel = config.MaxParallel
+	r.ValidateSchema = config.ValidateSchema
+	r.Contract = config.Contract
+	r.OnResult = config.OnResult
+	r.Authenticator = config.Authenticator
+	r.Redaction = config.Redaction
+	r.Report = config.Report
+	r.Hooks = config.Hooks
+	r.RequestTimeout = config.RequestTimeout
+	r.TestTimeout = config.TestTimeout
+	r.Retry = config.Retry
+	r.Vars = config.Vars
+
+	for mediaType, encoder := range defaultRequestEncoders {
+		r.Encoders[mediaType] = encoder
+	}
+	for mediaType, encoder := range config.Encoders {
+		r.Encoders[strings.ToLower(mediaType)] = encoder
+	}
+	for mediaType, decoder := range defaultResponseDecoders {
+		r.Decoders[mediaType] = decoder
+	}
+	for mediaType, decoder := range config.Decoders {
+		r.Decoders[strings.ToLower(mediaType)] = decoder
+	}
 
 	return r
 }
 
 func (r *httpRunner) Run(t *testing.T, tests ...Test) {
+	r.RunWithContext(context.Background(), t, tests...)
+}
+
+// RunWithContext runs tests until given context is canceled. Requests
+// of test cases and SetUp of tests are canceled along with it.
+//
+// The run is also canceled shortly before the deadline of 'go test -timeout',
+// so hung requests fail with a clear message and tests have time to clean up
+func (r *httpRunner) RunWithContext(ctx context.Context, t *testing.T, tests ...Test) {
+	ctx = withRunnerHttpClient(ctx, r.HttpClient)
+	cleanupCtx := ctx
+	if deadline, ok := t.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-cleanupTime(deadline)))
+		defer cancel()
+	}
+
+	// hooks and tests share the context of the run, variables captured
+	// by test cases live until the end of the run
+	run := newRunContext(ctx, cleanupCtx, r)
+	r.Report.start()
+	defer r.Report.finish()
+
+	if r.Hooks.BeforeRun != nil {
+		if err := protect(func() error { return r.Hooks.BeforeRun(run) }); err != nil {
+			t.Errorf("error before the run: %s", err.Error())
+			return
+		}
+	}
+	if r.Hooks.AfterRun != nil {
+		defer func() {
+			if err := protect(func() error { return r.Hooks.AfterRun(run) }); err != nil {
+				t.Errorf("error after the run: %s", err.Error())
+			}
+		}()
+	}
+
+	if r.Parallel {
+		r.runParallel(t, tests, run)
+		return
+	}
+
 	for _, test := range tests {
-		testName := extractTestName(test)
-		// setup test
-		if setuppable, ok := test.(Setuppable); ok {
-			t.Logf("setting up test '%s'(%s)...", testName, test.Description())
+		test := test
+		t.Run(extractTestName(test), func(t *testing.T) {
+			r.runTestCases(t, test, run)
+		})
+	}
+}
 
-			if err := setuppable.SetUp(); err != nil {
-				t.Errorf("error setting up test '%s'(%s): %s",
-					testName, test.Description(), err.Error())
+// parallelUnit is a set of tests that run one by one in a single parallel subtest
+type parallelUnit struct {
+	name  string
+	group bool
+	tests []Test
+}
+
+// runParallel runs tests as parallel subtests. Tests of the same serial group
+// share a subtest and run one by one in given order. Tests that opted out of
+// parallel execution run after all parallel tests are finished.
+func (r *httpRunner) runParallel(t *testing.T, tests []Test, run *RunContext) {
+	units := []*parallelUnit{}
+	groups := map[string]*parallelUnit{}
+	serial := []Test{}
+	for _, test := range tests {
+		if parallelizable, ok := test.(Parallelizable); ok && !parallelizable.Parallel() {
+			serial = append(serial, test)
+			continue
+		}
 
-				continue
+		if groupable, ok := test.(SerialGroupable); ok && groupable.SerialGroup() != "" {
+			group := groupable.SerialGroup()
+			unit, ok := groups[group]
+			if !ok {
+				unit = &parallelUnit{name: group, group: true}
+				groups[group] = unit
+				units = append(units, unit)
 			}
+			unit.tests = append(unit.tests, test)
+			continue
 		}
 
-		// run test
-		for caseIndex, testCase := range test.TestCases() {
-			t.Logf("running test '%s'(%s), case %d", testName, testCase.Description, caseIndex+1)
-			r.runTest(t, testCase, test.Method(), test.Path())
+		units = append(units, &parallelUnit{name: extractTestName(test), tests: []Test{test}})
+	}
+
+	var limiter chan struct{}
+	if r.MaxParallel > 0 {
+		limiter = make(chan struct{}, r.MaxParallel)
+	}
+
+	// parallel subtests are resumed when their parent returns, so they are
+	// put into a group that blocks until all of them are finished
+	t.Run("parallel", func(t *testing.T) {
+		for _, unit := range units {
+			unit := unit
+			t.Run(unit.name, func(t *testing.T) {
+				t.Parallel()
+
+				if limiter != nil {
+					limiter <- struct{}{}
+					defer func() { <-limiter }()
+				}
+
+				if !unit.group {
+					r.runTestCases(t, unit.tests[0], run)
+					return
+				}
+
+				for _, test := range unit.tests {
+					test := test
+					t.Run(extractTestName(test), func(t *testing.T) {
+						r.runTestCases(t, test, run)
+					})
+				}
+			})
+		}
+	})
+
+	for _, test := range serial {
+		test := test
+		t.Run(extractTestName(test), func(t *testing.T) {
+			r.runTestCases(t, test, run)
+		})
+	}
+}
+
+// cleanupTime is the part of time left until the deadline of 'go test -timeout'
+// that is reserved for TearDown of tests and hooks after the run
+func cleanupTime(deadline time.Time) time.Duration {
+	reserved := time.Until(deadline) / 10
+	if reserved > 5*time.Second {
+		reserved = 5 * time.Second
+	}
+
+	return reserved
+}
+
+// runTestCases runs all test cases of given test between its SetUp and TearDown.
+// Each test case is run as a subtest named after its description. TearDown is
+// called even if test cases panic or call t.FailNow
+func (r *httpRunner) runTestCases(t *testing.T, test Test, run *RunContext) {
+	ctx := run.ctx
+	if r.TestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.TestTimeout)
+		defer cancel()
+	}
+
+	testName := extractTestName(test)
+	testReport := &TestReport{
+		Name:        testName,
+		Description: test.Description(),
+		Method:      test.Method(),
+		Path:        test.Path(),
+		Cases:       []*TestCaseReport{},
+	}
+	r.Report.addTest(testReport)
+	started := time.Now()
+	defer func() { testReport.Duration = time.Since(started) }()
+
+	if r.Contract != nil {
+		if violations := r.Contract.Check(test); len(violations) > 0 {
+			t.Errorf("test '%s'(%s) breaks the contract:\n%s",
+				testName, test.Description(), strings.Join(violations, "\n"))
+			testReport.Error = "test breaks the contract:\n" + strings.Join(violations, "\n")
+			r.report(test, "", false, 0)
+
+			return
+		}
+	}
+
+	// setup test
+	if setUp := testSetUp(ctx, test); setUp != nil {
+		t.Logf("setting up test '%s'(%s)...", testName, test.Description())
+
+		if err := protect(setUp); err != nil {
+			t.Errorf("error setting up test '%s'(%s): %s",
+				testName, test.Description(), err.Error())
+			testReport.SetUpError = err.Error()
+			r.report(test, "", false, 0)
+
+			return
 		}
+	}
 
-		// teardown test
-		if teardownable, ok := test.(Teardownable); ok {
+	// teardown test, it's not limited by the timeout of the test
+	if tearDown := testTearDown(run.cleanupCtx, test); tearDown != nil {
+		defer func() {
 			t.Logf("tearing down test '%s'(%s)...", testName, test.Description())
 
-			if err := teardownable.TearDown(); err != nil {
+			if err := protect(tearDown); err != nil {
 				t.Errorf("error cleaning up after a test '%s'(%s): %s",
 					testName, test.Description(), err.Error())
+				testReport.TearDownError = err.Error()
+				r.report(test, "", false, 0)
 			}
+		}()
+	}
+
+	// run test
+	for caseIndex, testCase := range test.TestCases() {
+		caseIndex, testCase := caseIndex, testCase
+		caseReport := &TestCaseReport{
+			Name:           testCaseName(testCase, caseIndex),
+			Method:         test.Method(),
+			ExpectedStatus: testCase.ExpectedHttpCode,
 		}
+		testReport.Cases = append(testReport.Cases, caseReport)
+
+		started := time.Now()
+		passed := t.Run(testCaseName(testCase, caseIndex), func(t *testing.T) {
+			t.Logf("running test '%s'(%s), case %d", testName, testCase.Description, caseIndex+1)
+			r.runCase(ctx, newCaseT(t, r.Redaction, caseReport), test, testCase, run)
+		})
+		caseReport.Passed = passed
+		caseReport.Duration = time.Since(started)
+		r.report(test, testCaseName(testCase, caseIndex), passed, caseReport.Duration)
+	}
+}
+
+// testSetUp returns SetUp of the test, the context aware one if the test has it
+func testSetUp(ctx context.Context, test Test) func() error {
+	if setuppable, ok := test.(ContextSetuppable); ok {
+		return func() error { return setuppable.SetUpContext(ctx) }
+	}
+	if setuppable, ok := test.(Setuppable); ok {
+		return setuppable.SetUp
+	}
+
+	return nil
+}
+
+// testTearDown returns TearDown of the test, the context aware one if the test has it
+func testTearDown(ctx context.Context, test Test) func() error {
+	if teardownable, ok := test.(ContextTeardownable); ok {
+		return func() error { return teardownable.TearDownContext(ctx) }
 	}
+	if teardownable, ok := test.(Teardownable); ok {
+		return teardownable.TearDown
+	}
+
+	return nil
+}
+
+// runCase runs a test case between hooks of the runner and the test.
+// Hooks after the case are called even if it panics or calls t.FailNow
+func (r *httpRunner) runCase(ctx context.Context, t *caseT, test Test, testCase TestCase, run *RunContext) {
+	if err := protect(func() error { return r.beforeCase(test, &testCase, run) }); err != nil {
+		t.Errorf("error before the test case: %s", err.Error())
+		return
+	}
+	defer func() {
+		passed := !t.Failed()
+		if err := protect(func() error { return r.afterCase(test, testCase, passed, run) }); err != nil {
+			t.Errorf("error after the test case: %s", err.Error())
+		}
+	}()
+	defer func() {
+		if p := recover(); p != nil {
+			t.Errorf("test case panicked: %v\n%s", p, debug.Stack())
+		}
+	}()
+
+	r.runTest(ctx, t, testCase, test.Method(), test.Path(), run)
+}
+
+func (r *httpRunner) beforeCase(test Test, testCase *TestCase, run *RunContext) error {
+	if r.Hooks.BeforeCase != nil {
+		if err := r.Hooks.BeforeCase(run, test, testCase); err != nil {
+			return err
+		}
+	}
+	if setuppable, ok := test.(CaseSetuppable); ok {
+		return setuppable.SetUpCase(run, testCase)
+	}
+
+	return nil
+}
+
+// afterCase calls hooks after the test case, both of them are called even
+// if one fails
+func (r *httpRunner) afterCase(test Test, testCase TestCase, passed bool, run *RunContext) error {
+	var err error
+	if teardownable, ok := test.(CaseTeardownable); ok {
+		err = protect(func() error { return teardownable.TearDownCase(run, testCase) })
+	}
+	if r.Hooks.AfterCase != nil {
+		if hookErr := r.Hooks.AfterCase(run, test, testCase, passed); err == nil {
+			err = hookErr
+		}
+	}
+
+	return err
+}
+
+// report passes the result of a test case to OnResult callback if it's defined
+func (r *httpRunner) report(test Test, testCase string, passed bool, duration time.Duration) {
+	if r.OnResult == nil {
+		return
+	}
+
+	r.OnResult(TestCaseResult{
+		Test:     extractTestName(test),
+		Method:   test.Method(),
+		Path:     test.Path(),
+		TestCase: testCase,
+		Passed:   passed,
+		Duration: duration,
+	})
 }
 
-func (r *httpRunner) encode(obj interface{}) ([]byte, error) {
-	// TODO: make it configurable
-	return json.Marshal(obj)
+// encode encodes request body with the encoder of given content type
+func (r *httpRunner) encode(body interface{}, contentType string) ([]byte, string, error) {
+	return encodeBody(r.Encoders, body, contentType)
 }
 
-func (r *httpRunner) runTest(t *testing.T, testCase TestCase, method, path string) {
-	urlstring := r.BaseUrl + path
-	url, err := testCase.Url(urlstring)
+// requestContentType returns content type of the request defined by the test case
+// or by default headers of the runner
+func (r *httpRunner) requestContentType(testCase TestCase) string {
+	for name, param := range testCase.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			return fmt.Sprintf("%v", param.Value)
+		}
+	}
+	for name, value := range r.DefaultHeaders {
+		if strings.EqualFold(name, "Content-Type") {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// caseT is a test of a test case. It masks secrets of the case in failure
+// output and logs, failures are recorded into the report of the case
+type caseT struct {
+	*testing.T
+	secrets *secrets
+	report  *TestCaseReport
+}
+
+func newCaseT(t *testing.T, redaction Redaction, report *TestCaseReport) *caseT {
+	return &caseT{T: t, secrets: newSecrets(redaction), report: report}
+}
+
+func (t *caseT) Errorf(format string, args ...interface{}) {
+	t.T.Helper()
+	message := t.secrets.mask(fmt.Sprintf(format, args...))
+	t.report.Failures = append(t.report.Failures, strings.TrimSpace(message))
+	t.T.Error(message)
+}
+
+func (t *caseT) Logf(format string, args ...interface{}) {
+	t.T.Helper()
+	t.T.Log(t.secrets.mask(fmt.Sprintf(format, args...)))
+}
+
+// caseTestingT is what assertions of a response report to: the test case
+// itself or an attempt of an eventual test case
+type caseTestingT interface {
+	Errorf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// runTest runs a test case within the context of its test. Secrets of the case are
+// collected as soon as they are known, so its failure output, logs and report never show them
+func (r *httpRunner) runTest(ctx context.Context, t *caseT, testCase TestCase, method, path string, run *RunContext) {
+	testCase, err := run.Vars.resolveTestCase(testCase)
+	if !assert.NoError(t, err, "could not resolve variables") {
+		return
+	}
+	t.secrets.addParams(testCase.Headers, true)
+	t.secrets.addParams(testCase.QueryParams, false)
+	t.secrets.addParams(testCase.PathParams, false)
+	t.secrets.addData(testCase.RequestBody)
+	t.secrets.addData(testCase.ExpectedData)
+
+	url, err := testCase.Url(r.BaseUrl + path)
 	if !assert.NoError(t, err, "could not prepare an url") {
 		return
 	}
+	t.report.URL = t.secrets.mask(url)
 
-	// TODO: prepare body
-	var req *http.Request
+	if r.Contract != nil && testCase.RequestBody != nil {
+		violations, err := r.Contract.validateRequestBody(method, path, testCase.RequestBody)
+		if !assert.NoError(t, err, "could not validate request body") ||
+			!assert.Empty(t, violations, "request body breaks the contract") {
+			return
+		}
+	}
+
+	request := &caseRequest{
+		testCase:    testCase,
+		method:      method,
+		path:        path,
+		url:         url,
+		contentType: r.requestContentType(testCase),
+		retry:       r.Retry,
+	}
 	if testCase.RequestBody != nil {
-		encoded, err := r.encode(testCase.RequestBody)
+		request.body, request.contentType, err = r.encode(testCase.RequestBody, request.contentType)
 		if !assert.NoError(t, err, "could not encode body") {
 			return
 		}
+	}
+	if testCase.Retry != nil {
+		request.retry = *testCase.Retry
+	}
+	if testCase.Eventually != nil {
+		request.deadline = time.Now().Add(testCase.Eventually.timeout())
+	}
+
+	for attempt := 1; !r.attempt(ctx, t, request, attempt, run); attempt++ {
+		delay := request.delay(attempt)
+		t.Logf("attempt %d failed, retrying in %s", attempt, delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			// the next attempt fails telling why the context is done
+		}
+	}
+}
+
+// attempt sends the request of a test case and asserts its response. It returns
+// false if the attempt failed and the request should be sent again
+func (r *httpRunner) attempt(ctx context.Context, t *caseT, c *caseRequest, attempt int, run *RunContext) bool {
+	testCtx := ctx
+	if r.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.RequestTimeout)
+		defer cancel()
+	}
 
-		requestBody := bytes.NewBuffer(encoded)
-		req, err = http.NewRequest(method, url, requestBody)
-	} else {
-		req, err = http.NewRequest(method, url, nil)
+	report := &AttemptReport{}
+	if c.retried() {
+		t.report.Attempts = append(t.report.Attempts, report)
 	}
+	started := time.Now()
+	defer func() { report.Duration = time.Since(started) }()
 
+	var body io.Reader
+	if c.body != nil {
+		body = bytes.NewReader(c.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.method, c.url, body)
 	if !assert.NoError(t, err, "could not create HTTP request") {
-		return
+		return true
 	}
 
 	for name, value := range r.DefaultHeaders {
 		req.Header.Set(name, value)
 	}
-	for name, param := range testCase.Headers {
+	for name, param := range c.testCase.Headers {
 		if stringValue, ok := param.Value.(string); ok {
 			req.Header.Set(name, stringValue)
 		} else {
 			req.Header.Set(name, fmt.Sprintf("%v", param.Value))
 		}
 	}
+	// encoder may extend content type, e.g. with a boundary of multipart body
+	if c.contentType != "" {
+		req.Header.Set("Content-Type", c.contentType)
+	}
+	if r.Hooks.BeforeRequest != nil {
+		err := protect(func() error { return r.Hooks.BeforeRequest(run, req) })
+		if !assert.NoError(t, err, "error before the request") {
+			return true
+		}
+	}
+	if r.Authenticator != nil {
+		if err := r.Authenticator.Authenticate(req); !assert.NoError(t, err, "could not authenticate request") {
+			return true
+		}
+	}
+	t.secrets.addHeaders(req.Header)
+	t.report.Request = newMessageReport(req.Header, c.body, t.secrets)
+	report.Request = t.report.Request
 
 	resp, err := r.HttpClient.Do(req)
-	if !assert.NoError(t, err, "failed sending a request") {
-		return
+	if err != nil {
+		err = r.contextError(err, req, testCtx, run)
+		retryable := c.retry.TransportErrors || !c.deadline.IsZero()
+		if retryable && testCtx.Err() == nil && c.canRetry(attempt) {
+			report.Error = t.secrets.mask(err.Error())
+			return false
+		}
+		assert.NoError(t, err, "failed sending a request")
+		return true
 	}
-
-	if !assert.NotNil(t, resp, "request to '%s' returned nil response", urlstring) {
-		return
+	if !assert.NotNil(t, resp, "request to '%s' returned nil response", t.report.URL) {
+		return true
+	}
+	if r.Hooks.AfterResponse != nil {
+		err := protect(func() error { return r.Hooks.AfterResponse(run, req, resp) })
+		if !assert.NoError(t, err, "error after the response") {
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			return true
+		}
 	}
 
 	var responseBody []byte
 	if resp.Body != nil {
-		defer resp.Body.Close()
-
 		responseBody, err = ioutil.ReadAll(resp.Body)
-		if !assert.NoError(t, err) {
-			return
+		resp.Body.Close()
+		if err != nil {
+			err = r.contextError(err, req, testCtx, run)
+		}
+		if !assert.NoError(t, err, "failed reading the response") {
+			return true
 		}
 	}
+	t.secrets.addHeaders(resp.Header)
+	t.secrets.addJSON(responseBody)
+	t.report.Status = resp.StatusCode
+	t.report.Response = newMessageReport(resp.Header, responseBody, t.secrets)
+	report.Status = resp.StatusCode
+	report.Response = t.report.Response
+
+	if c.retry.retriesStatus(resp.StatusCode) && c.canRetry(attempt) {
+		report.Error = "status is retried"
+		return false
+	}
+
+	if c.deadline.IsZero() {
+		r.checkResponse(t, t.T, c, resp, responseBody, run)
+		return true
+	}
+
+	// failures of an eventual test case are reported once no attempts are left
+	attemptT := &attemptT{}
+	if r.checkResponse(attemptT, nil, c, resp, responseBody, run) {
+		if c.testCase.AssertResponse != nil {
+			c.testCase.AssertResponse(t.T, c.testCase.ExpectedData, responseBody)
+		}
+		return true
+	}
+	if testCtx.Err() == nil && c.canRetry(attempt) {
+		report.Error = t.secrets.mask(attemptT.failures())
+		return false
+	}
+	attemptT.replay(t)
+
+	return true
+}
+
+// checkResponse asserts the response of a test case and captures variables from
+// it if it passed. Custom AssertResponse is called with customT, it's skipped if customT is nil
+func (r *httpRunner) checkResponse(t caseTestingT, customT *testing.T, c *caseRequest, resp *http.Response,
+	responseBody []byte, run *RunContext) bool {
 
+	testCase := c.testCase
 	if !assert.Equal(t, testCase.ExpectedHttpCode, resp.StatusCode) {
 		t.Logf("body received: %s", string(responseBody))
 
-		return
+		return false
 	}
 
 	// asserting headers
@@ -174,53 +762,103 @@ func (r *httpRunner) runTest(t *testing.T, testCase TestCase, method, path strin
 			if !assert.Equal(t, value, resp.Header.Get(header)) {
 				t.Logf("body received: %s", string(responseBody))
 
-				return
+				return false
 			}
 		}
 	}
 
-	if testCase.AssertResponse != nil {
-		testCase.AssertResponse(t, testCase.ExpectedData, responseBody)
-	} else {
-		AssertResponse(t, testCase.ExpectedData, responseBody)
+	passed := true
+	if r.ValidateSchema && testCase.ExpectedData != nil {
+		violations, err := validateResponseSchema(testCase.ExpectedData, responseBody)
+		if !assert.NoError(t, err, "could not validate response") {
+			passed = false
+		} else if len(violations) > 0 {
+			passed = assert.Fail(t, strings.Join(violations, "\n"), "response does not conform to the schema of expected data")
+		}
 	}
-}
 
-// AssertResponse checks that given expected object contains the same data
-// as provided responseBody.
-func AssertResponse(t *testing.T, expected interface{}, responseBody []byte) bool {
-	if expected != nil {
-		expectedData := decodeExpected(expected)
-		actualData := decodeResponse(responseBody)
+	if r.Contract != nil {
+		violations, err := r.Contract.validateResponseBody(c.method, c.path, resp.StatusCode, responseBody)
+		if !assert.NoError(t, err, "could not validate response body") {
+			passed = false
+		} else if len(violations) > 0 {
+			passed = assert.Fail(t, strings.Join(violations, "\n"), "response body breaks the contract")
+		}
+	}
 
-		diff := jsondiff.Compare(expectedData, actualData)
-		if !diff.IsEqual() {
-			return assert.Fail(t, string(jsondiff.Format(diff)), "request and response are not equal")
+	switch {
+	case testCase.AssertResponse != nil:
+		if customT != nil {
+			testCase.AssertResponse(customT, testCase.ExpectedData, responseBody)
+		}
+	case testCase.ExpectedData == nil && r.Contract != nil:
+		// response body is checked by the contract
+	default:
+		passed = assertResponseBody(t, testCase.ExpectedData, responseBody, responseMediaType(resp.Header.Get("Content-Type")),
+			r.Encoders, r.Decoders) && passed
+	}
+
+	// variables are captured from responses that passed, so a failed attempt
+	// of an eventual test case does not overwrite them
+	if passed && len(testCase.Captures) > 0 {
+		if err := run.Vars.capture(testCase.Captures, resp, responseBody); !assert.NoError(t, err) {
+			t.Logf("body received: %s", string(responseBody))
+
+			return false
 		}
-		return true
 	}
 
-	return assert.Empty(t, string(responseBody), "expected empty response")
+	return passed
 }
 
-// decodeExpected processes expected data into representation used for comparison to actual data
-// (converts object to map, does some type conversion)
-func decodeExpected(data interface{}) interface{} {
-	if decoded, err := objToJsonMap(data); err == nil {
-		return decoded
+// contextError explains an error of the request caused by its context: the run
+// is canceled, the test or the request timed out. Other errors are returned as is
+func (r *httpRunner) contextError(err error, req *http.Request, testCtx context.Context, run *RunContext) error {
+	target := req.Method + " " + req.URL.String()
+	switch {
+	case run.ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("deadline of the run exceeded while waiting for %s", target)
+	case run.ctx.Err() != nil:
+		return fmt.Errorf("run canceled while waiting for %s", target)
+	case testCtx.Err() != nil:
+		return fmt.Errorf("test timed out after %s while waiting for %s", r.TestTimeout, target)
+	case req.Context().Err() != nil:
+		return fmt.Errorf("request %s timed out after %s", target, r.RequestTimeout)
 	}
 
-	return data
+	return err
 }
 
-// decodeResponse processes response data into representation used for comparison to expected data
-func decodeResponse(data []byte) interface{} {
-	var dataMap map[string]interface{}
-	if err := json.Unmarshal(data, &dataMap); err == nil {
-		return dataMap
+// AssertResponse checks that given expected object contains the same data
+// as provided responseBody. Expected object may contain matchers of 'match'
+// package. Response body is treated as JSON, runner uses decoders of
+// the response content type instead
+func AssertResponse(t *testing.T, expected interface{}, responseBody []byte) bool {
+	return assertResponseBody(t, expected, responseBody, defaultMediaType, defaultRequestEncoders, defaultResponseDecoders)
+}
+
+// assertResponseBody is AssertResponse for response body of given media type
+func assertResponseBody(t assert.TestingT, expected interface{}, responseBody []byte, mediaType string,
+	encoders map[string]IRequestEncoder, decoders map[string]IResponseDecoder) bool {
+
+	if expected == nil {
+		return assert.Empty(t, string(responseBody), "expected empty response")
 	}
 
-	return string(data)
+	actualData := decodeResponse(responseBody, mediaType, decoders)
+	if match.HasMatchers(expected) {
+		if err := match.Match(expected, actualData); err != nil {
+			return assert.Fail(t, err.Error(), "response does not match expected data")
+		}
+		return true
+	}
+
+	expectedData := decodeExpected(expected, mediaType, encoders, decoders)
+	diff := jsondiff.Compare(expectedData, actualData)
+	if !diff.IsEqual() {
+		return assert.Fail(t, string(jsondiff.Format(diff)), "request and response are not equal")
+	}
+	return true
 }
 
 func extractTestName(value interface{}) string {
@@ -230,14 +868,15 @@ func extractTestName(value interface{}) string {
 	return reflect.TypeOf(value).String()
 }
 
-func objToJsonMap(obj interface{}) (map[string]interface{}, error) {
+// objToJsonValue converts given object into its generic JSON representation
+// (maps, slices and primitives), the same way JSON decoder would see it
+func objToJsonValue(obj interface{}) (interface{}, error) {
 	js, err := json.Marshal(obj)
 	if err != nil {
 		return nil, err
 	}
 
-	result := map[string]interface{}{}
-
+	var result interface{}
 	err = json.Unmarshal(js, &result)
 	return result, err
 }
@@ -0,0 +1,573 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type slowTest struct {
+	path   string
+	group  string
+	serial bool
+}
+
+func (t *slowTest) Name() string        { return "slow" + strings.Replace(t.path, "/", "_", -1) }
+func (t *slowTest) Method() string      { return "GET" }
+func (t *slowTest) Description() string { return "Test for slow API handler" }
+func (t *slowTest) Path() string        { return t.path }
+func (t *slowTest) SerialGroup() string { return t.group }
+func (t *slowTest) Parallel() bool      { return !t.serial }
+func (t *slowTest) TestCases() []TestCase {
+	return []TestCase{{ExpectedHttpCode: 200, ExpectedData: "ok"}}
+}
+
+// slowClient responds to every request after a delay and tracks
+// how many requests were processed simultaneously
+type slowClient struct {
+	sync.Mutex
+	inFlight      int
+	maxInFlight   int
+	inFlightAt    map[string]int
+	groupInFlight map[string]int
+	groupOverlaps map[string]int
+	order         []string
+}
+
+func newSlowClient() *slowClient {
+	return &slowClient{
+		inFlightAt:    map[string]int{},
+		groupInFlight: map[string]int{},
+		groupOverlaps: map[string]int{},
+	}
+}
+
+func (c *slowClient) Do(req *http.Request) (*http.Response, error) {
+	group := strings.Split(req.URL.Path, "/")[1]
+
+	c.Lock()
+	c.inFlightAt[req.URL.Path] = c.inFlight
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.groupInFlight[group]++
+	if c.groupInFlight[group] > 1 {
+		c.groupOverlaps[group]++
+	}
+	c.order = append(c.order, req.URL.Path)
+	c.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.Lock()
+	c.inFlight--
+	c.groupInFlight[group]--
+	c.Unlock()
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("ok")),
+	}, nil
+}
+
+func TestRunParallel(t *testing.T) {
+	client := newSlowClient()
+	tests := []Test{
+		&slowTest{path: "/a/1"},
+		&slowTest{path: "/b/1"},
+		&slowTest{path: "/c/1"},
+		&slowTest{path: "/d/1"},
+		&slowTest{path: "/e/1"},
+	}
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client, Parallel: true, MaxParallel: 2})
+	runner.Run(t, tests...)
+
+	assert.Len(t, client.order, len(tests))
+	assert.True(t, client.maxInFlight <= 2, "no more than 2 tests are expected to run simultaneously, got %d", client.maxInFlight)
+}
+
+func TestRunParallelSerialGroups(t *testing.T) {
+	client := newSlowClient()
+	tests := []Test{
+		&slowTest{path: "/users/create", group: "users"},
+		&slowTest{path: "/other/1"},
+		&slowTest{path: "/users/update", group: "users"},
+		&slowTest{path: "/serial/1", serial: true},
+		&slowTest{path: "/users/delete", group: "users"},
+		&slowTest{path: "/other/2"},
+	}
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client, Parallel: true})
+	runner.Run(t, tests...)
+
+	assert.Len(t, client.order, len(tests))
+
+	// tests of a serial group never overlap and keep their order
+	assert.Equal(t, 0, client.groupOverlaps["users"])
+	usersOrder := []string{}
+	for _, path := range client.order {
+		if strings.HasPrefix(path, "/users/") {
+			usersOrder = append(usersOrder, path)
+		}
+	}
+	assert.Equal(t, []string{"/users/create", "/users/update", "/users/delete"}, usersOrder)
+
+	// serial test runs alone after all the parallel ones
+	assert.Equal(t, 0, client.inFlightAt["/serial/1"])
+	assert.Equal(t, "/serial/1", client.order[len(client.order)-1])
+}
+
+type namedCasesTest struct {
+	names []string
+}
+
+func (t *namedCasesTest) Method() string      { return "GET" }
+func (t *namedCasesTest) Description() string { return "Test for names of subtests" }
+func (t *namedCasesTest) Path() string        { return "/a/1" }
+func (t *namedCasesTest) TestCases() []TestCase {
+	recordName := func(tt *testing.T, expected interface{}, responseBody []byte) bool {
+		t.names = append(t.names, tt.Name())
+		return true
+	}
+
+	return []TestCase{
+		{Description: "first case", ExpectedHttpCode: 200, AssertResponse: recordName},
+		{ExpectedHttpCode: 200, AssertResponse: recordName},
+	}
+}
+
+func TestRunSubtests(t *testing.T) {
+	test := &namedCasesTest{}
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: newSlowClient()})
+	runner.Run(t, test)
+
+	assert.Equal(t, []string{
+		"TestRunSubtests/*schreder.namedCasesTest/first_case",
+		"TestRunSubtests/*schreder.namedCasesTest/case_2",
+	}, test.names)
+}
+
+type createItemTest struct{}
+
+func (t *createItemTest) Method() string      { return "POST" }
+func (t *createItemTest) Description() string { return "Test for creating an item" }
+func (t *createItemTest) Path() string        { return "/items" }
+func (t *createItemTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			ExpectedHttpCode: 201,
+			AssertResponse:   func(t *testing.T, expected interface{}, responseBody []byte) bool { return true },
+			Captures: []Capture{
+				{Variable: "id", JSONPointer: "/id"},
+				{Variable: "self", JSONPath: "$.links[0]['href']"},
+				{Variable: "token", Header: "X-Token"},
+			},
+		},
+	}
+}
+
+type updateItemTest struct{}
+
+func (t *updateItemTest) Method() string      { return "PUT" }
+func (t *updateItemTest) Description() string { return "Test for updating an item" }
+func (t *updateItemTest) Path() string        { return "/items/{id}" }
+func (t *updateItemTest) TestCases() []TestCase {
+	return []TestCase{
+		{
+			PathParams:  ParamMap{"id": Param{Value: "${id}"}},
+			QueryParams: ParamMap{"self": Param{Value: "${self}"}},
+			Headers:     ParamMap{"Authorization": Param{Value: "Bearer ${token}"}},
+			RequestBody: map[string]interface{}{
+				"id":    "${id}",
+				"names": []string{"item ${id}"},
+				"note":  "$${id} is not a reference",
+			},
+			ExpectedHttpCode: 200,
+			ExpectedHeaders:  map[string]string{"Location": "/items/${id}"},
+			ExpectedData:     map[string]interface{}{"id": "${id}"},
+		},
+	}
+}
+
+func TestRunCapturesVariables(t *testing.T) {
+	requests := []*http.Request{}
+	bodies := []string{}
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		if req.Body != nil {
+			body, _ := ioutil.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+		}
+
+		resp := &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{})}
+		if req.Method == "PUT" {
+			resp.Header.Set("Location", req.URL.Path)
+			resp.Body = ioutil.NopCloser(bytes.NewBufferString(`{"id": 12345678901234567}`))
+		}
+		if req.Method == "POST" {
+			resp.StatusCode = 201
+			resp.Header.Set("X-Token", "secret")
+			resp.Body = ioutil.NopCloser(bytes.NewBufferString(`{"id": 12345678901234567, "links": [{"href": "/items/12345678901234567"}]}`))
+		}
+		return resp, nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client})
+	runner.Run(t, &createItemTest{}, &updateItemTest{})
+
+	if assert.Len(t, requests, 2) {
+		assert.Equal(t, "http://testapi.my/items/12345678901234567?self=%2Fitems%2F12345678901234567", requests[1].URL.String())
+		assert.Equal(t, "Bearer secret", requests[1].Header.Get("Authorization"))
+		assert.JSONEq(t, `{"id": 12345678901234567, "names": ["item 12345678901234567"], "note": "${id} is not a reference"}`, bodies[0])
+	}
+}
+
+func TestResolveUndefinedVariable(t *testing.T) {
+	vars := newVariables()
+	vars.Set("id", 1)
+
+	_, err := vars.resolveTestCase(TestCase{PathParams: ParamMap{"id": Param{Value: "${id}"}}})
+	assert.NoError(t, err)
+
+	_, err = vars.resolveTestCase(TestCase{RequestBody: map[string]interface{}{"name": "${name}"}})
+	assert.EqualError(t, err, "request body: 'name': variable 'name' is not defined")
+
+	_, err = vars.resolveTestCase(TestCase{ExpectedData: map[string]interface{}{"name": "${name}"}})
+	assert.EqualError(t, err, "expected data: 'name': variable 'name' is not defined")
+}
+
+type itemBody struct {
+	XMLName xml.Name    `json:"-" xml:"item"`
+	ID      interface{} `json:"id" xml:"id"`
+	Name    string      `json:"name" xml:"name"`
+	Tags    []string    `json:"tags" xml:"tag"`
+	Owner   *itemOwner  `json:"owner,omitempty" xml:"owner,omitempty"`
+}
+
+type itemOwner struct {
+	ID int `json:"id" xml:"id"`
+}
+
+func TestResolveTypedBody(t *testing.T) {
+	vars := newVariables()
+	vars.Set("id", json.Number("7"))
+	vars.Set("tag", "new")
+
+	body := &itemBody{ID: "${id}", Name: "item ${id}", Tags: []string{"${tag}", "$${tag}"}, Owner: &itemOwner{ID: 1}}
+	resolved, err := vars.resolveBody(body)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, &itemBody{ID: json.Number("7"), Name: "item 7", Tags: []string{"new", "${tag}"}, Owner: &itemOwner{ID: 1}}, resolved,
+		"type of the body is kept")
+	assert.Equal(t, "${id}", body.ID, "body is not changed")
+	assert.True(t, body.Owner == resolved.(*itemBody).Owner, "values with no references are not copied")
+
+	encoded, err := xml.Marshal(resolved)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `<item><id>7</id><name>item 7</name><tag>new</tag><tag>${tag}</tag><owner><id>1</id></owner></item>`, string(encoded))
+	}
+
+	unchanged := itemBody{Name: "no references"}
+	resolved, err = vars.resolveBody(unchanged)
+	assert.NoError(t, err)
+	assert.Equal(t, unchanged, resolved)
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tokens, err := parseJSONPath("$.items[0]['first.name'].id")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"items", "0", "first.name", "id"}, tokens)
+
+	_, err = parseJSONPath("items[0]")
+	assert.Error(t, err)
+}
+
+func TestValidateResponseSchema(t *testing.T) {
+	violations, err := validateResponseSchema(SearchResult{}, []byte(`{
+		"total_count": 1,
+		"updated_at": "2016-10-16T10:00:00Z",
+		"items": [{"login": "octocat", "id": 1}]
+	}`))
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = validateResponseSchema(SearchResult{}, []byte(`{
+		"total_count": 1.5,
+		"updated_at": "yesterday",
+		"items": [{"login": "octocat"}, {"login": "monalisa", "id": "2", "role": "admin"}]
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`/items/1/id: expected integer, got "2"`,
+		`/items/1/role: property is not allowed`,
+		`/total_count: expected integer, got 1.5`,
+		`/updated_at: "yesterday" is not a date-time`,
+	}, violations)
+
+	violations, err = validateResponseSchema(SearchResult{}, []byte(`[]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/: expected object, got []"}, violations)
+}
+
+type nullableItem struct {
+	Name    string            `json:"name"`
+	Comment *string           `json:"comment"`
+	Owner   *itemOwner        `json:"owner"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Related []*nullableItem   `json:"related"`
+}
+
+func TestValidateResponseSchemaNullable(t *testing.T) {
+	violations, err := validateResponseSchema(nullableItem{}, []byte(`{
+		"name": "first",
+		"comment": null,
+		"owner": null,
+		"tags": null,
+		"labels": null,
+		"related": [null, {"name": "second", "comment": null, "owner": {"id": 1}, "tags": [], "labels": {}, "related": null}]
+	}`))
+	assert.NoError(t, err)
+	assert.Empty(t, violations, "nil fields of expected data are encoded as null")
+
+	violations, err = validateResponseSchema(&nullableItem{}, []byte(`{
+		"name": null, "comment": 1, "owner": null, "tags": [null], "labels": null, "related": null
+	}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`/comment: expected string, got 1`,
+		`/name: expected string, got null`,
+		`/tags/0: expected string, got null`,
+	}, violations)
+}
+
+func TestRunValidatesSchema(t *testing.T) {
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewBufferString("Hello World!")),
+		}, nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{HttpClient: client, ValidateSchema: true})
+	runner.Run(t, &HelloTest{})
+}
+
+type encodedBodyTest struct{}
+
+func (t *encodedBodyTest) Method() string      { return "POST" }
+func (t *encodedBodyTest) Description() string { return "Test for encoding of request bodies" }
+func (t *encodedBodyTest) Path() string        { return "/items" }
+func (t *encodedBodyTest) TestCases() []TestCase {
+	type item struct {
+		XMLName struct{} `xml:"item" json:"-"`
+		Name    string   `xml:"name" json:"name"`
+		Count   int      `xml:"count" json:"count,omitempty"`
+	}
+
+	contentType := func(value string) ParamMap {
+		return ParamMap{"Content-Type": Param{Value: value}}
+	}
+
+	return []TestCase{
+		{Description: "json", RequestBody: item{Name: "a", Count: 1}, ExpectedHttpCode: 200},
+		{Description: "form", Headers: contentType("application/x-www-form-urlencoded"),
+			RequestBody: item{Name: "a b"}, ExpectedHttpCode: 200},
+		{Description: "multipart", Headers: contentType("multipart/form-data"),
+			RequestBody: map[string]interface{}{
+				"name": "a",
+				"file": &File{Name: "a.txt", ContentType: "text/plain", Content: []byte("content")},
+			}, ExpectedHttpCode: 200},
+		{Description: "xml", Headers: contentType("application/xml"), RequestBody: item{Name: "a", Count: 2}, ExpectedHttpCode: 200},
+		{Description: "text", Headers: contentType("text/plain; charset=utf-8"), RequestBody: 42, ExpectedHttpCode: 200},
+		{Description: "raw", Headers: contentType("image/png"), RequestBody: strings.NewReader("PNG"), ExpectedHttpCode: 200},
+		{Description: "custom", Headers: contentType("application/x-custom"), RequestBody: "a", ExpectedHttpCode: 200},
+	}
+}
+
+func TestRunEncodesRequestBody(t *testing.T) {
+	contentTypes := []string{}
+	bodies := []string{}
+	var multipartForm *multipart.Form
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		contentTypes = append(contentTypes, req.Header.Get("Content-Type"))
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := req.ParseMultipartForm(1024); err != nil {
+				return nil, err
+			}
+			multipartForm = req.MultipartForm
+			bodies = append(bodies, "")
+		} else {
+			body, _ := ioutil.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+		}
+
+		return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+	})
+
+	custom := IRequestEncoderFunc(func(body interface{}, contentType string) ([]byte, string, error) {
+		return []byte(fmt.Sprintf("custom:%v", body)), contentType + "; v=1", nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{
+		HttpClient: client,
+		Encoders:   map[string]IRequestEncoder{"application/x-custom": custom},
+	})
+	runner.Run(t, &encodedBodyTest{})
+
+	if !assert.Len(t, bodies, 7) {
+		return
+	}
+	assert.Equal(t, []string{
+		"",
+		"application/x-www-form-urlencoded",
+		contentTypes[2],
+		"application/xml",
+		"text/plain; charset=utf-8",
+		"image/png",
+		"application/x-custom; v=1",
+	}, contentTypes)
+	assert.Equal(t, `{"name":"a","count":1}`, bodies[0])
+	assert.Equal(t, "name=a+b", bodies[1])
+	assert.Equal(t, "<item><name>a</name><count>2</count></item>", bodies[3])
+	assert.Equal(t, "42", bodies[4])
+	assert.Equal(t, "PNG", bodies[5])
+	assert.Equal(t, "custom:a", bodies[6])
+
+	assert.True(t, strings.HasPrefix(contentTypes[2], "multipart/form-data; boundary="))
+	if assert.NotNil(t, multipartForm) {
+		assert.Equal(t, []string{"a"}, multipartForm.Value["name"])
+		if assert.Len(t, multipartForm.File["file"], 1) {
+			assert.Equal(t, "a.txt", multipartForm.File["file"][0].Filename)
+			assert.Equal(t, "text/plain", multipartForm.File["file"][0].Header.Get("Content-Type"))
+		}
+	}
+
+	encoded, contentType, err := runner.encode(map[string]interface{}{"name": "a"}, "application/vnd.api.v2")
+	if assert.NoError(t, err, "body of unknown media type is encoded as JSON") {
+		assert.JSONEq(t, `{"name": "a"}`, string(encoded))
+		assert.Equal(t, "application/vnd.api.v2", contentType)
+	}
+
+	_, _, err = encodeBody(map[string]IRequestEncoder{defaultMediaType: IRequestEncoderFunc(encodeJSON)},
+		map[string]interface{}{"name": "a"}, "multipart/form-data")
+	assert.EqualError(t, err, "no encoder is registered for media type 'multipart/form-data'")
+}
+
+func TestDecodeResponse(t *testing.T) {
+	type item struct {
+		XMLName struct{} `xml:"item" json:"-"`
+		ID      int      `xml:"id,attr" json:"id"`
+		Name    string   `xml:"name" json:"name"`
+		Tags    []string `xml:"tag" json:"tags"`
+	}
+
+	cases := []struct {
+		mediaType string
+		body      string
+		expected  interface{}
+	}{
+		{"application/json", `[{"id":1,"name":"a","tags":["x"]},{"id":2,"name":"b","tags":null}]`,
+			[]item{{ID: 1, Name: "a", Tags: []string{"x"}}, {ID: 2, Name: "b"}}},
+		{"application/json", `42`, 42},
+		{"application/json", `"Hello"`, "Hello"},
+		{"application/json", `Hello`, "Hello"},
+		{"application/hal+json", `{"id":1,"name":"a","tags":[]}`, item{ID: 1, Name: "a", Tags: []string{}}},
+		{"application/xml", `<item id="1"><name>a</name><tag>x</tag><tag>y</tag></item>`,
+			item{ID: 1, Name: "a", Tags: []string{"x", "y"}}},
+		{"text/xml", "<item id=\"1\">\n  <name>a</name>\n</item>", item{ID: 1, Name: "a"}},
+		{"application/xml", `<item><name>a</name></item>`, []byte("<item>\n<name>a</name>\n</item>")},
+		{"application/x-www-form-urlencoded", `id=1&name=a&tags=x&tags=y`, item{ID: 1, Name: "a", Tags: []string{"x", "y"}}},
+		{"application/x-yaml", "- id: 1\n  name: a\n  tags: [x]\n", []item{{ID: 1, Name: "a", Tags: []string{"x"}}}},
+		{"text/plain", `42`, 42},
+		{"image/png", `PNG`, []byte("PNG")},
+	}
+
+	for _, c := range cases {
+		actual := decodeResponse([]byte(c.body), c.mediaType, defaultResponseDecoders)
+		expected := decodeExpected(c.expected, c.mediaType, defaultRequestEncoders, defaultResponseDecoders)
+		assert.Equal(t, expected, actual, "%s: %s", c.mediaType, c.body)
+	}
+
+	assert.Equal(t, map[string]interface{}{"item": map[string]interface{}{
+		"@id":  "1",
+		"name": "a",
+		"tag":  []interface{}{"x", "y"},
+	}}, decodeResponse([]byte(`<item id="1"><name>a</name><tag>x</tag><tag>y</tag></item>`), "application/xml", defaultResponseDecoders))
+
+	// arrays are compared structurally, so order of items matters
+	assert.NotEqual(t,
+		decodeExpected([]int{2, 1}, "application/json", defaultRequestEncoders, defaultResponseDecoders),
+		decodeResponse([]byte(`[1, 2]`), "application/json", defaultResponseDecoders))
+}
+
+type csvItemsTest struct{}
+
+func (t *csvItemsTest) Method() string      { return "GET" }
+func (t *csvItemsTest) Description() string { return "Test for custom decoders of response bodies" }
+func (t *csvItemsTest) Path() string        { return "/items" }
+func (t *csvItemsTest) TestCases() []TestCase {
+	return []TestCase{
+		{ExpectedHttpCode: 200, ExpectedData: []byte("1,a\n2,b")},
+	}
+}
+
+func TestRunDecodesResponseBody(t *testing.T) {
+	client := IHttpClientFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": []string{"application/vnd.item+csv"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString("1,a\n2,b\n")),
+		}, nil
+	})
+
+	csv := IResponseDecoderFunc(func(body []byte) (interface{}, error) {
+		rows := []interface{}{}
+		for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+			rows = append(rows, strings.Split(line, ","))
+		}
+		return rows, nil
+	})
+
+	runner := NewRunner("http://testapi.my", RunnerConfig{
+		HttpClient: client,
+		Decoders:   map[string]IResponseDecoder{"application/vnd.item+csv": csv},
+	})
+	runner.Run(t, &csvItemsTest{})
+}
+
+func TestRunReportsResults(t *testing.T) {
+	results := []TestCaseResult{}
+	runner := NewRunner("http://testapi.my", RunnerConfig{
+		HttpClient: newSlowClient(),
+		OnResult:   func(result TestCaseResult) { results = append(results, result) },
+	})
+	runner.Run(t, &namedCasesTest{})
+
+	if assert.Len(t, results, 2) {
+		for i, name := range []string{"first case", "case 2"} {
+			assert.Equal(t, "*schreder.namedCasesTest", results[i].Test)
+			assert.Equal(t, "GET", results[i].Method)
+			assert.Equal(t, "/a/1", results[i].Path)
+			assert.Equal(t, name, results[i].TestCase)
+			assert.True(t, results[i].Passed)
+		}
+	}
+}
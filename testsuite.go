@@ -1,8 +1,11 @@
 package schreder
 
 import (
+	"context"
 	"fmt"
+	"mime"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/jingweno/go-sawyer/hypermedia"
@@ -33,6 +36,63 @@ type Teardownable interface {
 	TearDown() error
 }
 
+// ContextSetuppable defines interface for tests that have setup logic which
+// respects cancellation
+//
+// SetUpContext is called instead of SetUp with the context of the test, it's
+// canceled once the test times out or the run is canceled
+type ContextSetuppable interface {
+	SetUpContext(ctx context.Context) error
+}
+
+// ContextTeardownable defines interface for tests that have teardown logic which
+// respects cancellation
+//
+// TearDownContext is called instead of TearDown. Its context is not canceled
+// when the test times out, so the test can clean up after itself
+type ContextTeardownable interface {
+	TearDownContext(ctx context.Context) error
+}
+
+// CaseSetuppable defines interface for tests that prepare each of their test cases
+//
+// SetUpCase is called before every test case of the test and may change it,
+// e.g. to refer data created for the case. The case is not run if it fails
+type CaseSetuppable interface {
+	SetUpCase(run *RunContext, testCase *TestCase) error
+}
+
+// CaseTeardownable defines interface for tests that clean up after each of their test cases
+//
+// TearDownCase is called after every test case of the test, even the failed one
+type CaseTeardownable interface {
+	TearDownCase(run *RunContext, testCase TestCase) error
+}
+
+// Parallelizable defines interface for tests that control their parallel execution
+//
+// Test that returns false is never run in parallel with other tests, even if
+// runner is configured to run tests in parallel
+type Parallelizable interface {
+	Parallel() bool
+}
+
+// SerialGroupable defines interface for tests that share some state with other tests
+//
+// Tests of the same serial group are never run simultaneously. In parallel mode
+// they run one by one in the order they are given to the runner
+type SerialGroupable interface {
+	SerialGroup() string
+}
+
+// Secured defines interface for tests of endpoints that require authentication
+//
+// Doc generators document security schemes of the test and leave out parameters
+// that carry its credentials, so secrets used by tests do not leak into docs
+type Secured interface {
+	SecuritySchemes() []SecurityScheme
+}
+
 // AssertResponseFunc defines function that asserts that expected object equals to
 // given response body
 type AssertResponseFunc func(t *testing.T, expected interface{}, responseBody []byte) bool
@@ -61,6 +121,16 @@ type TestCase struct {
 	// for processing of API response payload and assertion with
 	// expected data.
 	AssertResponse AssertResponseFunc
+
+	// Captures define values of the response that are stored into variables
+	// of the run, so following test cases can refer them
+	Captures []Capture
+
+	// Retry overrides the retry policy of the runner for the test case
+	Retry *RetryPolicy
+	// Eventually makes the runner repeat the request until assertions of
+	// the test case pass, e.g. to poll an asynchronous endpoint
+	Eventually *Eventually
 }
 
 type ParamMap map[string]Param
@@ -71,6 +141,11 @@ type Param struct {
 	Value       interface{}
 	Required    bool
 	Description string
+
+	// Secret marks values like API keys and passwords. The runner masks them
+	// in its output whatever their length, so short PINs are masked as well,
+	// doc generators never put them into documentation
+	Secret bool
 }
 
 // Url generates full URL to API endpoint for given test case.
@@ -98,3 +173,46 @@ func (testCase *TestCase) Url(urlpath string) (string, error) {
 
 	return u.String(), nil
 }
+
+// requestMediaType returns media type of the request body defined by
+// Content-Type header of the test case. JSON is assumed if it's not defined.
+func (testCase *TestCase) requestMediaType() string {
+	for name, param := range testCase.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			return parseMediaType(fmt.Sprintf("%v", param.Value))
+		}
+	}
+
+	return defaultMediaType
+}
+
+// responseMediaType returns media type of the response body defined by
+// expected Content-Type header of the test case. JSON is assumed if it's not defined.
+func (testCase *TestCase) responseMediaType() string {
+	for name, value := range testCase.ExpectedHeaders {
+		if strings.EqualFold(name, "Content-Type") {
+			return parseMediaType(value)
+		}
+	}
+
+	return defaultMediaType
+}
+
+// testCaseName provides a name that is used to refer the test case in
+// subtests of a runner and in examples of generated documentation
+func testCaseName(testCase TestCase, caseIndex int) string {
+	if testCase.Description != "" {
+		return testCase.Description
+	}
+
+	return fmt.Sprintf("case %d", caseIndex+1)
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+
+	return mediaType
+}
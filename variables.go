@@ -0,0 +1,482 @@
+package schreder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Capture defines a value that is captured from the response of a test case
+// into a variable of the run. Following test cases can refer the variable as
+// "${name}" in values of PathParams, QueryParams, Headers, in RequestBody,
+// ExpectedHeaders and ExpectedData. "$${name}" stands for literal "${name}".
+//
+// Exactly one source of the value must be defined
+type Capture struct {
+	// Variable is a name of the variable that receives captured value
+	Variable string
+
+	// JSONPointer points to the value in JSON response body, e.g. "/items/0/id"
+	JSONPointer string
+	// JSONPath points to the value in JSON response body, e.g. "$.items[0].id".
+	// Only child and index selectors are supported
+	JSONPath string
+	// Header is a name of the response header that holds the value
+	Header string
+}
+
+// variableReference matches references to variables like "${name}" and
+// escaped references like "$${name}"
+var variableReference = regexp.MustCompile(`\$?\$\{([^}]+)\}`)
+
+// Variables is a store of values that are shared between test cases of one run.
+// It's safe for concurrent use
+type Variables struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+func newVariables() *Variables {
+	return &Variables{values: map[string]interface{}{}}
+}
+
+// Get returns value of the variable and reports whether it's defined
+func (v *Variables) Get(name string) (interface{}, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// Set defines value of the variable
+func (v *Variables) Set(name string, value interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.values[name] = value
+}
+
+// resolveTestCase returns a copy of the test case with all references
+// to variables in its request and expectations replaced by their values
+func (v *Variables) resolveTestCase(testCase TestCase) (TestCase, error) {
+	var err error
+	if testCase.PathParams, err = v.resolveParams(testCase.PathParams); err != nil {
+		return testCase, fmt.Errorf("path params: %s", err.Error())
+	}
+	if testCase.QueryParams, err = v.resolveParams(testCase.QueryParams); err != nil {
+		return testCase, fmt.Errorf("query params: %s", err.Error())
+	}
+	if testCase.Headers, err = v.resolveParams(testCase.Headers); err != nil {
+		return testCase, fmt.Errorf("headers: %s", err.Error())
+	}
+	if testCase.RequestBody, err = v.resolveBody(testCase.RequestBody); err != nil {
+		return testCase, fmt.Errorf("request body: %s", err.Error())
+	}
+
+	expectedHeaders, _, err := v.substitution().value(testCase.ExpectedHeaders)
+	if err != nil {
+		return testCase, fmt.Errorf("expected headers: %s", err.Error())
+	}
+	testCase.ExpectedHeaders = expectedHeaders.(map[string]string)
+	if testCase.ExpectedData, err = v.resolveBody(testCase.ExpectedData); err != nil {
+		return testCase, fmt.Errorf("expected data: %s", err.Error())
+	}
+
+	return testCase, nil
+}
+
+func (v *Variables) substitution() substitution {
+	return substitution{lookup: v.Get}
+}
+
+func (v *Variables) resolveParams(params ParamMap) (ParamMap, error) {
+	if params == nil {
+		return nil, nil
+	}
+
+	resolved := ParamMap{}
+	for name, param := range params {
+		if value, ok := param.Value.(string); ok {
+			resolvedValue, _, err := v.substitution().str(value)
+			if err != nil {
+				return nil, fmt.Errorf("'%s': %s", name, err.Error())
+			}
+			param.Value = resolvedValue
+		}
+		resolved[name] = param
+	}
+
+	return resolved, nil
+}
+
+// resolveBody replaces references to variables in strings of the body.
+// Body that has no references is returned as is, otherwise its copy of the
+// same type is returned
+func (v *Variables) resolveBody(body interface{}) (interface{}, error) {
+	switch body.(type) {
+	case nil, []byte, io.Reader:
+		return body, nil
+	}
+
+	resolved, _, err := v.substitution().value(body)
+	return resolved, err
+}
+
+// substitution replaces references like "${name}" in strings with values
+// that lookup returns. "$${name}" is an escaped reference that stands for
+// literal "${name}". Partial substitution leaves references lookup does not
+// know and escaped ones as is, so they can be substituted later, otherwise
+// they are an error
+type substitution struct {
+	lookup  func(name string) (interface{}, bool)
+	partial bool
+}
+
+// str replaces references in given string. If the whole string is a reference,
+// the value is returned as is, so its type is kept. Reports whether anything
+// was replaced
+func (s substitution) str(value string) (interface{}, bool, error) {
+	if match := variableReference.FindStringSubmatch(value); match != nil && match[0] == value && !isEscapedReference(value) {
+		resolved, ok := s.lookup(match[1])
+		if !ok {
+			if s.partial {
+				return value, false, nil
+			}
+			return nil, false, fmt.Errorf("variable '%s' is not defined", match[1])
+		}
+		return resolved, true, nil
+	}
+
+	var err error
+	changed := false
+	substituted := variableReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if isEscapedReference(reference) {
+			if s.partial {
+				return reference
+			}
+			changed = true
+			return reference[1:]
+		}
+
+		name := variableReference.FindStringSubmatch(reference)[1]
+		resolved, ok := s.lookup(name)
+		if !ok {
+			if !s.partial && err == nil {
+				err = fmt.Errorf("variable '%s' is not defined", name)
+			}
+			return reference
+		}
+		changed = true
+		return fmt.Sprintf("%v", resolved)
+	})
+
+	return substituted, changed, err
+}
+
+// value replaces references in strings of given value: in items of maps and
+// slices and in exported fields of structs. Value that has no references is
+// returned as is, otherwise its copy of the same type is returned. Reports
+// whether anything was replaced
+func (s substitution) value(value interface{}) (interface{}, bool, error) {
+	if str, ok := value.(string); ok {
+		return s.str(str)
+	}
+	if value == nil {
+		return nil, false, nil
+	}
+
+	substituted, changed, err := s.reflected(reflect.ValueOf(value))
+	if err != nil || !changed {
+		return value, false, err
+	}
+
+	return substituted.Interface(), true, nil
+}
+
+func (s substitution) reflected(value reflect.Value) (reflect.Value, bool, error) {
+	switch value.Kind() {
+	case reflect.String:
+		substituted, changed, err := s.str(value.String())
+		if err != nil || !changed {
+			return value, false, err
+		}
+		// a string field can't hold a value of another type
+		result := reflect.New(value.Type()).Elem()
+		result.SetString(fmt.Sprintf("%v", substituted))
+		return result, true, nil
+
+	case reflect.Interface:
+		if value.IsNil() {
+			return value, false, nil
+		}
+		var item interface{}
+		var changed bool
+		var err error
+		if str, ok := value.Elem().Interface().(string); ok {
+			// an interface can hold a value of any type, so it's kept
+			item, changed, err = s.str(str)
+		} else {
+			var elem reflect.Value
+			if elem, changed, err = s.reflected(value.Elem()); changed {
+				item = elem.Interface()
+			}
+		}
+		if err != nil || !changed {
+			return value, false, err
+		}
+		result := reflect.New(value.Type()).Elem()
+		if item != nil {
+			result.Set(reflect.ValueOf(item))
+		}
+		return result, true, nil
+
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value, false, nil
+		}
+		elem, changed, err := s.reflected(value.Elem())
+		if err != nil || !changed {
+			return value, false, err
+		}
+		result := reflect.New(value.Type().Elem())
+		result.Elem().Set(elem)
+		return result, true, nil
+
+	case reflect.Map:
+		if value.IsNil() {
+			return value, false, nil
+		}
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		changed := false
+		for _, key := range value.MapKeys() {
+			item, itemChanged, err := s.reflected(value.MapIndex(key))
+			if err != nil {
+				return value, false, fmt.Errorf("'%v': %s", key.Interface(), err.Error())
+			}
+			result.SetMapIndex(key, item)
+			changed = changed || itemChanged
+		}
+		if !changed {
+			return value, false, nil
+		}
+		return result, true, nil
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && (value.IsNil() || value.Type().Elem().Kind() == reflect.Uint8) {
+			return value, false, nil
+		}
+		var result reflect.Value
+		if value.Kind() == reflect.Slice {
+			result = reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+			reflect.Copy(result, value)
+		} else {
+			result = reflect.New(value.Type()).Elem()
+			result.Set(value)
+		}
+		changed := false
+		for i := 0; i < value.Len(); i++ {
+			item, itemChanged, err := s.reflected(value.Index(i))
+			if err != nil {
+				return value, false, fmt.Errorf("[%d]: %s", i, err.Error())
+			}
+			if itemChanged {
+				result.Index(i).Set(item)
+				changed = true
+			}
+		}
+		if !changed {
+			return value, false, nil
+		}
+		return result, true, nil
+
+	case reflect.Struct:
+		result := reflect.New(value.Type()).Elem()
+		result.Set(value)
+		changed := false
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			item, itemChanged, err := s.reflected(value.Field(i))
+			if err != nil {
+				return value, false, fmt.Errorf("'%s': %s", field.Name, err.Error())
+			}
+			if itemChanged {
+				result.Field(i).Set(item)
+				changed = true
+			}
+		}
+		if !changed {
+			return value, false, nil
+		}
+		return result, true, nil
+	}
+
+	return value, false, nil
+}
+
+// isEscapedReference tells whether given reference is escaped like "$${name}"
+func isEscapedReference(reference string) bool {
+	return strings.HasPrefix(reference, "$$")
+}
+
+// refersVariables tells whether given value is a string that refers variables
+func refersVariables(value interface{}) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+
+	for _, reference := range variableReference.FindAllString(str, -1) {
+		if !isEscapedReference(reference) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// capture stores values captured from given response into variables
+func (v *Variables) capture(captures []Capture, resp *http.Response, responseBody []byte) error {
+	var document interface{}
+	decoded := false
+
+	for _, c := range captures {
+		var value interface{}
+		switch {
+		case c.Header != "":
+			if _, ok := resp.Header[http.CanonicalHeaderKey(c.Header)]; !ok {
+				return fmt.Errorf("could not capture '%s': response has no header '%s'", c.Variable, c.Header)
+			}
+			value = resp.Header.Get(c.Header)
+
+		case c.JSONPointer != "" || c.JSONPath != "":
+			if !decoded {
+				decoder := json.NewDecoder(bytes.NewReader(responseBody))
+				decoder.UseNumber() // keeps big numbers like IDs intact
+				if err := decoder.Decode(&document); err != nil {
+					return fmt.Errorf("could not capture '%s': response body is not a valid JSON: %s", c.Variable, err.Error())
+				}
+				decoded = true
+			}
+
+			var tokens []string
+			var err error
+			if c.JSONPointer != "" {
+				tokens, err = parseJSONPointer(c.JSONPointer)
+			} else {
+				tokens, err = parseJSONPath(c.JSONPath)
+			}
+			if err != nil {
+				return fmt.Errorf("could not capture '%s': %s", c.Variable, err.Error())
+			}
+
+			if value, err = lookupJSONValue(document, tokens); err != nil {
+				return fmt.Errorf("could not capture '%s': %s", c.Variable, err.Error())
+			}
+
+		default:
+			return fmt.Errorf("could not capture '%s': source of the value is not defined", c.Variable)
+		}
+
+		v.Set(c.Variable, value)
+	}
+
+	return nil
+}
+
+// parseJSONPointer splits RFC 6901 JSON pointer into unescaped reference tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON pointer '%s' must start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.Replace(token, "~1", "/", -1)
+		tokens[i] = strings.Replace(token, "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// parseJSONPath splits JSONPath like "$.items[0].id" or "$['items'][0]['id']"
+// into reference tokens
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath '%s' must start with '$'", path)
+	}
+
+	tokens := []string{}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath '%s' has an empty child selector", path)
+			}
+			tokens = append(tokens, rest[1:end+1])
+			rest = rest[end+1:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath '%s' has an unclosed selector", path)
+			}
+			selector := rest[1:end]
+			if unquoted, err := strconv.Unquote(strings.Replace(selector, "'", "\"", -1)); err == nil {
+				selector = unquoted
+			}
+			tokens = append(tokens, selector)
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("JSONPath '%s' has an unsupported selector at '%s'", path, rest)
+		}
+	}
+
+	return tokens, nil
+}
+
+// lookupJSONValue finds a value in generic JSON document by reference tokens
+func lookupJSONValue(document interface{}, tokens []string) (interface{}, error) {
+	value := document
+	for i, token := range tokens {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			item, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("no value at '/%s'", strings.Join(tokens[:i+1], "/"))
+			}
+			value = item
+
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("no value at '/%s'", strings.Join(tokens[:i+1], "/"))
+			}
+			value = node[index]
+
+		default:
+			return nil, fmt.Errorf("no value at '/%s'", strings.Join(tokens[:i+1], "/"))
+		}
+	}
+
+	return value, nil
+}